@@ -0,0 +1,305 @@
+// backend/cmd/api/book.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+const (
+	defaultBookDepth = 20
+	maxBookDepth     = 500
+	// how long a first request waits for the stream's snapshot
+	bookSyncWait     = 3 * time.Second
+	bookPushInterval = 250 * time.Millisecond
+	// books nobody reads are unsubscribed after this
+	defaultBookIdleTTL = 5 * time.Minute
+	bookSweepInterval  = 30 * time.Second
+)
+
+var (
+	defaultDepthBands = []float64{10, 25, 50, 100}
+	errBookNotReady   = errors.New("order book not synced yet")
+)
+
+type bookResponse struct {
+	Symbol string `json:"symbol"`
+	internal.BookSnapshot
+	Stats internal.BookStats `json:"stats"`
+}
+
+// bookParams reads ?depth=N&bps=10,25,50.
+func bookParams(r *http.Request) (int, []float64, error) {
+	depth := defaultBookDepth
+	if v := r.URL.Query().Get("depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, nil, errors.New("depth must be a positive integer")
+		}
+		if n > maxBookDepth {
+			n = maxBookDepth
+		}
+		depth = n
+	}
+
+	bands := defaultDepthBands
+	if v := r.URL.Query().Get("bps"); v != "" {
+		bands = nil
+		for _, part := range strings.Split(v, ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil || f <= 0 {
+				return 0, nil, errors.New("bps must be a comma-separated list of positive numbers")
+			}
+			bands = append(bands, f)
+		}
+	}
+	return depth, bands, nil
+}
+
+// bookSubs subscribes the stream to a market's book on first use and
+// releases it once nothing has read it for ttl and no /ws/book client
+// is watching.
+type bookSubs struct {
+	stream *internal.StreamClient
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	lastUsed map[int]time.Time
+	watchers map[int]int
+}
+
+func newBookSubs(stream *internal.StreamClient, ttl time.Duration) *bookSubs {
+	return &bookSubs{
+		stream:   stream,
+		ttl:      ttl,
+		lastUsed: make(map[int]time.Time),
+		watchers: make(map[int]int),
+	}
+}
+
+// newBookSubsFromEnv reads BOOK_IDLE_TTL (a Go duration).
+func newBookSubsFromEnv(stream *internal.StreamClient) *bookSubs {
+	ttl := defaultBookIdleTTL
+	if v := os.Getenv("BOOK_IDLE_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			ttl = d
+		} else {
+			log.Printf("bad BOOK_IDLE_TTL %q, using %s", v, ttl)
+		}
+	}
+	return newBookSubs(stream, ttl)
+}
+
+// open marks marketID used and subscribes its book if needed.
+func (b *bookSubs) open(marketID int) *internal.OrderBook {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastUsed[marketID] = time.Now()
+	book, ok := b.stream.Book(marketID)
+	if !ok {
+		b.stream.SubscribeOrderBook(marketID)
+		book, _ = b.stream.Book(marketID)
+	}
+	return book
+}
+
+// get opens the market's book and waits briefly for its snapshot.
+func (b *bookSubs) get(ctx context.Context, marketID int) (*internal.OrderBook, error) {
+	book := b.open(marketID)
+
+	deadline := time.Now().Add(bookSyncWait)
+	for !book.Synced() {
+		if time.Now().After(deadline) {
+			return nil, errBookNotReady
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return book, nil
+}
+
+// watch opens the market's book and keeps it subscribed until release
+// is called.
+func (b *bookSubs) watch(marketID int) (book *internal.OrderBook, release func()) {
+	book = b.open(marketID)
+	b.mu.Lock()
+	b.watchers[marketID]++
+	b.mu.Unlock()
+	return book, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.watchers[marketID]--
+		b.lastUsed[marketID] = time.Now()
+	}
+}
+
+// Run releases idle books until ctx is done.
+func (b *bookSubs) Run(ctx context.Context) {
+	ticker := time.NewTicker(bookSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			b.sweep(now)
+		}
+	}
+}
+
+// sweep unsubscribes books unused since now-ttl. It holds mu through
+// the unsubscribe so open can't hand out a book that's going away.
+func (b *bookSubs) sweep(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, at := range b.lastUsed {
+		if b.watchers[id] > 0 || now.Sub(at) < b.ttl {
+			continue
+		}
+		b.stream.Unsubscribe(fmt.Sprintf("order_book/%d", id))
+		delete(b.lastUsed, id)
+		delete(b.watchers, id)
+	}
+}
+
+func buildBookResponse(symbol string, book *internal.OrderBook, depth int, bands []float64) bookResponse {
+	full := book.Snapshot(0)
+	return bookResponse{
+		Symbol:       symbol,
+		BookSnapshot: full.Truncate(depth),
+		Stats:        full.Stats(bands),
+	}
+}
+
+// GET /api/markets/{symbol}/book?depth=N&bps=10,25
+func handleMarketBook(tr *trading, books *bookSubs, symbol string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		depth, bands, err := bookParams(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		mkt, ok := resolveMarket(w, r, tr, symbol)
+		if !ok {
+			return
+		}
+		book, err := books.get(r.Context(), mkt.MarketID)
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, buildBookResponse(mkt.Symbol, book, depth, bands))
+	}
+}
+
+// /api/markets/{symbol}/{resource}
+func handleMarketPath(tr *trading, books *bookSubs, stream *internal.StreamClient, candles *internal.CandleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/markets/"), "/"), "/")
+		if len(parts) != 2 || parts[0] == "" {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		symbol := strings.ToUpper(parts[0])
+		switch parts[1] {
+		case "book":
+			handleMarketBook(tr, books, symbol)(w, r)
+		case "candles":
+			handleMarketCandles(tr, stream, candles, symbol)(w, r)
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+	}
+}
+
+// /ws/book?symbol=ETH&depth=20&bps=10,25 pushes the book whenever it
+// moves, at most every bookPushInterval. It reads only local state.
+func handleBookWS(tr *trading, books *bookSubs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		symbol := strings.ToUpper(r.URL.Query().Get("symbol"))
+		if symbol == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "symbol is required"})
+			return
+		}
+		depth, bands, err := bookParams(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		mkt, ok := resolveMarket(w, r, tr, symbol)
+		if !ok {
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("ws upgrade error: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		book, release := books.watch(mkt.MarketID)
+		defer release()
+
+		ticker := time.NewTicker(bookPushInterval)
+		defer ticker.Stop()
+		heartbeat := time.NewTicker(wsHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		var lastOffset int64 = -1
+		for {
+			select {
+			case <-closed:
+				return
+			case <-heartbeat.C:
+				_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteJSON(wsMessage{Type: "heartbeat", Ts: time.Now().UnixMilli()}); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if !book.Synced() {
+					continue
+				}
+				resp := buildBookResponse(mkt.Symbol, book, depth, bands)
+				if resp.Offset == lastOffset {
+					continue
+				}
+				lastOffset = resp.Offset
+				_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteJSON(resp); err != nil {
+					log.Printf("ws book write error: %v", err)
+					return
+				}
+				heartbeat.Reset(wsHeartbeatInterval)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// backend/cmd/api/book_test.go
+package main
+
+import (
+	"testing"
+	"time"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+func TestBookSubsReleaseIdle(t *testing.T) {
+	// never connected: subscribes only update the channel set
+	stream := internal.NewStreamClient("ws://127.0.0.1:0/stream", internal.StreamHandlers{})
+	books := newBookSubs(stream, time.Minute)
+
+	books.open(1)
+	_, release := books.watch(2)
+	subscribed := func(want map[int]bool) {
+		t.Helper()
+		for id := 1; id <= 2; id++ {
+			if _, ok := stream.Book(id); ok != want[id] {
+				t.Errorf("market %d subscribed %v, want %v", id, ok, want[id])
+			}
+		}
+	}
+
+	books.sweep(time.Now().Add(30 * time.Second))
+	subscribed(map[int]bool{1: true, 2: true})
+
+	// past the ttl only the watched book stays
+	books.sweep(time.Now().Add(2 * time.Minute))
+	subscribed(map[int]bool{2: true})
+
+	// once the watcher leaves, its book idles out like any other
+	release()
+	books.sweep(time.Now().Add(30 * time.Second))
+	subscribed(map[int]bool{2: true})
+	books.sweep(time.Now().Add(2 * time.Minute))
+	subscribed(map[int]bool{})
+}
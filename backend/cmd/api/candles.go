@@ -0,0 +1,83 @@
+// backend/cmd/api/candles.go
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+const (
+	defaultCandleLimit = 500
+	maxCandleLimit     = 1500
+)
+
+type candlesResponse struct {
+	Symbol     string            `json:"symbol"`
+	MarketID   int               `json:"market_id"`
+	Resolution string            `json:"resolution"`
+	Candles    []internal.Candle `json:"candles"`
+}
+
+// GET /api/markets/{symbol}/candles?resolution=1m&limit=500&from=&to=
+// from/to are unix seconds. The first request for a market backfills
+// from REST and starts its trade stream; after that bars are local.
+func handleMarketCandles(tr *trading, stream *internal.StreamClient, candles *internal.CandleStore, symbol string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		res := q.Get("resolution")
+		if res == "" {
+			res = "1m"
+		}
+		if _, ok := internal.CandleResolutions[res]; !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "resolution must be one of 1m, 5m, 15m, 1h, 4h, 1d"})
+			return
+		}
+
+		limit := defaultCandleLimit
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+				return
+			}
+			if n > maxCandleLimit {
+				n = maxCandleLimit
+			}
+			limit = n
+		}
+
+		var from, to int64
+		for name, dst := range map[string]*int64{"from": &from, "to": &to} {
+			if v := q.Get(name); v != "" {
+				n, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": name + " must be unix seconds"})
+					return
+				}
+				*dst = n
+			}
+		}
+
+		mkt, ok := resolveMarket(w, r, tr, symbol)
+		if !ok {
+			return
+		}
+
+		stream.SubscribeTrades(mkt.MarketID)
+		if err := candles.EnsureBackfilled(r.Context(), mkt.MarketID); err != nil {
+			// serve what we have; the next request retries the backfill
+			log.Printf("candle backfill %s error: %v", mkt.Symbol, err)
+		}
+
+		writeJSON(w, http.StatusOK, candlesResponse{
+			Symbol:     mkt.Symbol,
+			MarketID:   mkt.MarketID,
+			Resolution: res,
+			Candles:    candles.Candles(mkt.MarketID, res, from, to, limit),
+		})
+	}
+}
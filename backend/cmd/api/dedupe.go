@@ -0,0 +1,263 @@
+// backend/cmd/api/dedupe.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+const defaultDedupeWindow = 10 * time.Minute
+
+var (
+	errClientIDReused = errors.New("client_id already used for a different order")
+	errOrderInDoubt   = errors.New("an earlier order with this client_id may have been placed; still confirming")
+)
+
+// dedupeEntry is the first request seen for a client_id. done closes
+// once that request has either placed its order or given up. While
+// its send is in doubt, sent is set and done stays open.
+type dedupeEntry struct {
+	fingerprint string
+	at          time.Time
+	done        chan struct{}
+	resp        *internal.OrderResponse // set before done closes; nil if it failed
+	sent        *sentOrder
+}
+
+func (e *dedupeEntry) settled() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// dedupeRecord is an entry as saved to disk.
+type dedupeRecord struct {
+	Fingerprint string                  `json:"fingerprint"`
+	At          time.Time               `json:"at"`
+	Resp        *internal.OrderResponse `json:"resp,omitempty"`
+	Sent        *sentOrder              `json:"sent,omitempty"`
+	Settled     bool                    `json:"settled"`
+}
+
+// orderDedupe makes /api/trade/order idempotent on client_id: a retry
+// inside the window gets the original response instead of a new order.
+// Entries are saved to path, so a restart keeps them.
+type orderDedupe struct {
+	window time.Duration
+	path   string // "" keeps entries in memory only
+
+	mu      sync.Mutex
+	entries map[string]*dedupeEntry
+}
+
+func newOrderDedupe(window time.Duration, path string) *orderDedupe {
+	return &orderDedupe{
+		window:  window,
+		path:    path,
+		entries: make(map[string]*dedupeEntry),
+	}
+}
+
+// newOrderDedupeFromEnv reads ORDER_DEDUPE_WINDOW (a Go duration) and
+// loads the entries saved at path.
+func newOrderDedupeFromEnv(path string) (*orderDedupe, error) {
+	window := defaultDedupeWindow
+	if v := os.Getenv("ORDER_DEDUPE_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			window = d
+		} else {
+			log.Printf("bad ORDER_DEDUPE_WINDOW %q, using %s", v, window)
+		}
+	}
+	d := newOrderDedupe(window, path)
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// orderFingerprint is the canonical form two requests must share to
+// count as the same order.
+func orderFingerprint(req internal.OrderRequest) string {
+	b, _ := json.Marshal(req)
+	return string(b)
+}
+
+// claim reserves clientID for req. It returns the earlier response for
+// a repeat (waiting if the first attempt is still in flight),
+// errClientIDReused if the payload differs, errOrderInDoubt while the
+// first attempt's outcome is unknown, or (nil, nil) when the caller
+// owns the order and must call settle or hold when done.
+func (d *orderDedupe) claim(ctx context.Context, req internal.OrderRequest) (*internal.OrderResponse, error) {
+	fp := orderFingerprint(req)
+	for {
+		d.mu.Lock()
+		d.pruneLocked(time.Now())
+		e, ok := d.entries[req.ClientID]
+		if !ok {
+			d.entries[req.ClientID] = &dedupeEntry{
+				fingerprint: fp,
+				at:          time.Now(),
+				done:        make(chan struct{}),
+			}
+			d.saveLocked()
+			d.mu.Unlock()
+			return nil, nil
+		}
+		inDoubt := e.sent != nil
+		d.mu.Unlock()
+
+		if e.fingerprint != fp {
+			return nil, errClientIDReused
+		}
+		if inDoubt {
+			return nil, errOrderInDoubt
+		}
+
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if e.resp != nil {
+			return e.resp, nil
+		}
+		// the first attempt failed and released the id; try to take it
+	}
+}
+
+// settle records the outcome for a claimed client_id. A nil resp means
+// nothing was placed, so the id is released for a retry.
+func (d *orderDedupe) settle(clientID string, resp *internal.OrderResponse) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.entries[clientID]
+	if !ok || e.settled() {
+		return
+	}
+	e.resp = resp
+	e.sent = nil
+	if resp == nil {
+		delete(d.entries, clientID)
+	}
+	close(e.done)
+	d.saveLocked()
+}
+
+// hold keeps clientID claimed after a send whose outcome is unknown,
+// until settle is called once the order is found or ruled out.
+func (d *orderDedupe) hold(clientID string, sent *sentOrder) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e, ok := d.entries[clientID]
+	if !ok || e.settled() {
+		return
+	}
+	e.sent = sent
+	d.saveLocked()
+}
+
+// inDoubt lists the held client_ids that can be confirmed, e.g. to
+// resume confirming them after a restart.
+func (d *orderDedupe) inDoubt() map[string]*sentOrder {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	out := make(map[string]*sentOrder)
+	for id, e := range d.entries {
+		if e.sent != nil && len(e.sent.Rows) > 0 {
+			out[id] = e.sent
+		}
+	}
+	return out
+}
+
+// pruneLocked drops finished entries older than the window, and held
+// ones that there's nothing to confirm with.
+func (d *orderDedupe) pruneLocked(now time.Time) {
+	pruned := false
+	for id, e := range d.entries {
+		if now.Sub(e.at) <= d.window {
+			continue
+		}
+		if e.settled() || (e.sent != nil && len(e.sent.Rows) == 0) {
+			delete(d.entries, id)
+			pruned = true
+		}
+	}
+	if pruned {
+		d.saveLocked()
+	}
+}
+
+// ----- persistence -----
+
+// load restores saved entries. One that was still in flight when the
+// process stopped may have been sent; it's held, with nothing to
+// confirm it by, until the window passes.
+func (d *orderDedupe) load() error {
+	if d.path == "" {
+		return nil
+	}
+	b, err := os.ReadFile(d.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read dedupe state: %w", err)
+	}
+	var recs map[string]dedupeRecord
+	if err := json.Unmarshal(b, &recs); err != nil {
+		return fmt.Errorf("decode dedupe state: %w", err)
+	}
+	for id, r := range recs {
+		e := &dedupeEntry{fingerprint: r.Fingerprint, at: r.At, done: make(chan struct{}), resp: r.Resp, sent: r.Sent}
+		switch {
+		case r.Settled:
+			close(e.done)
+		case e.sent == nil:
+			e.sent = &sentOrder{}
+		}
+		d.entries[id] = e
+	}
+	return nil
+}
+
+// saveLocked writes every entry; a failure is logged; the entries in
+// memory still apply.
+func (d *orderDedupe) saveLocked() {
+	if d.path == "" {
+		return
+	}
+	recs := make(map[string]dedupeRecord, len(d.entries))
+	for id, e := range d.entries {
+		recs[id] = dedupeRecord{Fingerprint: e.fingerprint, At: e.at, Resp: e.resp, Sent: e.sent, Settled: e.settled()}
+	}
+	b, err := json.Marshal(recs)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(d.path), 0o755)
+	}
+	if err == nil {
+		tmp := d.path + ".tmp"
+		if err = os.WriteFile(tmp, b, 0o644); err == nil {
+			err = os.Rename(tmp, d.path)
+		}
+	}
+	if err != nil {
+		log.Printf("dedupe save error: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+// backend/cmd/api/dedupe_test.go
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+func dedupeReq(clientID, size string) internal.OrderRequest {
+	sz := internal.MustDecimal(size)
+	return internal.OrderRequest{ClientID: clientID, Symbol: "ETH", Side: "buy", Type: "market", SizeUSD: &sz}
+}
+
+func TestOrderDedupeClaim(t *testing.T) {
+	placed := &internal.OrderResponse{OrderID: "42"}
+	sent := &sentOrder{Rows: []internal.OrderRow{{MarketID: 1}}, Nonce: 7}
+	cases := []struct {
+		name    string
+		prep    func(d *orderDedupe) // run after a first claim on "a" for 100
+		req     internal.OrderRequest
+		want    string // order id of the earlier response; "" means a fresh claim
+		wantErr error
+	}{
+		{"new id", nil, dedupeReq("b", "100"), "", nil},
+		{"repeat gets the response", func(d *orderDedupe) { d.settle("a", placed) }, dedupeReq("a", "100"), "42", nil},
+		{"different payload", func(d *orderDedupe) { d.settle("a", placed) }, dedupeReq("a", "200"), "", errClientIDReused},
+		{"failure releases the id", func(d *orderDedupe) { d.settle("a", nil) }, dedupeReq("a", "100"), "", nil},
+		{"held while in doubt", func(d *orderDedupe) { d.hold("a", sent) }, dedupeReq("a", "100"), "", errOrderInDoubt},
+		{"settled after doubt", func(d *orderDedupe) { d.hold("a", sent); d.settle("a", placed) }, dedupeReq("a", "100"), "42", nil},
+		{"ruled out after doubt", func(d *orderDedupe) { d.hold("a", sent); d.settle("a", nil) }, dedupeReq("a", "100"), "", nil},
+	}
+	for _, c := range cases {
+		d := newOrderDedupe(time.Minute, "")
+		if _, err := d.claim(context.Background(), dedupeReq("a", "100")); err != nil {
+			t.Fatal(err)
+		}
+		if c.prep != nil {
+			c.prep(d)
+		}
+		resp, err := d.claim(context.Background(), c.req)
+		if !errors.Is(err, c.wantErr) {
+			t.Errorf("%s: got error %v, want %v", c.name, err, c.wantErr)
+			continue
+		}
+		got := ""
+		if resp != nil {
+			got = resp.OrderID
+		}
+		if got != c.want {
+			t.Errorf("%s: got response %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestOrderDedupeWaitsForInFlight(t *testing.T) {
+	d := newOrderDedupe(time.Minute, "")
+	if _, err := d.claim(context.Background(), dedupeReq("a", "100")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := d.claim(ctx, dedupeReq("a", "100")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("repeat while in flight: %v", err)
+	}
+
+	go d.settle("a", &internal.OrderResponse{OrderID: "42"})
+	resp, err := d.claim(context.Background(), dedupeReq("a", "100"))
+	if err != nil || resp == nil || resp.OrderID != "42" {
+		t.Fatalf("repeat after settle: %+v, %v", resp, err)
+	}
+}
+
+func TestOrderDedupePersisted(t *testing.T) {
+	sent := &sentOrder{Rows: []internal.OrderRow{{MarketID: 1}}, Nonce: 7}
+	cases := []struct {
+		name    string
+		prep    func(d *orderDedupe) // run after a first claim on "a"
+		want    string
+		wantErr error
+		inDoubt int // entries a restart resumes confirming
+	}{
+		{"settled keeps its response", func(d *orderDedupe) { d.settle("a", &internal.OrderResponse{OrderID: "42"}) }, "42", nil, 0},
+		{"released stays released", func(d *orderDedupe) { d.settle("a", nil) }, "", nil, 0},
+		{"in doubt resumes", func(d *orderDedupe) { d.hold("a", sent) }, "", errOrderInDoubt, 1},
+		{"in flight is held", nil, "", errOrderInDoubt, 0},
+	}
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "dedupe.json")
+		d := newOrderDedupe(time.Minute, path)
+		if _, err := d.claim(context.Background(), dedupeReq("a", "100")); err != nil {
+			t.Fatal(err)
+		}
+		if c.prep != nil {
+			c.prep(d)
+		}
+
+		d = newOrderDedupe(time.Minute, path)
+		if err := d.load(); err != nil {
+			t.Fatalf("%s: load: %v", c.name, err)
+		}
+		if n := len(d.inDoubt()); n != c.inDoubt {
+			t.Errorf("%s: %d in doubt, want %d", c.name, n, c.inDoubt)
+		}
+		resp, err := d.claim(context.Background(), dedupeReq("a", "100"))
+		if !errors.Is(err, c.wantErr) {
+			t.Errorf("%s: got error %v, want %v", c.name, err, c.wantErr)
+			continue
+		}
+		got := ""
+		if resp != nil {
+			got = resp.OrderID
+		}
+		if got != c.want {
+			t.Errorf("%s: got response %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestOrderDedupePrune(t *testing.T) {
+	d := newOrderDedupe(time.Minute, "")
+	old := time.Now().Add(-2 * time.Minute)
+	sent := &sentOrder{Rows: []internal.OrderRow{{MarketID: 1}}}
+	done := make(chan struct{})
+	close(done)
+	d.entries = map[string]*dedupeEntry{
+		"settled":     {at: old, done: done},
+		"recent":      {at: time.Now(), done: done},
+		"in flight":   {at: old, done: make(chan struct{})},
+		"in doubt":    {at: old, done: make(chan struct{}), sent: sent},
+		"unconfirmed": {at: old, done: make(chan struct{}), sent: &sentOrder{}},
+	}
+	d.pruneLocked(time.Now())
+
+	want := map[string]bool{"recent": true, "in flight": true, "in doubt": true}
+	for id := range d.entries {
+		if !want[id] {
+			t.Errorf("%q kept", id)
+		}
+	}
+	for id := range want {
+		if _, ok := d.entries[id]; !ok {
+			t.Errorf("%q pruned", id)
+		}
+	}
+}
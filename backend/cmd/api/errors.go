@@ -0,0 +1,65 @@
+// backend/cmd/api/errors.go
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+// upstreamStatus picks the status we answer with when a Lighter call
+// fails:
+//
+//	circuit open          503 (+ Retry-After)
+//	timed out             504
+//	rate limited          429
+//	bad request/not found 400/404, passed through
+//	code in a 200 body    422
+//	anything else         502
+func upstreamStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	if errors.Is(err, internal.ErrCircuitOpen) {
+		return http.StatusServiceUnavailable
+	}
+	ae, ok := internal.AsAPIError(err)
+	if !ok || ae.Err != nil {
+		return http.StatusBadGateway
+	}
+	switch {
+	case ae.HTTPStatus == http.StatusTooManyRequests:
+		return http.StatusTooManyRequests
+	case ae.HTTPStatus == http.StatusBadRequest, ae.HTTPStatus == http.StatusNotFound:
+		return ae.HTTPStatus
+	case ae.HTTPStatus >= 200 && ae.HTTPStatus < 300:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadGateway
+}
+
+// writeUpstreamError logs err and answers with msg plus whatever Lighter
+// told us about the failure.
+func writeUpstreamError(w http.ResponseWriter, err error, msg string) {
+	log.Printf("%s: %v", msg, err)
+
+	status := upstreamStatus(err)
+	body := map[string]any{"error": msg}
+	if ae, ok := internal.AsAPIError(err); ok {
+		body["retryable"] = ae.Retryable
+		if ae.Code != 0 {
+			body["code"] = ae.Code
+		}
+		if ae.Err == nil && ae.Message != "" {
+			body["message"] = ae.Message
+		}
+		if ae.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(ae.RetryAfter.Seconds()+0.999)))
+		}
+	}
+	writeJSON(w, status, body)
+}
@@ -0,0 +1,321 @@
+// backend/cmd/api/killswitch.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+const (
+	dailyLossCheckInterval = 30 * time.Second
+	killLedgerSeedTimeout  = 10 * time.Second
+)
+
+// requireArmed refuses new orders while the kill switch is tripped.
+func requireArmed(w http.ResponseWriter, tr *trading) bool {
+	if tripped, reason := tr.kill.Tripped(); tripped {
+		writeJSON(w, http.StatusLocked, map[string]string{
+			"error":  "trading halted by kill switch",
+			"reason": reason,
+		})
+		return false
+	}
+	return true
+}
+
+// haltReport is what a trip did, for the API response and the logs.
+type haltReport struct {
+	Tripped           bool                     `json:"tripped"`
+	Cancelled         []string                 `json:"cancelled"`
+	CancelError       string                   `json:"cancel_error,omitempty"`
+	PaperCancelled    []string                 `json:"paper_cancelled"`
+	StoppedStrategies []string                 `json:"stopped_strategies"`
+	Flattened         []flattenResult          `json:"flattened,omitempty"`
+	State             internal.KillSwitchState `json:"state"`
+}
+
+type flattenResult struct {
+	Symbol  string           `json:"symbol"`
+	Side    string           `json:"side"`
+	Size    internal.Decimal `json:"size_contracts"`
+	OrderID string           `json:"order_id,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// halt trips the switch, stops every strategy, then cancels everything,
+// paper orders included, and optionally closes every position. The trip
+// is recorded even if the cleanup fails.
+func (tr *trading) halt(ctx context.Context, source, reason string, flatten bool) haltReport {
+	if _, err := tr.kill.Trip(source, reason); err != nil {
+		log.Printf("kill switch persist error: %v", err)
+	}
+	log.Printf("KILL SWITCH tripped (%s): %s", source, reason)
+
+	rep := haltReport{Tripped: true, Cancelled: []string{}, PaperCancelled: []string{}, StoppedStrategies: []string{}}
+	// strategies first, so none of them places or re-quotes mid cleanup
+	if tr.engine != nil {
+		rep.StoppedStrategies = tr.engine.StopAll()
+	}
+	if tr.paper != nil {
+		rep.PaperCancelled = tr.paper.CancelAll("")
+	}
+	if tr.signer == nil {
+		rep.CancelError = "signer not configured"
+		rep.State = tr.kill.State()
+		return rep
+	}
+
+	cancelled, err := tr.cancelEverything(ctx)
+	if err != nil {
+		log.Printf("kill switch cancel-all error: %v", err)
+		rep.CancelError = err.Error()
+	} else {
+		rep.Cancelled = cancelled
+	}
+
+	if flatten {
+		rep.Flattened = tr.flatten(ctx)
+	}
+	rep.State = tr.kill.State()
+	return rep
+}
+
+// flatten closes each open position with a reduce-only market order.
+// It goes straight to execute: risk checks and the kill switch must not
+// stand in the way of getting flat.
+func (tr *trading) flatten(ctx context.Context) []flattenResult {
+	addr := os.Getenv("LIGHTER_L1_ADDRESS")
+	if addr == "" {
+		return []flattenResult{{Error: "LIGHTER_L1_ADDRESS not set in env"}}
+	}
+	acct, err := tr.lc.AccountByL1(ctx, addr)
+	if err != nil {
+		return []flattenResult{{Error: "failed to fetch account positions: " + err.Error()}}
+	}
+
+	out := []flattenResult{}
+	for _, p := range flattenPositions(acct, nil) {
+		side := "sell"
+		if p.Side == "short" {
+			side = "buy"
+		}
+		size := p.SizeContracts.Abs()
+		res := flattenResult{Symbol: p.Symbol, Side: side, Size: size}
+
+		mkt, err := tr.markets.Lookup(ctx, p.Symbol)
+		if err != nil {
+			res.Error = err.Error()
+			out = append(out, res)
+			continue
+		}
+		placed, err := tr.execute(ctx, internal.OrderRequest{
+			Symbol:        p.Symbol,
+			Side:          side,
+			Type:          "market",
+			SizeContracts: &size,
+			ReduceOnly:    true,
+			ClientID:      "kill-switch-flatten",
+		}, mkt)
+		if err != nil {
+			log.Printf("flatten %s error: %v", p.Symbol, err)
+			res.Error = err.Error()
+		} else {
+			res.OrderID = placed.OrderID
+		}
+		out = append(out, res)
+	}
+	return out
+}
+
+// ----- endpoints -----
+
+// GET /api/killswitch
+func handleKillSwitchState(tr *trading) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, tr.kill.State())
+	}
+}
+
+type killSwitchRequest struct {
+	Reason  string `json:"reason"`
+	Flatten bool   `json:"flatten"`
+	By      string `json:"by"`
+}
+
+func decodeKillSwitchRequest(r *http.Request) (killSwitchRequest, error) {
+	var req killSwitchRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if errors.Is(err, io.EOF) {
+		err = nil // empty body is fine
+	}
+	return req, err
+}
+
+// POST /api/killswitch/trip {"reason": "...", "flatten": true}
+func handleKillSwitchTrip(tr *trading) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		req, err := decodeKillSwitchRequest(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+		if req.Reason == "" {
+			req.Reason = "manual trip"
+		}
+
+		writeJSON(w, http.StatusOK, tr.halt(r.Context(), internal.KillSourceOperator, req.Reason, req.Flatten))
+	}
+}
+
+// POST /api/killswitch/rearm {"by": "..."}
+func handleKillSwitchRearm(tr *trading) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		req, err := decodeKillSwitchRequest(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+		if req.By == "" {
+			req.By = "operator"
+		}
+
+		if err := tr.kill.Rearm(req.By); err != nil {
+			if errors.Is(err, internal.ErrKillSwitchArmed) {
+				writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			log.Printf("kill switch rearm error: %v", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to persist kill switch state"})
+			return
+		}
+		log.Printf("kill switch re-armed by %s", req.By)
+		writeJSON(w, http.StatusOK, tr.kill.State())
+	}
+}
+
+// ----- daily loss limit -----
+
+// runDailyLossMonitor polls the account and trips the switch when PnL
+// since the start of the UTC day falls past -DAILY_LOSS_LIMIT_USD.
+// Realized PnL is booked from our fills as they stream in (see
+// startStream); the poll adds the change in unrealized PnL.
+// DAILY_LOSS_FLATTEN=true also closes positions on a trip.
+func runDailyLossMonitor(ctx context.Context, tr *trading) {
+	var limit internal.Decimal
+	if v := os.Getenv("DAILY_LOSS_LIMIT_USD"); v != "" {
+		d, err := internal.ParseDecimal(v)
+		if err != nil {
+			log.Printf("bad DAILY_LOSS_LIMIT_USD %q: %v", v, err)
+			return
+		}
+		limit = d.Abs()
+	}
+	addr := os.Getenv("LIGHTER_L1_ADDRESS")
+	if limit.IsZero() || addr == "" {
+		log.Printf("daily loss limit disabled")
+		return
+	}
+	flatten, _ := strconv.ParseBool(os.Getenv("DAILY_LOSS_FLATTEN"))
+
+	ticker := time.NewTicker(dailyLossCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		acct, err := tr.lc.AccountByL1(ctx, addr)
+		if err != nil {
+			log.Printf("daily loss: AccountByL1 error: %v", err)
+			continue
+		}
+		if err := tr.kill.SeedPositions(ledgerPositions(acct)); err != nil {
+			log.Printf("daily loss: persist error: %v", err)
+		}
+		dayPnl, err := tr.kill.ObserveDayPnl(time.Now(), unrealizedPnl(acct))
+		if err != nil {
+			log.Printf("daily loss: persist error: %v", err)
+		}
+		if limit.Neg().LessThan(dayPnl) {
+			continue
+		}
+		if tripped, _ := tr.kill.Tripped(); tripped {
+			continue
+		}
+		reason := fmt.Sprintf("daily PnL $%s breached loss limit $%s", dayPnl.StringFixed(2), limit.StringFixed(2))
+		tr.halt(ctx, internal.KillSourceDailyLoss, reason, flatten)
+	}
+}
+
+// seedKillLedger starts the kill switch's ledger from the account, so
+// it's in place before the stream books any fill against it. On failure
+// the daily loss monitor seeds it on its first poll.
+func seedKillLedger(ctx context.Context, tr *trading) {
+	addr := os.Getenv("LIGHTER_L1_ADDRESS")
+	if addr == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, killLedgerSeedTimeout)
+	defer cancel()
+	acct, err := tr.lc.AccountByL1(ctx, addr)
+	if err != nil {
+		log.Printf("kill switch: seed ledger: AccountByL1 error: %v", err)
+		return
+	}
+	if err := tr.kill.SeedPositions(ledgerPositions(acct)); err != nil {
+		log.Printf("kill switch: seed ledger: persist error: %v", err)
+	}
+}
+
+// unrealizedPnl sums the open positions' unrealized PnL. Realized PnL
+// comes from our fills instead: a closed position drops out of the
+// account, and its realized PnL with it.
+func unrealizedPnl(acct *internal.AccountByL1Response) internal.Decimal {
+	var total internal.Decimal
+	for _, a := range acct.Accounts {
+		for _, p := range a.Positions {
+			total = total.Add(p.UnrealizedPnl)
+		}
+	}
+	return total
+}
+
+// ledgerPositions is the account's open positions, signed, for seeding
+// the kill switch's ledger.
+func ledgerPositions(acct *internal.AccountByL1Response) map[string]internal.PnlPosition {
+	out := make(map[string]internal.PnlPosition)
+	for _, a := range acct.Accounts {
+		for _, p := range a.Positions {
+			if p.Position.IsZero() {
+				continue
+			}
+			size := p.Position.Abs()
+			if p.Sign < 0 {
+				size = size.Neg()
+			}
+			out[p.Symbol] = internal.PnlPosition{Size: size, AvgEntry: p.AvgEntryPrice}
+		}
+	}
+	return out
+}
@@ -4,12 +4,12 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
-	"sync"
 	"time"
 
 	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
@@ -20,81 +20,48 @@ import (
 // ---------- Account Types (positions + orders) ----------
 
 type PositionRow struct {
-	Symbol           string  `json:"symbol"`
-	Side             string  `json:"side"` // "long" or "short"
-	SizeUsd          float64 `json:"size_usd"`
-	SizeContracts    float64 `json:"size_contracts"`
-	EntryPrice       float64 `json:"entry_price"`
-	MarkPrice        float64 `json:"mark_price"`
-	Leverage         float64 `json:"leverage"`
-	UnrealizedPnlUsd float64 `json:"unrealized_pnl_usd"`
-	RealizedPnlUsd   float64 `json:"realized_pnl_usd"`
-	MarginUsedUsd    float64 `json:"margin_used_usd"`
+	Symbol           string           `json:"symbol"`
+	Side             string           `json:"side"` // "long" or "short"
+	SizeUsd          internal.Decimal `json:"size_usd"`
+	SizeContracts    internal.Decimal `json:"size_contracts"`
+	EntryPrice       internal.Decimal `json:"entry_price"`
+	MarkPrice        internal.Decimal `json:"mark_price"`
+	Leverage         internal.Decimal `json:"leverage"`
+	UnrealizedPnlUsd internal.Decimal `json:"unrealized_pnl_usd"`
+	RealizedPnlUsd   internal.Decimal `json:"realized_pnl_usd"`
+	MarginUsedUsd    internal.Decimal `json:"margin_used_usd"`
 }
 
 type AccountSummary struct {
-	AccountID          string  `json:"account_id"`
-	BalanceUsd         float64 `json:"balance_usd"`          // sum of collateral across all subaccts
-	EquityUsd          float64 `json:"equity_usd"`           // balance + unrealized (for now same)
-	UnrealizedPnlUsd   float64 `json:"unrealized_pnl_usd"`   // TODO: aggregate from positions
-	RealizedPnlUsd     float64 `json:"realized_pnl_usd"`     // TODO: from PnL history
-	MarginUsedUsd      float64 `json:"margin_used_usd"`      // ∑ allocated_margin (later)
-	MarginAvailableUsd float64 `json:"margin_available_usd"` // equity - margin_used
-	EffectiveLeverage  float64 `json:"effective_leverage"`   // equity / margin_used
-	Sharpe30d          float64 `json:"sharpe_30d"`           // placeholder
+	AccountID          string           `json:"account_id"`
+	BalanceUsd         internal.Decimal `json:"balance_usd"`          // sum of collateral across all subaccts
+	EquityUsd          internal.Decimal `json:"equity_usd"`           // balance + unrealized (for now same)
+	UnrealizedPnlUsd   internal.Decimal `json:"unrealized_pnl_usd"`   // TODO: aggregate from positions
+	RealizedPnlUsd     internal.Decimal `json:"realized_pnl_usd"`     // TODO: from PnL history
+	MarginUsedUsd      internal.Decimal `json:"margin_used_usd"`      // ∑ allocated_margin (later)
+	MarginAvailableUsd internal.Decimal `json:"margin_available_usd"` // equity - margin_used
+	EffectiveLeverage  internal.Decimal `json:"effective_leverage"`   // equity / margin_used
+	Sharpe30d          float64          `json:"sharpe_30d"`           // placeholder
 }
 
-type OrderRow struct {
-	OrderID        string  `json:"order_id"`
-	Symbol         string  `json:"symbol"`
-	Side           string  `json:"side"`   // buy / sell
-	Type           string  `json:"type"`   // market / limit
-	Status         string  `json:"status"` // open / filled / cancelled
-	Price          float64 `json:"price,omitempty"`
-	SizeUsd        float64 `json:"size_usd,omitempty"`
-	SizeContracts  float64 `json:"size_contracts,omitempty"`
-	Leverage       float64 `json:"leverage"`
-	ReduceOnly     bool    `json:"reduce_only"`
-	ClientID       string  `json:"client_id,omitempty"`
-	CreatedAtEpoch int64   `json:"created_at_epoch"`
-}
-
-// in-memory order log to drive "Working & Recent Orders" UI
-var (
-	orderLogMu sync.Mutex
-	orderLog   []OrderRow
-)
+// orders, status changes and fills; opened in main
+var journal internal.Journal
 
 // ---------- Market Types ----------
 
-type MarketRow struct {
-	Symbol       string  `json:"symbol"`
-	MarketID     int     `json:"market_id"`
-	Status       string  `json:"status"`
-	TakerFee     string  `json:"taker_fee"`
-	MakerFee     string  `json:"maker_fee"`
-	OpenInterest float64 `json:"open_interest"`
-
-	IndexPrice   float64 `json:"index_price"`
-	MarkPrice    float64 `json:"mark_price"`
-	Change24hPct float64 `json:"change_24h_pct"`
-
-	OpenInterestUsd float64 `json:"open_interest_usd"`
-	Volume24hUsd    float64 `json:"volume_24h_usd"`
-	FundingRate8h   float64 `json:"funding_rate_8h"`
-}
+// market rows (internal.MarketRow) are shared with the market hub
 
 type lighterMarketsResponse struct {
-	Code             int         `json:"code"`
-	OrderBookDetails []MarketRow `json:"order_book_details"`
+	Code             int                  `json:"code"`
+	OrderBookDetails []internal.MarketRow `json:"order_book_details"`
 }
 
 type exchangeStat struct {
-	Symbol                string  `json:"symbol"`
-	LastTradePrice        float64 `json:"last_trade_price"`
-	DailyPriceChange      float64 `json:"daily_price_change"`
-	DailyBaseTokenVolume  float64 `json:"daily_base_token_volume"`
-	DailyQuoteTokenVolume float64 `json:"daily_quote_token_volume"`
+	Symbol                string           `json:"symbol"`
+	LastTradePrice        internal.Decimal `json:"last_trade_price"`
+	DailyPriceChange      internal.Decimal `json:"daily_price_change"`
+	DailyBaseTokenVolume  internal.Decimal `json:"daily_base_token_volume"`
+	DailyQuoteTokenVolume internal.Decimal `json:"daily_quote_token_volume"`
 }
 
 type exchangeStatsResponse struct {
@@ -103,40 +70,9 @@ type exchangeStatsResponse struct {
 	OrderBookStats []exchangeStat `json:"order_book_stats"`
 }
 
-type fundingRateRow struct {
-	MarketID int     `json:"market_id"`
-	Exchange string  `json:"exchange"`
-	Symbol   string  `json:"symbol"`
-	Rate     float64 `json:"rate"`
-}
-
 type fundingRatesResponse struct {
-	Code         int              `json:"code"`
-	FundingRates []fundingRateRow `json:"funding_rates"`
-}
-
-// ---------- Trade / Order Types ----------
-
-type OrderRequest struct {
-	Symbol        string   `json:"symbol"`
-	Side          string   `json:"side"` // "buy" | "sell"
-	Type          string   `json:"type"` // "market" | "limit"
-	Price         *float64 `json:"price,omitempty"`
-	SizeUSD       *float64 `json:"size_usd,omitempty"`
-	SizeContracts *float64 `json:"size_contracts,omitempty"`
-	Leverage      float64  `json:"leverage"`
-	ReduceOnly    bool     `json:"reduce_only"`
-	ClientID      string   `json:"client_id"`
-
-	StopLoss   *float64 `json:"stop_loss,omitempty"`
-	TakeProfit *float64 `json:"take_profit,omitempty"`
-}
-
-type OrderResponse struct {
-	OrderID string       `json:"order_id"`
-	Status  string       `json:"status"`
-	Message string       `json:"message,omitempty"`
-	Request OrderRequest `json:"request"`
+	Code         int                    `json:"code"`
+	FundingRates []internal.FundingRate `json:"funding_rates"`
 }
 
 // ---------- Helpers ----------
@@ -149,6 +85,15 @@ func loadEnv() {
 	}
 }
 
+// dataPath resolves a file under DATA_DIR (default ./data).
+func dataPath(name string) string {
+	dir := os.Getenv("DATA_DIR")
+	if dir == "" {
+		dir = "data"
+	}
+	return filepath.Join(dir, name)
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -163,64 +108,101 @@ var upgrader = websocket.Upgrader{
 
 // --- exchange stats (prices, 24h %, volume) ---
 
-func fetchStatsMap(ctx context.Context, lc *internal.LighterClient) (map[string]exchangeStat, error) {
-	raw, err := lc.ExchangeStats(ctx)
+func fetchStatsMap(ctx context.Context, lc *internal.LighterClient) (map[string]exchangeStat, internal.CachedResponse, error) {
+	cr, err := lc.ExchangeStatsCached(ctx)
 	if err != nil {
-		return nil, err
+		return nil, cr, err
 	}
 
 	var resp exchangeStatsResponse
-	if err := json.Unmarshal(raw, &resp); err != nil {
-		return nil, err
+	if err := json.Unmarshal(cr.Raw, &resp); err != nil {
+		return nil, cr, err
 	}
 
 	stats := make(map[string]exchangeStat, len(resp.OrderBookStats))
 	for _, st := range resp.OrderBookStats {
 		stats[st.Symbol] = st
 	}
-	return stats, nil
+	return stats, cr, nil
 }
 
 // --- funding rates ---
 
-func fetchFundingMap(ctx context.Context, lc *internal.LighterClient) (map[string]float64, error) {
-	raw, err := lc.FundingRates(ctx)
+// fetchFundingRates is every exchange's rate for every symbol.
+func fetchFundingRates(ctx context.Context, lc *internal.LighterClient) ([]internal.FundingRate, internal.CachedResponse, error) {
+	cr, err := lc.FundingRatesCached(ctx)
 	if err != nil {
-		return nil, err
+		return nil, cr, err
 	}
 
 	var resp fundingRatesResponse
-	if err := json.Unmarshal(raw, &resp); err != nil {
-		return nil, err
+	if err := json.Unmarshal(cr.Raw, &resp); err != nil {
+		return nil, cr, err
 	}
+	return resp.FundingRates, cr, nil
+}
+
+// fetchFundingMap is the same rates keyed by symbol, then exchange.
+func fetchFundingMap(ctx context.Context, lc *internal.LighterClient) (internal.FundingTable, internal.CachedResponse, error) {
+	rates, cr, err := fetchFundingRates(ctx, lc)
+	if err != nil {
+		return nil, cr, err
+	}
+	return internal.NewFundingTable(rates), cr, nil
+}
+
+// marketsMeta says how fresh a merged market list is: AsOf is the
+// oldest of the responses it was built from.
+type marketsMeta struct {
+	AsOf  time.Time
+	Stale bool
+}
+
+func (m *marketsMeta) include(cr internal.CachedResponse) {
+	if m.AsOf.IsZero() || cr.FetchedAt.Before(m.AsOf) {
+		m.AsOf = cr.FetchedAt
+	}
+	m.Stale = m.Stale || cr.Stale
+}
 
-	m := make(map[string]float64, len(resp.FundingRates))
-	for _, fr := range resp.FundingRates {
-		m[fr.Symbol] = fr.Rate
+// setDataAge reports snapshot age in headers so the JSON body keeps
+// its shape.
+func setDataAge(w http.ResponseWriter, meta marketsMeta) {
+	w.Header().Set("X-Data-As-Of", strconv.FormatInt(meta.AsOf.UnixMilli(), 10))
+	w.Header().Set("X-Data-Age-Ms", strconv.FormatInt(time.Since(meta.AsOf).Milliseconds(), 10))
+	if meta.Stale {
+		w.Header().Set("X-Data-Stale", "true")
 	}
-	return m, nil
 }
 
 // Combine orderBookDetails + exchangeStats + funding into []MarketRow.
-func loadMarketsMerged(ctx context.Context, lc *internal.LighterClient) ([]MarketRow, error) {
-	rawDetails, err := lc.OrderBookDetails(ctx)
+// All three come through the client's cache, so this is cheap to call.
+func loadMarketsMerged(ctx context.Context, lc *internal.LighterClient) ([]internal.MarketRow, marketsMeta, error) {
+	var meta marketsMeta
+
+	cr, err := lc.OrderBookDetailsCached(ctx)
 	if err != nil {
-		return nil, err
+		return nil, meta, err
 	}
+	meta.include(cr)
 
 	var details lighterMarketsResponse
-	if err := json.Unmarshal(rawDetails, &details); err != nil {
-		return nil, err
+	if err := json.Unmarshal(cr.Raw, &details); err != nil {
+		return nil, meta, err
 	}
 
-	statsMap, err := fetchStatsMap(ctx, lc)
+	statsMap, cr, err := fetchStatsMap(ctx, lc)
 	if err != nil {
 		log.Printf("fetchStatsMap error: %v", err)
+	} else {
+		meta.include(cr)
 	}
 
-	fundingMap, err := fetchFundingMap(ctx, lc)
+	fundingMap, cr, err := fetchFundingMap(ctx, lc)
 	if err != nil {
 		log.Printf("fetchFundingMap error: %v", err)
+	} else {
+		meta.include(cr)
 	}
 
 	rows := details.OrderBookDetails
@@ -238,117 +220,265 @@ func loadMarketsMerged(ctx context.Context, lc *internal.LighterClient) ([]Marke
 			}
 		}
 
-		px := m.MarkPrice
-		if px == 0 {
-			px = m.IndexPrice
-		}
-		if px != 0 {
-			m.OpenInterestUsd = m.OpenInterest * px
-		} else {
-			m.OpenInterestUsd = 0
-		}
+		m.OpenInterestUsd = m.OpenInterest.Mul(m.RefPrice())
 
 		if fundingMap != nil {
-			if rate, ok := fundingMap[m.Symbol]; ok {
+			if rate, ok := fundingMap.Rate(internal.ExchangeLighter, m.Symbol); ok {
 				m.FundingRate8h = rate
 			}
+			m.FundingRates = fundingMap[m.Symbol]
+		}
+	}
+
+	return rows, meta, nil
+}
+
+// summarizeAccount rolls collateral and margin up over all subaccounts.
+func summarizeAccount(addr string, resp *internal.AccountByL1Response) AccountSummary {
+	var (
+		totalCollateral internal.Decimal
+		totalMarginUsed internal.Decimal
+	)
+
+	for _, acct := range resp.Accounts {
+		totalCollateral = totalCollateral.Add(acct.Collateral)
+		for _, p := range acct.Positions {
+			totalMarginUsed = totalMarginUsed.Add(p.AllocatedMargin)
 		}
 	}
 
-	return rows, nil
+	balance := totalCollateral
+	equity := totalCollateral // until we add unrealized PnL on top
+	marginUsed := totalMarginUsed
+	marginAvail := equity.Sub(marginUsed)
+	effLev, _ := equity.Div(marginUsed) // 0 with no margin in use
+
+	summary := AccountSummary{
+		AccountID:          addr,
+		BalanceUsd:         balance,
+		EquityUsd:          equity,
+		MarginUsedUsd:      marginUsed,
+		MarginAvailableUsd: marginAvail,
+		EffectiveLeverage:  effLev,
+		Sharpe30d:          0,
+	}
+
+	return summary
 }
 
-// ----- /api/trade/order handler (stubbed execution) -----
+// flattenPositions turns every non-zero position across subaccounts
+// into a PositionRow, marked at the merged market price when we have it.
+func flattenPositions(accountResp *internal.AccountByL1Response, markets []internal.MarketRow) []PositionRow {
+	priceMap := make(map[string]internal.Decimal)
+	for _, m := range markets {
+		if px := m.RefPrice(); !px.IsZero() {
+			priceMap[m.Symbol] = px
+		}
+	}
+
+	hundred := internal.NewDecimalFromInt(100)
+	var out []PositionRow
+
+	for _, acct := range accountResp.Accounts {
+		for _, p := range acct.Positions {
+			qty := p.Position
+			if qty.IsZero() {
+				continue
+			}
+
+			lev, _ := hundred.Div(p.InitialMarginFraction) // 0 if unknown
+
+			px, ok := priceMap[p.Symbol]
+			if !ok && !p.PositionValue.IsZero() {
+				px, _ = p.PositionValue.Div(qty) // qty is non-zero here
+				px = px.Abs()
+			}
+
+			side := "long"
+			if p.Sign < 0 {
+				side = "short"
+			}
+
+			out = append(out, PositionRow{
+				Symbol:           p.Symbol,
+				Side:             side,
+				SizeUsd:          p.PositionValue.Abs(),
+				SizeContracts:    qty,
+				EntryPrice:       p.AvgEntryPrice,
+				MarkPrice:        px,
+				Leverage:         lev,
+				UnrealizedPnlUsd: p.UnrealizedPnl,
+				RealizedPnlUsd:   p.RealizedPnl,
+				MarginUsedUsd:    p.AllocatedMargin,
+			})
+		}
+	}
 
-func handleTradeOrder(lc *internal.LighterClient) http.HandlerFunc {
+	return out
+}
+
+// ----- /api/trade/order handler -----
+
+func handleTradeOrder(tr *trading) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 			return
 		}
 
-		var req OrderRequest
+		var req internal.OrderRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			log.Printf("order decode error: %v", err)
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
 			return
 		}
 
-		// TODO: wire lc.PlaceOrder once we implement signing
-
-		if req.Symbol == "" {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "symbol is required"})
-			return
-		}
-		if req.Side != "buy" && req.Side != "sell" {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "side must be 'buy' or 'sell'"})
+		if err := validateOrder(req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		if req.Type != "market" && req.Type != "limit" {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "type must be 'market' or 'limit'"})
+
+		if tr.modeOf(req) == internal.ModeLive && (!requireSigner(w, tr) || !requireArmed(w, tr)) {
 			return
 		}
-		if req.Type == "limit" {
-			if req.Price == nil || *req.Price <= 0 {
-				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit orders require positive price"})
+
+		// a retried client_id gets the original response, not a new order
+		var (
+			placedResp *internal.OrderResponse
+			held       bool
+		)
+		if req.ClientID != "" {
+			prev, err := tr.dedupe.claim(r.Context(), req)
+			if errors.Is(err, errClientIDReused) || errors.Is(err, errOrderInDoubt) {
+				writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusRequestTimeout, map[string]string{"error": err.Error()})
 				return
 			}
+			if prev != nil {
+				writeJSON(w, http.StatusOK, prev)
+				return
+			}
+			defer func() {
+				if !held {
+					tr.dedupe.settle(req.ClientID, placedResp)
+				}
+			}()
 		}
-		if (req.SizeUSD == nil || *req.SizeUSD <= 0) &&
-			(req.SizeContracts == nil || *req.SizeContracts <= 0) {
-			writeJSON(w, http.StatusBadRequest, map[string]string{
-				"error": "size_usd or size_contracts must be > 0",
-			})
+
+		resp, err := tr.placeChecked(r.Context(), req)
+		if err != nil {
+			// the order may exist: keep the client_id until it's found or
+			// can no longer land
+			var doubt *orderInDoubtError
+			if errors.As(err, &doubt) {
+				if req.ClientID != "" {
+					tr.dedupe.hold(req.ClientID, doubt.sent)
+					held = true
+				}
+				go tr.confirmSent(req.ClientID, doubt.sent)
+			}
+			writeOrderError(w, err)
 			return
 		}
 
-		log.Printf("NEW ORDER (stub): %+v", req)
-
-		devOrderID := fmt.Sprintf("dev-%d", time.Now().UnixNano())
-		now := time.Now().Unix()
+		placedResp = resp
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
 
-		resp := OrderResponse{
-			OrderID: devOrderID,
-			Status:  "accepted",
-			Message: "stubbed order (not sent to exchange yet)",
-			Request: req,
+// validateOrder checks field shapes; market/grid checks come later.
+func validateOrder(req internal.OrderRequest) error {
+	if req.Symbol == "" {
+		return errors.New("symbol is required")
+	}
+	if req.Mode != "" && req.Mode != internal.ModeLive && req.Mode != internal.ModePaper {
+		return errors.New("mode must be 'live' or 'paper'")
+	}
+	if req.Side != "buy" && req.Side != "sell" {
+		return errors.New("side must be 'buy' or 'sell'")
+	}
+	if req.Type != "market" && req.Type != "limit" {
+		return errors.New("type must be 'market' or 'limit'")
+	}
+	if req.Type == "limit" {
+		if req.Price == nil || !req.Price.IsPositive() {
+			return errors.New("limit orders require positive price")
 		}
+	}
+	if (req.SizeUSD == nil || !req.SizeUSD.IsPositive()) &&
+		(req.SizeContracts == nil || !req.SizeContracts.IsPositive()) {
+		return errors.New("size_usd or size_contracts must be > 0")
+	}
+	if req.StopLoss != nil && !req.StopLoss.IsPositive() {
+		return errors.New("stop_loss must be > 0")
+	}
+	if req.TakeProfit != nil && !req.TakeProfit.IsPositive() {
+		return errors.New("take_profit must be > 0")
+	}
+	if (req.StopLoss != nil || req.TakeProfit != nil) && req.ReduceOnly {
+		return errors.New("stop_loss/take_profit can't be attached to a reduce-only order")
+	}
+	return nil
+}
 
-		// add to in-memory order log so the UI can see "Working & Recent Orders"
-		orderLogMu.Lock()
-		orderLog = append(orderLog, OrderRow{
-			OrderID: devOrderID,
-			Symbol:  req.Symbol,
-			Side:    req.Side,
-			Type:    req.Type,
-			Status:  "open",
-			Price: func() float64 {
-				if req.Price != nil {
-					return *req.Price
-				}
-				return 0
-			}(),
-			SizeUsd: func() float64 {
-				if req.SizeUSD != nil {
-					return *req.SizeUSD
-				}
-				return 0
-			}(),
-			SizeContracts: func() float64 {
-				if req.SizeContracts != nil {
-					return *req.SizeContracts
-				}
-				return 0
-			}(),
-			Leverage:       req.Leverage,
-			ReduceOnly:     req.ReduceOnly,
-			ClientID:       req.ClientID,
-			CreatedAtEpoch: now,
+// writeOrderError maps grid errors to 400, refusals before sending to
+// their own status, sends with an unknown outcome to 504, and exchange
+// rejections to 422 with the Lighter code; anything else is an
+// upstream failure.
+func writeOrderError(w http.ResponseWriter, err error) {
+	var (
+		bad    badOrderError
+		halted haltedError
+		lookup marketLookupError
+		risk   *internal.RiskRejection
+		doubt  *orderInDoubtError
+	)
+	switch {
+	case errors.As(err, &doubt):
+		log.Printf("order in doubt: %v", err)
+		writeJSON(w, http.StatusGatewayTimeout, map[string]string{
+			"error": "order outcome unknown; retry with the same client_id for the result",
+		})
+		return
+	case errors.As(err, &bad):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": bad.Error()})
+		return
+	case errors.Is(err, errTradingDisabled):
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	case errors.As(err, &halted):
+		writeJSON(w, http.StatusLocked, map[string]string{
+			"error":  "trading halted by kill switch",
+			"reason": halted.reason,
 		})
-		orderLogMu.Unlock()
+		return
+	case errors.As(err, &risk):
+		writeRiskError(w, err)
+		return
+	case errors.As(err, &lookup):
+		writeUpstreamError(w, lookup.error, "failed to fetch market details")
+		return
+	case errors.Is(err, internal.ErrPaperRejected):
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{
+			"error":   "order rejected by paper account",
+			"message": err.Error(),
+		})
+		return
+	}
 
-		writeJSON(w, http.StatusOK, resp)
+	if rej, ok := internal.AsAPIError(err); ok && rej.Err == nil && !rej.Retryable {
+		log.Printf("order rejected: %v", rej)
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":   "order rejected by exchange",
+			"code":    rej.Code,
+			"message": rej.Message,
+		})
+		return
 	}
+	writeUpstreamError(w, err, "failed to send order")
 }
 
 // ---------- main / handlers ----------
@@ -357,6 +487,124 @@ func main() {
 	loadEnv()
 
 	lc := internal.NewLighterClientFromEnv()
+
+	retentionDays := 30
+	if v := os.Getenv("JOURNAL_RETENTION_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			retentionDays = n
+		}
+	}
+	j, err := internal.OpenFileJournal(dataPath("journal.jsonl"), time.Duration(retentionDays)*24*time.Hour)
+	if err != nil {
+		log.Fatalf("open journal: %v", err)
+	}
+	defer j.Close()
+	journal = j
+
+	kill, err := internal.OpenKillSwitch(dataPath("killswitch.json"))
+	if err != nil {
+		log.Fatalf("open kill switch: %v", err)
+	}
+	if tripped, reason := kill.Tripped(); tripped {
+		log.Printf("kill switch is tripped (%s); orders blocked until re-armed", reason)
+	}
+
+	dedupe, err := newOrderDedupeFromEnv(dataPath("dedupe.json"))
+	if err != nil {
+		log.Fatalf("open order dedupe: %v", err)
+	}
+
+	tr := &trading{
+		lc:      lc,
+		markets: internal.NewMarketRegistry(lc),
+		grid:    internal.GridModeFromEnv(),
+		dedupe:  dedupe,
+		risk:    internal.NewRiskEngineFromConfig(internal.RiskConfigFromEnv()),
+		kill:    kill,
+		mode:    internal.TradingModeFromEnv(),
+	}
+	// without a signer the API still serves data, but orders are refused
+	if signer, err := internal.NewSignerFromEnv(); err != nil {
+		log.Printf("signer not configured, trading disabled: %v", err)
+	} else {
+		tr.signer = signer
+		tr.nonces = internal.NewNonceManager(lc, signer.AccountIndex(), signer.APIKeyIndex(), dataPath("nonce.state"))
+		// sends left in doubt by the last run
+		for clientID, sent := range dedupe.inDoubt() {
+			go tr.confirmSent(clientID, sent)
+		}
+	}
+	// one upstream poll shared by every /ws/markets client
+	hub := internal.NewMarketHub(func(ctx context.Context) (internal.MarketSnapshot, error) {
+		rows, meta, err := loadMarketsMerged(ctx, lc)
+		if err != nil {
+			return internal.MarketSnapshot{}, err
+		}
+		// cached alongside the merge, so this costs nothing extra
+		funding, _, err := fetchFundingRates(ctx, lc)
+		if err != nil {
+			log.Printf("fetchFundingRates error: %v", err)
+		}
+		return internal.MarketSnapshot{Markets: rows, Funding: funding, At: meta.AsOf, Stale: meta.Stale}, nil
+	}, 2*time.Second, 0)
+	go hub.Run(context.Background())
+
+	// bars built from the trade stream, saved under DATA_DIR/candles
+	candles, err := internal.OpenCandleStore(dataPath("candles"), lc)
+	if err != nil {
+		log.Fatalf("open candle store: %v", err)
+	}
+	go candles.Run(context.Background())
+
+	// strategies trade through the same checks as /api/trade/order
+	engine := internal.NewEngineFromEnv(hub, strategyRouter{tr})
+	for _, s := range internal.BuiltinStrategies() {
+		if err := engine.Register(s); err != nil {
+			log.Fatalf("register strategy: %v", err)
+		}
+	}
+	tr.engine = engine
+	go engine.Run(context.Background())
+
+	// RECORD_MARKET_DATA keeps markets, trades and funding under
+	// DATA_DIR/recordings for cmd/backtest
+	var recorder *internal.Recorder
+	if on, _ := strconv.ParseBool(os.Getenv("RECORD_MARKET_DATA")); on {
+		recorder, err = internal.OpenRecorder(dataPath("recordings"), tr.markets.All)
+		if err != nil {
+			log.Fatalf("open recorder: %v", err)
+		}
+	}
+
+	// the kill switch's ledger must be in place before fills stream in
+	seedKillLedger(context.Background(), tr)
+
+	// upstream websocket: public trades, plus our fills when signing
+	stream := startStream(context.Background(), tr, candles, engine, recorder)
+	if recorder != nil {
+		// trades for every recorded market, not just those with candles
+		recorder.OnSpecs(func(specs []internal.MarketSpec) {
+			for _, m := range specs {
+				stream.SubscribeTrades(m.MarketID)
+			}
+		})
+		go recorder.Run(context.Background(), hub)
+	}
+	// order books are subscribed on demand and dropped when idle
+	books := newBookSubsFromEnv(stream)
+	go books.Run(context.Background())
+
+	// virtual account filled against the live books; paper fills reach
+	// strategies like real ones
+	paper, err := internal.OpenPaperExecutor(dataPath("paper.json"), paperFeed{books, hub}, tr.grid, internal.PaperStartingBalanceFromEnv())
+	if err != nil {
+		log.Fatalf("open paper account: %v", err)
+	}
+	paper.OnFill(engine.NotifyFill)
+	tr.paper = paper
+	go paper.Run(context.Background(), internal.PaperMatchIntervalFromEnv())
+	log.Printf("trading mode: %s", tr.mode)
+
 	mux := http.NewServeMux()
 
 	// health
@@ -366,71 +614,61 @@ func main() {
 
 	// simple status
 	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		tripped, _ := kill.Tripped()
+		cacheAgeMs := map[string]int64{}
+		for k, age := range lc.CacheAges() {
+			cacheAgeMs[k] = age.Milliseconds()
+		}
 		writeJSON(w, http.StatusOK, map[string]any{
-			"network_id": 1,
-			"status":     200,
-			"timestamp":  time.Now().Unix(),
+			"network_id":   1,
+			"status":       200,
+			"timestamp":    time.Now().Unix(),
+			"kill_switch":  tripped,
+			"stream":       stream.Connected(),
+			"cache_age_ms": cacheAgeMs,
+			"upstream":     lc.UpstreamStatus(),
 		})
 	})
 
 	// ----- markets -----
 	mux.HandleFunc("/api/markets", func(w http.ResponseWriter, r *http.Request) {
-		rows, err := loadMarketsMerged(r.Context(), lc)
+		rows, meta, err := loadMarketsMerged(r.Context(), lc)
 		if err != nil {
-			log.Printf("/api/markets error: %v", err)
-			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			writeUpstreamError(w, err, "failed to fetch markets")
 			return
 		}
+		setDataAge(w, meta)
 		writeJSON(w, http.StatusOK, rows)
 	})
 
 	mux.HandleFunc("/api/markets/live", func(w http.ResponseWriter, r *http.Request) {
-		rows, err := loadMarketsMerged(r.Context(), lc)
+		rows, meta, err := loadMarketsMerged(r.Context(), lc)
 		if err != nil {
-			log.Printf("/api/markets/live error: %v", err)
-			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			writeUpstreamError(w, err, "failed to fetch markets")
 			return
 		}
+		setDataAge(w, meta)
 		writeJSON(w, http.StatusOK, rows)
 	})
 
-	// ----- ws markets -----
-	mux.HandleFunc("/ws/markets", func(w http.ResponseWriter, r *http.Request) {
-		conn, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			log.Printf("ws upgrade error: %v", err)
-			return
-		}
-		defer conn.Close()
-
-		ctx := r.Context()
-		ticker := time.NewTicker(2 * time.Second) // slightly slower to avoid 429s
-		defer ticker.Stop()
+	// ----- order books -----
+	mux.HandleFunc("/api/markets/", handleMarketPath(tr, books, stream, candles))
+	mux.HandleFunc("/ws/book", handleBookWS(tr, books))
 
-		type WSMessage struct {
-			Markets []MarketRow `json:"markets"`
-		}
+	// ----- ws markets -----
+	mux.HandleFunc("/ws/markets", handleMarketsWS(hub))
 
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				rows, err := loadMarketsMerged(ctx, lc)
-				if err != nil {
-					log.Printf("ws loadMarketsMerged error: %v", err)
-					return
-				}
-				if err := conn.WriteJSON(WSMessage{Markets: rows}); err != nil {
-					log.Printf("ws write error: %v", err)
-					return
-				}
+	// ----- REAL /api/account/summary from /account (?mode=paper: virtual) -----
+	mux.HandleFunc("/api/account/summary", func(w http.ResponseWriter, r *http.Request) {
+		if accountMode(r, tr) == internal.ModePaper {
+			markets, _, err := loadMarketsMerged(r.Context(), lc)
+			if err != nil {
+				log.Printf("loadMarketsMerged error in paper summary: %v", err)
 			}
+			writeJSON(w, http.StatusOK, paperSummary(tr.paper.Account(), markets))
+			return
 		}
-	})
 
-	// ----- REAL /api/account/summary from /account -----
-	mux.HandleFunc("/api/account/summary", func(w http.ResponseWriter, r *http.Request) {
 		addr := os.Getenv("LIGHTER_L1_ADDRESS")
 		if addr == "" {
 			writeJSON(w, http.StatusBadRequest, map[string]string{
@@ -441,61 +679,28 @@ func main() {
 
 		resp, err := lc.AccountByL1(r.Context(), addr)
 		if err != nil {
-			log.Printf("AccountByL1 error: %v", err)
-			writeJSON(w, http.StatusBadGateway, map[string]string{
-				"error": "failed to fetch accounts",
-			})
+			writeUpstreamError(w, err, "failed to fetch accounts")
 			return
 		}
 
-		var (
-			totalCollateral float64
-			totalMarginUsed float64
-		)
-
-		for _, acct := range resp.Accounts {
-			if acct.Collateral != "" {
-				if f, err := strconv.ParseFloat(acct.Collateral, 64); err == nil {
-					totalCollateral += f
-				}
-			}
-			for _, p := range acct.Positions {
-				if p.AllocatedMargin != "" {
-					if f, err := strconv.ParseFloat(p.AllocatedMargin, 64); err == nil {
-						totalMarginUsed += f
-					}
-				}
-			}
-		}
-
-		balance := totalCollateral
-		equity := totalCollateral // until we add unrealized PnL on top
-		marginUsed := totalMarginUsed
-		marginAvail := equity - marginUsed
-		var effLev float64
-		if marginUsed > 0 {
-			effLev = equity / marginUsed
-		} else {
-			effLev = 0
-		}
-
-		summary := AccountSummary{
-			AccountID:          addr,
-			BalanceUsd:         balance,
-			EquityUsd:          equity,
-			UnrealizedPnlUsd:   0,
-			RealizedPnlUsd:     0,
-			MarginUsedUsd:      marginUsed,
-			MarginAvailableUsd: marginAvail,
-			EffectiveLeverage:  effLev,
-			Sharpe30d:          0,
-		}
+		summary := summarizeAccount(addr, resp)
 
 		writeJSON(w, http.StatusOK, summary)
 	})
 
 	// ----- /api/account/positions : flatten positions over all subaccts -----
 	mux.HandleFunc("/api/account/positions", func(w http.ResponseWriter, r *http.Request) {
+		if accountMode(r, tr) == internal.ModePaper {
+			markets, _, err := loadMarketsMerged(r.Context(), lc)
+			if err != nil {
+				log.Printf("loadMarketsMerged error in paper positions: %v", err)
+			}
+			writeJSON(w, http.StatusOK, map[string]any{
+				"positions": paperPositions(tr.paper.Account(), markets),
+			})
+			return
+		}
+
 		addr := os.Getenv("LIGHTER_L1_ADDRESS")
 		if addr == "" {
 			writeJSON(w, http.StatusBadRequest, map[string]string{
@@ -506,103 +711,59 @@ func main() {
 
 		accountResp, err := lc.AccountByL1(r.Context(), addr)
 		if err != nil {
-			log.Printf("AccountByL1 error: %v", err)
-			writeJSON(w, http.StatusBadGateway, map[string]string{
-				"error": "failed to fetch account positions",
-			})
+			writeUpstreamError(w, err, "failed to fetch account positions")
 			return
 		}
 
 		// Map symbol -> mark price from merged markets
-		markets, err := loadMarketsMerged(r.Context(), lc)
+		markets, _, err := loadMarketsMerged(r.Context(), lc)
 		if err != nil {
 			log.Printf("loadMarketsMerged error in positions: %v", err)
 		}
-		priceMap := make(map[string]float64)
-		for _, m := range markets {
-			px := m.MarkPrice
-			if px == 0 {
-				px = m.IndexPrice
-			}
-			if px != 0 {
-				priceMap[m.Symbol] = px
-			}
-		}
-
-		var out []PositionRow
-
-		for _, acct := range accountResp.Accounts {
-			for _, p := range acct.Positions {
-				qty, _ := strconv.ParseFloat(p.Position, 64)
-				if qty == 0 {
-					continue
-				}
-
-				posVal, _ := strconv.ParseFloat(p.PositionValue, 64)
-				entry, _ := strconv.ParseFloat(p.AvgEntryPrice, 64)
-				upnl, _ := strconv.ParseFloat(p.UnrealizedPnl, 64)
-				rpnl, _ := strconv.ParseFloat(p.RealizedPnl, 64)
-				allocMargin, _ := strconv.ParseFloat(p.AllocatedMargin, 64)
-				imf, _ := strconv.ParseFloat(p.InitialMarginFraction, 64)
-
-				lev := 0.0
-				if imf > 0 {
-					lev = 100.0 / imf
-				}
-
-				px := priceMap[p.Symbol]
-				if px == 0 && qty != 0 {
-					if posVal != 0 {
-						px = posVal / qty
-					}
-				}
-
-				side := "long"
-				if p.Sign < 0 {
-					side = "short"
-				}
-
-				sizeUsd := posVal
-				if sizeUsd < 0 {
-					sizeUsd = -sizeUsd
-				}
-
-				out = append(out, PositionRow{
-					Symbol:           p.Symbol,
-					Side:             side,
-					SizeUsd:          sizeUsd,
-					SizeContracts:    qty,
-					EntryPrice:       entry,
-					MarkPrice:        px,
-					Leverage:         lev,
-					UnrealizedPnlUsd: upnl,
-					RealizedPnlUsd:   rpnl,
-					MarginUsedUsd:    allocMargin,
-				})
-			}
-		}
+		out := flattenPositions(accountResp, markets)
 
 		writeJSON(w, http.StatusOK, map[string]any{
 			"positions": out,
 		})
 	})
 
-	// ----- /api/account/orders : returns local log for now -----
-	mux.HandleFunc("/api/account/orders", func(w http.ResponseWriter, r *http.Request) {
-		orderLogMu.Lock()
-		defer orderLogMu.Unlock()
+	// ----- /api/account/orders : paged view of the order journal -----
+	mux.HandleFunc("/api/account/orders", handleAccountOrders(tr))
 
-		// copy to avoid race if UI mutates accidentally
-		out := make([]OrderRow, len(orderLog))
-		copy(out, orderLog)
+	// ----- paper account -----
+	mux.HandleFunc("/api/account/paper", handlePaperAccount(tr, hub))
+	mux.HandleFunc("/api/account/paper/reset", handlePaperReset(tr))
 
-		writeJSON(w, http.StatusOK, map[string]any{
-			"orders": out,
-		})
+	// ----- trade order -----
+	placeOrder := handleTradeOrder(tr)
+	cancelByClientID := handleCancelByClientID(tr)
+	mux.HandleFunc("/api/trade/order", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			cancelByClientID(w, r)
+			return
+		}
+		placeOrder(w, r)
+	})
+	cancelOrder := handleCancelOrder(tr)
+	modifyOrder := handleModifyOrder(tr)
+	mux.HandleFunc("/api/trade/order/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			modifyOrder(w, r)
+			return
+		}
+		cancelOrder(w, r)
 	})
+	mux.HandleFunc("/api/trade/cancel-all", handleCancelAll(tr))
 
-	// ----- trade order -----
-	mux.HandleFunc("/api/trade/order", handleTradeOrder(lc))
+	// ----- kill switch -----
+	mux.HandleFunc("/api/killswitch", handleKillSwitchState(tr))
+	mux.HandleFunc("/api/killswitch/trip", handleKillSwitchTrip(tr))
+	mux.HandleFunc("/api/killswitch/rearm", handleKillSwitchRearm(tr))
+	go runDailyLossMonitor(context.Background(), tr)
+
+	// ----- strategies -----
+	mux.HandleFunc("/api/strategies", handleStrategies(engine))
+	mux.HandleFunc("/api/strategies/", handleStrategyPath(engine))
 
 	handler := withCORS(mux)
 
@@ -0,0 +1,503 @@
+// backend/cmd/api/orders.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+// ----- order log helpers -----
+
+// appendOrders journals new orders. The exchange already has them, so a
+// write failure is logged rather than failing the request.
+func appendOrders(rows ...internal.OrderRow) {
+	for _, o := range rows {
+		if err := journal.RecordOrder(o); err != nil {
+			log.Printf("journal record %s error: %v", o.OrderID, err)
+		}
+	}
+}
+
+// findOrders returns copies of the journaled orders that match.
+func findOrders(match func(internal.OrderRow) bool) []internal.OrderRow {
+	return journal.FindOrders(match)
+}
+
+// updateOrder applies fn to the journaled order with this id.
+func updateOrder(orderID string, fn func(*internal.OrderRow)) {
+	if err := journal.UpdateOrder(orderID, fn); err != nil {
+		log.Printf("journal update %s error: %v", orderID, err)
+	}
+}
+
+func setOrderStatus(orderID, status string) {
+	updateOrder(orderID, func(o *internal.OrderRow) { o.Status = status })
+}
+
+// isWorking is true for orders that can still fill: resting, or a
+// bracket leg waiting on its parent.
+func isWorking(status string) bool {
+	return status == "open" || status == "pending"
+}
+
+// openOrderFilter matches working orders, optionally on one symbol.
+func openOrderFilter(symbol string) func(internal.OrderRow) bool {
+	return func(o internal.OrderRow) bool {
+		return isWorking(o.Status) && (symbol == "" || o.Symbol == symbol)
+	}
+}
+
+func childOrders(parentID string) []internal.OrderRow {
+	return findOrders(func(o internal.OrderRow) bool {
+		return o.ParentOrderID == parentID && isWorking(o.Status)
+	})
+}
+
+// markOrderFilled marks an order filled and applies the bracket rules the
+// exchange enforces: a filled parent arms its legs, and a filled leg
+// cancels its sibling (OCO).
+func markOrderFilled(orderID string) {
+	rows := findOrders(func(o internal.OrderRow) bool { return o.OrderID == orderID })
+	if len(rows) == 0 {
+		return
+	}
+	setOrderStatus(orderID, "filled")
+
+	row := rows[0]
+	if row.ParentOrderID == "" {
+		for _, c := range childOrders(row.OrderID) {
+			setOrderStatus(c.OrderID, "open")
+		}
+		return
+	}
+	for _, sib := range childOrders(row.ParentOrderID) {
+		setOrderStatus(sib.OrderID, "cancelled")
+	}
+}
+
+// cancelChildren cancels every working leg of a cancelled parent,
+// pending ones included: the exchange only drops those with the parent
+// when none of it filled. A leg whose cancel fails stays working unless
+// the exchange no longer has it active.
+func cancelChildren(ctx context.Context, tr *trading, parentID string) {
+	for _, c := range childOrders(parentID) {
+		if err := tr.cancelOrder(ctx, c); err != nil && !tr.orderGone(ctx, c) {
+			log.Printf("cancel child %s error: %v", c.OrderID, err)
+			continue
+		}
+		setOrderStatus(c.OrderID, "cancelled")
+	}
+}
+
+// resizeChildren keeps a bracket's legs the size of their parent after
+// a modify. Pending legs close whatever the parent filled, so only the
+// journal changes; armed ones are modified on the exchange too.
+func resizeChildren(ctx context.Context, tr *trading, parentID string, mkt internal.MarketSpec, contracts internal.Decimal, base int64) {
+	for _, c := range childOrders(parentID) {
+		if c.Status == "open" {
+			if err := resizeLeg(ctx, tr, c, mkt, base); err != nil {
+				log.Printf("resize child %s error: %v", c.OrderID, err)
+				continue
+			}
+		}
+		updateOrder(c.OrderID, func(o *internal.OrderRow) { o.SizeContracts = contracts })
+	}
+}
+
+// resizeLeg modifies an armed leg to base, keeping its price and trigger.
+func resizeLeg(ctx context.Context, tr *trading, leg internal.OrderRow, mkt internal.MarketSpec, base int64) error {
+	// both were stored from ticks, so they are on the grid
+	px, err := mkt.PriceTicks(leg.Price, internal.GridReject, false)
+	if err != nil {
+		return err
+	}
+	trigger, err := mkt.PriceTicks(leg.TriggerPrice, internal.GridReject, false)
+	if err != nil {
+		return err
+	}
+	_, err = tr.modifyOrder(ctx, leg, px, trigger, base)
+	return err
+}
+
+// ----- order journal view -----
+
+const (
+	defaultOrdersPageSize = 100
+	maxOrdersPageSize     = 500
+)
+
+// GET /api/account/orders?symbol=&status=&from=&to=&limit=&offset=&mode=
+// from/to are epoch seconds on created_at_epoch. Newest first.
+func handleAccountOrders(tr *trading) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+		q := internal.OrderQuery{
+			Symbol: qs.Get("symbol"),
+			Status: qs.Get("status"),
+			Limit:  defaultOrdersPageSize,
+		}
+
+		ints := []struct {
+			name string
+			dst  *int64
+		}{{"from", &q.From}, {"to", &q.To}}
+		for _, p := range ints {
+			if v := qs.Get(p.name); v != "" {
+				n, err := strconv.ParseInt(v, 10, 64)
+				if err != nil || n < 0 {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": p.name + " must be epoch seconds"})
+					return
+				}
+				*p.dst = n
+			}
+		}
+		if v := qs.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be > 0"})
+				return
+			}
+			if n > maxOrdersPageSize {
+				n = maxOrdersPageSize
+			}
+			q.Limit = n
+		}
+		if v := qs.Get("offset"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "offset must be >= 0"})
+				return
+			}
+			q.Offset = n
+		}
+
+		if accountMode(r, tr) == internal.ModePaper {
+			orders, total := paperOrders(tr.paper.Account(), q)
+			writeJSON(w, http.StatusOK, map[string]any{
+				"orders": orders,
+				"total":  total,
+				"limit":  q.Limit,
+				"offset": q.Offset,
+			})
+			return
+		}
+
+		orders, total := journal.Orders(q)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"orders": orders,
+			"total":  total,
+			"limit":  q.Limit,
+			"offset": q.Offset,
+		})
+	}
+}
+
+// ----- cancel endpoints -----
+
+type cancelFailure struct {
+	OrderID string `json:"order_id"`
+	Error   string `json:"error"`
+}
+
+// cancelRows cancels each row one tx at a time and updates the log.
+func cancelRows(r *http.Request, tr *trading, rows []internal.OrderRow) ([]string, []cancelFailure) {
+	cancelled := []string{}
+	failed := []cancelFailure{}
+	for _, row := range rows {
+		if err := tr.cancelOrder(r.Context(), row); err != nil {
+			log.Printf("cancel %s error: %v", row.OrderID, err)
+			failed = append(failed, cancelFailure{OrderID: row.OrderID, Error: err.Error()})
+			continue
+		}
+		setOrderStatus(row.OrderID, "cancelled")
+		cancelChildren(r.Context(), tr, row.OrderID)
+		cancelled = append(cancelled, row.OrderID)
+	}
+	return cancelled, failed
+}
+
+func requireSigner(w http.ResponseWriter, tr *trading) bool {
+	if tr.signer == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "trading disabled: signer not configured",
+		})
+		return false
+	}
+	return true
+}
+
+// openOrderByPath resolves /api/trade/order/{order_id}?symbol= to an
+// open order, writing 404/409 if there isn't one.
+func openOrderByPath(w http.ResponseWriter, r *http.Request) (internal.OrderRow, bool) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/trade/order/")
+	if id == "" || strings.Contains(id, "/") {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "order id is required"})
+		return internal.OrderRow{}, false
+	}
+	symbol := r.URL.Query().Get("symbol")
+
+	rows := findOrders(func(o internal.OrderRow) bool {
+		return o.OrderID == id && (symbol == "" || o.Symbol == symbol)
+	})
+	if len(rows) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "order not found"})
+		return internal.OrderRow{}, false
+	}
+	if !isWorking(rows[0].Status) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "order is " + rows[0].Status})
+		return internal.OrderRow{}, false
+	}
+	return rows[0], true
+}
+
+// DELETE /api/trade/order/{order_id}?symbol=
+func handleCancelOrder(tr *trading) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		if id := strings.TrimPrefix(r.URL.Path, "/api/trade/order/"); internal.IsPaperOrderID(id) {
+			cancelPaperOrder(w, r, tr, id)
+			return
+		}
+
+		row, ok := openOrderByPath(w, r)
+		if !ok {
+			return
+		}
+		if !requireSigner(w, tr) {
+			return
+		}
+
+		if err := tr.cancelOrder(r.Context(), row); err != nil {
+			writeOrderError(w, err)
+			return
+		}
+		setOrderStatus(row.OrderID, "cancelled")
+		cancelChildren(r.Context(), tr, row.OrderID)
+
+		writeJSON(w, http.StatusOK, map[string]string{
+			"order_id": row.OrderID,
+			"status":   "cancelled",
+		})
+	}
+}
+
+// cancelPaperOrder is DELETE /api/trade/order/{id} for a paper order.
+func cancelPaperOrder(w http.ResponseWriter, r *http.Request, tr *trading, id string) {
+	err := tr.paper.Cancel(r.Context(), id)
+	switch {
+	case errors.Is(err, internal.ErrPaperOrderNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "order not found"})
+		return
+	case errors.Is(err, internal.ErrPaperOrderClosed):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "order is not open"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"order_id": id,
+		"status":   "cancelled",
+	})
+}
+
+// DELETE /api/trade/order?client_id=...&symbol=
+func handleCancelByClientID(tr *trading) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clientID := r.URL.Query().Get("client_id")
+		if clientID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "client_id is required"})
+			return
+		}
+		open := openOrderFilter(r.URL.Query().Get("symbol"))
+
+		// legs share the parent's client_id and are cancelled with it
+		rows := findOrders(func(o internal.OrderRow) bool {
+			return o.ClientID == clientID && open(o) && o.ParentOrderID == ""
+		})
+		if len(rows) == 0 {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no open orders for client_id"})
+			return
+		}
+
+		if !requireSigner(w, tr) {
+			return
+		}
+
+		cancelled, failed := cancelRows(r, tr, rows)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"cancelled": cancelled,
+			"failed":    failed,
+		})
+	}
+}
+
+// POST /api/trade/cancel-all?symbol=&mode=
+//
+// Without a symbol this is one cancel-all tx for the whole account.
+// With a symbol, each open order on that market is cancelled in turn.
+// mode=paper cancels resting paper orders instead.
+func handleCancelAll(tr *trading) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		if accountMode(r, tr) == internal.ModePaper {
+			writeJSON(w, http.StatusOK, map[string]any{
+				"cancelled": tr.paper.CancelAll(r.URL.Query().Get("symbol")),
+				"failed":    []cancelFailure{},
+			})
+			return
+		}
+		if !requireSigner(w, tr) {
+			return
+		}
+
+		symbol := r.URL.Query().Get("symbol")
+		if symbol != "" {
+			// legs go with their parent; only send cancels for top-level orders
+			open := openOrderFilter(symbol)
+			rows := findOrders(func(o internal.OrderRow) bool {
+				return open(o) && o.ParentOrderID == ""
+			})
+			cancelled, failed := cancelRows(r, tr, rows)
+			writeJSON(w, http.StatusOK, map[string]any{
+				"cancelled": cancelled,
+				"failed":    failed,
+			})
+			return
+		}
+
+		cancelled, err := tr.cancelEverything(r.Context())
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"cancelled": cancelled,
+			"failed":    []cancelFailure{},
+		})
+	}
+}
+
+// ----- modify endpoint -----
+
+// ModifyOrderRequest carries the new price and/or size. Omitted fields
+// keep the order's current value.
+type ModifyOrderRequest struct {
+	Price         *internal.Decimal `json:"price,omitempty"`
+	SizeUSD       *internal.Decimal `json:"size_usd,omitempty"`
+	SizeContracts *internal.Decimal `json:"size_contracts,omitempty"`
+}
+
+// PATCH /api/trade/order/{order_id}?symbol=
+func handleModifyOrder(tr *trading) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var mod ModifyOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&mod); err != nil {
+			log.Printf("modify decode error: %v", err)
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+		if internal.IsPaperOrderID(strings.TrimPrefix(r.URL.Path, "/api/trade/order/")) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "paper orders can't be modified; cancel and re-place"})
+			return
+		}
+		if mod.Price == nil && mod.SizeUSD == nil && mod.SizeContracts == nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "price, size_usd or size_contracts is required",
+			})
+			return
+		}
+
+		row, ok := openOrderByPath(w, r)
+		if !ok {
+			return
+		}
+		if row.Type != "limit" || row.Status != "open" {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "only resting limit orders can be modified"})
+			return
+		}
+
+		// rebuild the order as it would look if placed fresh, then run the
+		// same validation as /api/trade/order
+		price, contracts := row.Price, row.SizeContracts
+		req := internal.OrderRequest{
+			Symbol:        row.Symbol,
+			Side:          row.Side,
+			Type:          row.Type,
+			Price:         &price,
+			SizeContracts: &contracts,
+			Leverage:      row.Leverage,
+			ReduceOnly:    row.ReduceOnly,
+			ClientID:      row.ClientID,
+		}
+		if mod.Price != nil {
+			req.Price = mod.Price
+		}
+		if mod.SizeUSD != nil {
+			req.SizeUSD = mod.SizeUSD
+			req.SizeContracts = nil
+		}
+		if mod.SizeContracts != nil {
+			req.SizeContracts = mod.SizeContracts
+			req.SizeUSD = nil
+		}
+		if err := validateOrder(req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if !requireSigner(w, tr) || !requireArmed(w, tr) {
+			return
+		}
+		mkt, ok := resolveMarket(w, r, tr, req.Symbol)
+		if !ok {
+			return
+		}
+		if err := tr.risk.Check(tr.riskContext(r.Context(), req, row.OrderID)); err != nil {
+			writeRiskError(w, err)
+			return
+		}
+		px, base, err := mkt.ScaleOrder(req, tr.grid)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		placed, err := tr.modifyOrder(r.Context(), row, px, 0, base)
+		if err != nil {
+			writeOrderError(w, err)
+			return
+		}
+
+		newPrice := internal.NewDecimalScaled(int64(px), mkt.PriceDecimals)
+		newContracts := internal.NewDecimalScaled(base, mkt.SizeDecimals)
+		updateOrder(row.OrderID, func(o *internal.OrderRow) {
+			o.Price = newPrice
+			o.SizeContracts = newContracts
+			o.SizeUsd = newPrice.Mul(newContracts)
+		})
+		if !newContracts.Equal(row.SizeContracts) {
+			resizeChildren(r.Context(), tr, row.OrderID, mkt, newContracts, base)
+		}
+
+		writeJSON(w, http.StatusOK, internal.OrderResponse{
+			OrderID:          row.OrderID,
+			OrderIndex:       row.OrderIndex,
+			TxHash:           placed.TxHash,
+			ClientOrderIndex: row.ClientOrderIndex,
+			Status:           "open",
+			Message:          placed.Message,
+			Request:          req,
+		})
+	}
+}
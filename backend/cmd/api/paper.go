@@ -0,0 +1,214 @@
+// backend/cmd/api/paper.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+// paperFeed serves the paper account live books from the stream and
+// fees/marks from the market hub.
+type paperFeed struct {
+	books *bookSubs
+	hub   *internal.MarketHub
+}
+
+func (f paperFeed) Book(ctx context.Context, marketID int) (internal.BookSnapshot, error) {
+	book, err := f.books.get(ctx, marketID)
+	if err != nil {
+		return internal.BookSnapshot{}, err
+	}
+	return book.Snapshot(0), nil
+}
+
+func (f paperFeed) Market(symbol string) (internal.MarketRow, bool) {
+	snap, ok := f.hub.Latest()
+	if !ok {
+		return internal.MarketRow{}, false
+	}
+	for _, m := range snap.Markets {
+		if m.Symbol == symbol {
+			return m, true
+		}
+	}
+	return internal.MarketRow{}, false
+}
+
+// accountMode is ?mode= on the /api/account endpoints, defaulting to
+// TRADING_MODE.
+func accountMode(r *http.Request, tr *trading) string {
+	switch m := r.URL.Query().Get("mode"); m {
+	case internal.ModeLive, internal.ModePaper:
+		return m
+	}
+	return tr.mode
+}
+
+func markPrices(markets []internal.MarketRow) map[string]internal.Decimal {
+	marks := make(map[string]internal.Decimal, len(markets))
+	for _, m := range markets {
+		if px := m.RefPrice(); !px.IsZero() {
+			marks[m.Symbol] = px
+		}
+	}
+	return marks
+}
+
+// paperPositions marks the virtual positions like flattenPositions does
+// the real ones; without a mark a position is valued at entry.
+func paperPositions(acct internal.PaperAccount, markets []internal.MarketRow) []PositionRow {
+	marks := markPrices(markets)
+	one := internal.NewDecimalFromInt(1)
+	out := []PositionRow{}
+	for _, p := range acct.SortedPositions() {
+		px, ok := marks[p.Symbol]
+		if !ok {
+			px = p.AvgEntry
+		}
+		lev := p.Leverage
+		if !lev.IsPositive() {
+			lev = one
+		}
+		side := "long"
+		if p.Size.Sign() < 0 {
+			side = "short"
+		}
+		value := p.Size.Abs().Mul(px)
+		margin, _ := value.Div(lev) // lev is at least 1
+		out = append(out, PositionRow{
+			Symbol:           p.Symbol,
+			Side:             side,
+			SizeUsd:          value,
+			SizeContracts:    p.Size,
+			EntryPrice:       p.AvgEntry,
+			MarkPrice:        px,
+			Leverage:         lev,
+			UnrealizedPnlUsd: px.Sub(p.AvgEntry).Mul(p.Size),
+			RealizedPnlUsd:   p.RealizedPnl,
+			MarginUsedUsd:    margin,
+		})
+	}
+	return out
+}
+
+// paperSummary is summarizeAccount for the virtual account.
+func paperSummary(acct internal.PaperAccount, markets []internal.MarketRow) AccountSummary {
+	var unrealized, marginUsed internal.Decimal
+	for _, p := range paperPositions(acct, markets) {
+		unrealized = unrealized.Add(p.UnrealizedPnlUsd)
+		marginUsed = marginUsed.Add(p.MarginUsedUsd)
+	}
+	equity := acct.Balance.Add(unrealized)
+	effLev, _ := equity.Div(marginUsed) // 0 with no margin in use
+	return AccountSummary{
+		AccountID:          internal.ModePaper,
+		BalanceUsd:         acct.Balance,
+		EquityUsd:          equity,
+		UnrealizedPnlUsd:   unrealized,
+		RealizedPnlUsd:     acct.RealizedPnl,
+		MarginUsedUsd:      marginUsed,
+		MarginAvailableUsd: equity.Sub(marginUsed),
+		EffectiveLeverage:  effLev,
+	}
+}
+
+// paperRiskContext fills exposure, open orders and margin from the
+// virtual account instead of Lighter.
+func paperRiskContext(rc *internal.RiskContext, acct internal.PaperAccount, markets []internal.MarketRow, excludeOrderID string) {
+	for _, o := range acct.OpenOrders() {
+		if o.OrderID == excludeOrderID {
+			continue
+		}
+		rc.OpenOrders++
+		if o.Symbol == rc.Order.Symbol && !o.ReduceOnly {
+			rc.SymbolExposureUsd = rc.SymbolExposureUsd.Add(o.Price.Mul(o.Size.Sub(o.Filled)))
+		}
+	}
+	for _, p := range paperPositions(acct, markets) {
+		if p.Symbol == rc.Order.Symbol {
+			rc.SymbolExposureUsd = rc.SymbolExposureUsd.Add(p.SizeUsd)
+		}
+	}
+	rc.MarginKnown = true
+	rc.MarginAvailableUsd = paperSummary(acct, markets).MarginAvailableUsd
+}
+
+// paperOrders applies an OrderQuery to the paper orders, newest first.
+func paperOrders(acct internal.PaperAccount, q internal.OrderQuery) ([]internal.PaperOrder, int) {
+	var match []internal.PaperOrder
+	for _, o := range acct.Orders {
+		if (q.Symbol != "" && o.Symbol != q.Symbol) || (q.Status != "" && o.Status != q.Status) {
+			continue
+		}
+		if (q.From > 0 && o.CreatedAtEpoch < q.From) || (q.To > 0 && o.CreatedAtEpoch > q.To) {
+			continue
+		}
+		match = append(match, o)
+	}
+	sort.SliceStable(match, func(i, j int) bool { return match[i].CreatedAtEpoch > match[j].CreatedAtEpoch })
+
+	total := len(match)
+	if q.Offset >= total {
+		return []internal.PaperOrder{}, total
+	}
+	match = match[q.Offset:]
+	if q.Limit > 0 && len(match) > q.Limit {
+		match = match[:q.Limit]
+	}
+	return match, total
+}
+
+// GET /api/account/paper : the whole virtual account, marked to market
+func handlePaperAccount(tr *trading, hub *internal.MarketHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		var markets []internal.MarketRow
+		if snap, ok := hub.Latest(); ok {
+			markets = snap.Markets
+		}
+		acct := tr.paper.Account()
+		writeJSON(w, http.StatusOK, map[string]any{
+			"summary":   paperSummary(acct, markets),
+			"positions": paperPositions(acct, markets),
+			"orders":    acct.OpenOrders(),
+			"fills":     acct.Fills,
+			"fees_paid": acct.FeesPaid,
+			"starting":  acct.StartingBalance,
+		})
+	}
+}
+
+// POST /api/account/paper/reset  {"balance": 10000} (optional)
+func handlePaperReset(tr *trading) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		var body struct {
+			Balance internal.Decimal `json:"balance"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+				return
+			}
+		}
+		if body.Balance.Sign() < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "balance must be > 0"})
+			return
+		}
+		if err := tr.paper.Reset(body.Balance); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, paperSummary(tr.paper.Account(), nil))
+	}
+}
@@ -0,0 +1,128 @@
+// backend/cmd/api/strategies.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+const maxStrategyConfigBytes = 64 << 10
+
+// strategyRouter sends strategy orders down the same path as
+// /api/trade/order, and cancels them with whichever executor placed
+// them.
+type strategyRouter struct{ tr *trading }
+
+// PlaceOrder confirms a send in doubt in the background, as the HTTP
+// path does, and hands the outcome to the engine.
+func (s strategyRouter) PlaceOrder(ctx context.Context, req internal.OrderRequest) (*internal.OrderResponse, error) {
+	resp, err := s.tr.placeChecked(ctx, req)
+	var doubt *orderInDoubtError
+	if errors.As(err, &doubt) {
+		resolved := make(chan *internal.OrderResponse, 1)
+		go func() { resolved <- s.tr.confirmSent(req.ClientID, doubt.sent) }()
+		return nil, &internal.OrderPendingError{Err: err, Resolved: resolved}
+	}
+	return resp, err
+}
+
+func (s strategyRouter) CancelOrder(ctx context.Context, orderID string) error {
+	if internal.IsPaperOrderID(orderID) {
+		return s.tr.paper.Cancel(ctx, orderID)
+	}
+	return liveExecutor{s.tr}.Cancel(ctx, orderID)
+}
+
+func (s strategyRouter) MarketSpec(ctx context.Context, symbol string) (internal.MarketSpec, error) {
+	return s.tr.markets.Lookup(ctx, symbol)
+}
+
+// GET /api/strategies
+func handleStrategies(engine *internal.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"strategies": engine.Statuses()})
+	}
+}
+
+// GET  /api/strategies/{name}
+// POST /api/strategies/{name}/start   (optional JSON config body)
+// POST /api/strategies/{name}/stop
+func handleStrategyPath(engine *internal.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/strategies/"), "/"), "/")
+		if parts[0] == "" || len(parts) > 2 {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+		name := parts[0]
+
+		if len(parts) == 1 {
+			if r.Method != http.MethodGet {
+				writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+				return
+			}
+			st, err := engine.Status(name)
+			if err != nil {
+				writeStrategyError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, st)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		var err error
+		switch parts[1] {
+		case "start":
+			var cfg []byte
+			cfg, err = io.ReadAll(io.LimitReader(r.Body, maxStrategyConfigBytes))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "failed to read body"})
+				return
+			}
+			if len(strings.TrimSpace(string(cfg))) > 0 && !json.Valid(cfg) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+				return
+			}
+			err = engine.Start(name, json.RawMessage(strings.TrimSpace(string(cfg))))
+		case "stop":
+			err = engine.Stop(name)
+		default:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+		if err != nil {
+			writeStrategyError(w, err)
+			return
+		}
+
+		st, _ := engine.Status(name)
+		writeJSON(w, http.StatusOK, st)
+	}
+}
+
+// writeStrategyError: unknown 404, wrong state 409, anything else is the
+// strategy refusing its config or failing to start.
+func writeStrategyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, internal.ErrUnknownStrategy):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, internal.ErrStrategyRunning), errors.Is(err, internal.ErrStrategyStopped):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
+}
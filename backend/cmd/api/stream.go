@@ -0,0 +1,105 @@
+// backend/cmd/api/stream.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+// startStream connects the upstream websocket. With a signer configured
+// it follows our account so fills land in the journal, and reach the
+// kill switch's PnL ledger and the strategy engine, as they happen.
+// Public trades feed the candle store for every market it tracks, and
+// the recorder when one is running.
+func startStream(ctx context.Context, tr *trading, candles *internal.CandleStore, engine *internal.Engine, recorder *internal.Recorder) *internal.StreamClient {
+	sc := internal.NewStreamClientFromEnv(internal.StreamHandlers{
+		OnTrades: func(marketID int, trades []internal.StreamTrade) {
+			for _, t := range trades {
+				candles.AddTrade(marketID, t.Price, t.Size, t.Timestamp)
+				if recorder != nil {
+					recorder.RecordTrade(t)
+				}
+			}
+		},
+		OnAccount: func(accountID int64, _ json.RawMessage, trades []internal.StreamTrade) {
+			for _, t := range trades {
+				if fill, ok := recordStreamFill(accountID, t); ok {
+					if err := tr.kill.ObserveFill(fill); err != nil {
+						log.Printf("kill switch fill persist error: %v", err)
+					}
+					engine.NotifyFill(fill)
+				}
+			}
+		},
+	})
+	if tr.signer != nil {
+		sc.SubscribeAccount(tr.signer.AccountIndex())
+	}
+	for _, id := range candles.Markets() {
+		sc.SubscribeTrades(id)
+	}
+	go sc.Run(ctx)
+	return sc
+}
+
+// recordStreamFill matches a trade to our journaled order by market and
+// order index (or client order index, for rows whose order index isn't
+// known yet), records it once, and marks the order filled when the
+// fills cover its size. ok is false for trades it skipped.
+func recordStreamFill(accountID int64, t internal.StreamTrade) (internal.Fill, bool) {
+	side, orderIndex, clientIndex := "buy", t.BidID, t.BidClientID
+	if t.AskAccountID == accountID {
+		side, orderIndex, clientIndex = "sell", t.AskID, t.AskClientID
+	} else if t.BidAccountID != accountID {
+		return internal.Fill{}, false
+	}
+
+	rows := findOrders(func(o internal.OrderRow) bool {
+		if o.MarketID != t.MarketID || o.Side != side {
+			return false
+		}
+		if o.OrderIndex != 0 {
+			return o.OrderIndex == orderIndex
+		}
+		return clientIndex != 0 && o.ClientOrderIndex == clientIndex
+	})
+	if len(rows) == 0 {
+		return internal.Fill{}, false // placed elsewhere (UI, another bot)
+	}
+	row := rows[0]
+
+	tradeID := strconv.FormatInt(t.TradeID, 10)
+	var filled internal.Decimal
+	for _, f := range journal.Fills(row.OrderID) {
+		if f.TradeID == tradeID {
+			return internal.Fill{}, false // replayed on resubscribe
+		}
+		filled = filled.Add(f.Size)
+	}
+
+	fill := internal.Fill{
+		OrderID:   row.OrderID,
+		TradeID:   tradeID,
+		Symbol:    row.Symbol,
+		Side:      side,
+		Price:     t.Price,
+		Size:      t.Size,
+		TimeEpoch: t.Timestamp / 1000,
+	}
+	if err := journal.RecordFill(fill); err != nil {
+		log.Printf("journal fill %s error: %v", row.OrderID, err)
+		return internal.Fill{}, false
+	}
+	filled = filled.Add(fill.Size)
+
+	// market orders sit in "submitted" until we hear about them
+	working := isWorking(row.Status) || row.Status == "submitted"
+	if working && row.SizeContracts.IsPositive() && !filled.LessThan(row.SizeContracts) {
+		markOrderFilled(row.OrderID)
+	}
+	return fill, true
+}
@@ -0,0 +1,915 @@
+// backend/cmd/api/trade.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+var decimalOne = internal.NewDecimalFromInt(1)
+
+// trading bundles what the order endpoints need to reach the exchange.
+// signer and nonces are nil when no API key is configured.
+type trading struct {
+	lc      *internal.LighterClient
+	markets *internal.MarketRegistry
+	grid    internal.GridMode // off-grid prices/sizes: round or reject
+	signer  *internal.Signer
+	nonces  *internal.NonceManager
+	dedupe  *orderDedupe
+	risk    *internal.RiskEngine
+	kill    *internal.KillSwitch
+
+	mode  string // TRADING_MODE: where orders without a mode go
+	paper *internal.PaperExecutor
+
+	engine *internal.Engine // stopped by a kill switch trip
+}
+
+// badOrderError is an order that can't be expressed on the market's
+// price/size grid; handlers answer it with 400.
+type badOrderError struct{ error }
+
+var errTradingDisabled = errors.New("trading disabled: signer not configured")
+
+// haltedError is an order refused because the kill switch is tripped.
+type haltedError struct{ reason string }
+
+func (e haltedError) Error() string { return "trading halted by kill switch: " + e.reason }
+
+// marketLookupError is a failure to load market details, as opposed to
+// a failure sending the order.
+type marketLookupError struct{ error }
+
+func (e marketLookupError) Unwrap() error { return e.error }
+
+// placeChecked is the /api/trade/order path without the HTTP: shape
+// validation, executor choice, signer and kill switch (live only),
+// market lookup and grid, risk checks, then execute. Strategies place
+// orders through it too.
+func (tr *trading) placeChecked(ctx context.Context, req internal.OrderRequest) (*internal.OrderResponse, error) {
+	if err := validateOrder(req); err != nil {
+		return nil, badOrderError{err}
+	}
+	req.Mode = tr.modeOf(req)
+	ex := tr.executor(req.Mode)
+	if req.Mode == internal.ModeLive {
+		if tr.signer == nil {
+			return nil, errTradingDisabled
+		}
+		if tripped, reason := tr.kill.Tripped(); tripped {
+			return nil, haltedError{reason}
+		}
+	}
+
+	mkt, err := tr.markets.Lookup(ctx, req.Symbol)
+	if errors.Is(err, internal.ErrUnknownMarket) {
+		return nil, badOrderError{fmt.Errorf("unknown symbol %s", req.Symbol)}
+	}
+	if err != nil {
+		return nil, marketLookupError{err}
+	}
+	if _, _, err := mkt.ScaleOrder(req, tr.grid); err != nil {
+		return nil, badOrderError{err}
+	}
+
+	if err := tr.risk.Check(tr.riskContext(ctx, req, "")); err != nil {
+		return nil, err
+	}
+	return ex.Execute(ctx, req, mkt)
+}
+
+// modeOf is req's mode, or TRADING_MODE when it doesn't say.
+func (tr *trading) modeOf(req internal.OrderRequest) string {
+	if req.Mode != "" {
+		return req.Mode
+	}
+	return tr.mode
+}
+
+// executor returns the paper account for ModePaper, else live.
+func (tr *trading) executor(mode string) internal.Executor {
+	if mode == internal.ModePaper {
+		return tr.paper
+	}
+	return liveExecutor{tr}
+}
+
+// liveExecutor signs and sends orders to Lighter.
+type liveExecutor struct{ tr *trading }
+
+func (l liveExecutor) Mode() string { return internal.ModeLive }
+
+func (l liveExecutor) Execute(ctx context.Context, req internal.OrderRequest, mkt internal.MarketSpec) (*internal.OrderResponse, error) {
+	if l.tr.signer == nil {
+		return nil, errTradingDisabled
+	}
+	return l.tr.execute(ctx, req, mkt)
+}
+
+// Cancel cancels a working journaled order and its bracket legs.
+func (l liveExecutor) Cancel(ctx context.Context, orderID string) error {
+	rows := findOrders(func(o internal.OrderRow) bool { return o.OrderID == orderID })
+	if len(rows) == 0 {
+		return fmt.Errorf("order %s not found", orderID)
+	}
+	row := rows[0]
+	if !isWorking(row.Status) {
+		return fmt.Errorf("order %s is %s", orderID, row.Status)
+	}
+	if l.tr.signer == nil {
+		return errTradingDisabled
+	}
+	if err := l.tr.cancelOrder(ctx, row); err != nil {
+		return err
+	}
+	setOrderStatus(row.OrderID, "cancelled")
+	cancelChildren(ctx, l.tr, row.OrderID)
+	return nil
+}
+
+// execute builds, signs and sends req (with any bracket legs) and
+// journals the result. Callers have already validated and risk-checked
+// it; a bad size/price grid comes back as badOrderError, and a send
+// that may or may not have landed as *orderInDoubtError.
+func (tr *trading) execute(ctx context.Context, req internal.OrderRequest, mkt internal.MarketSpec) (*internal.OrderResponse, error) {
+	tx, err := buildCreateOrderTx(req, mkt, tr.grid)
+	if err != nil {
+		return nil, badOrderError{err}
+	}
+
+	send := txSender(tr.placeOrder)
+	sign := func(nonce int64) (string, error) {
+		tx.Nonce = nonce
+		return tr.signer.SignCreateOrder(tx)
+	}
+
+	// stop_loss / take_profit ride along in one grouped tx
+	var (
+		group    *internal.CreateGroupedOrdersTxInfo
+		children []bracketChild
+	)
+	if req.StopLoss != nil || req.TakeProfit != nil {
+		group, children, err = buildBracketTx(req, mkt, tx, tr.grid)
+		if err != nil {
+			return nil, badOrderError{err}
+		}
+		send = tr.lc.CreateGroupedOrders
+		sign = func(nonce int64) (string, error) {
+			group.Nonce = nonce
+			return tr.signer.SignCreateGroupedOrders(group)
+		}
+	}
+
+	status := "open"
+	if req.Type == "market" {
+		status = "submitted"
+	}
+
+	// journal it so the UI can see "Working & Recent Orders"
+	rows := []internal.OrderRow{{
+		ClientOrderIndex: tx.ClientOrderIndex,
+		MarketID:         mkt.MarketID,
+		Symbol:           req.Symbol,
+		Side:             req.Side,
+		Type:             req.Type,
+		Status:           status,
+		Price:            derefDecimal(req.Price),
+		SizeUsd:          derefDecimal(req.SizeUSD),
+		// what was actually sent, so a later modify keeps the same size
+		SizeContracts:  internal.NewDecimalScaled(tx.BaseAmount, mkt.SizeDecimals),
+		Leverage:       req.Leverage,
+		ReduceOnly:     req.ReduceOnly,
+		ClientID:       req.ClientID,
+		CreatedAtEpoch: time.Now().Unix(),
+	}}
+	for _, c := range children {
+		rows = append(rows, bracketRow(rows[0], c, mkt))
+	}
+
+	placed, err := tr.submit(ctx, send, sign)
+	if err != nil {
+		if !internal.IsAmbiguous(err) {
+			return nil, err
+		}
+		sent := &sentOrder{Request: req, Rows: rows, ClientOrderIndex: tx.ClientOrderIndex, Nonce: tx.Nonce, ExpiredAt: tx.ExpiredAt}
+		if group != nil {
+			sent.Nonce, sent.ExpiredAt = group.Nonce, group.ExpiredAt
+		}
+		return nil, &orderInDoubtError{err: err, sent: sent}
+	}
+
+	// the exchange assigns order indexes; look them up so rows can be
+	// keyed, cancelled and matched to fills by them
+	sent := sentOrder{ClientOrderIndex: tx.ClientOrderIndex}
+	if group != nil {
+		sent.Nonce = group.Nonce
+	}
+	found, err := tr.awaitOrders(ctx, mkt.MarketID, sent.match, len(rows))
+	if err != nil {
+		log.Printf("order %s: look up order index: %v", placed.TxHash, err)
+	}
+	return tr.recordPlaced(req, rows, placed, found, sent.match), nil
+}
+
+// recordPlaced journals rows for an order the exchange took, keyed by
+// the order indexes in found (or the tx hash until they show up), and
+// builds the response.
+func (tr *trading) recordPlaced(req internal.OrderRequest, rows []internal.OrderRow, placed *internal.PlaceOrderResponse, found []internal.AccountOrder, match func(internal.AccountOrder) bool) *internal.OrderResponse {
+	all := assignOrderIndexes(rows, found)
+	for i := range rows {
+		rows[i].TxHash = placed.TxHash
+		rows[i].OrderID = placed.TxHash
+		if rows[i].OrderIndex != 0 {
+			rows[i].OrderID = strconv.FormatInt(rows[i].OrderIndex, 10)
+		}
+		if i > 0 {
+			rows[i].ParentOrderID = rows[0].OrderID
+			if rows[i].OrderIndex == 0 {
+				rows[i].OrderID = rows[0].OrderID + bracketSuffix(rows[i].Type)
+			}
+		}
+	}
+	appendOrders(rows...)
+	if !all {
+		go tr.resolveLater(append([]internal.OrderRow(nil), rows...), match)
+	}
+
+	resp := internal.OrderResponse{
+		OrderID:          rows[0].OrderID,
+		OrderIndex:       rows[0].OrderIndex,
+		TxHash:           placed.TxHash,
+		ClientOrderIndex: rows[0].ClientOrderIndex,
+		Status:           rows[0].Status,
+		Message:          placed.Message,
+		Request:          req,
+	}
+	for _, child := range rows[1:] {
+		resp.ChildOrderIDs = append(resp.ChildOrderIDs, child.OrderID)
+	}
+	return &resp
+}
+
+// ----- orders in doubt -----
+
+// sentOrder is what's needed to find an order on the exchange after
+// sending it: by client order index, or for grouped orders (which have
+// none) by the tx nonce they share. For a send whose outcome is
+// unknown it also carries the rows to journal if the order turns up.
+type sentOrder struct {
+	Request          internal.OrderRequest `json:"request"`
+	Rows             []internal.OrderRow   `json:"rows"`
+	ClientOrderIndex int64                 `json:"client_order_index,omitempty"`
+	Nonce            int64                 `json:"nonce"`
+	ExpiredAt        int64                 `json:"expired_at"` // ms; the tx can't land after this
+}
+
+func (s *sentOrder) match(o internal.AccountOrder) bool {
+	if s.ClientOrderIndex != 0 {
+		return o.ClientOrderIndex == s.ClientOrderIndex
+	}
+	return o.Nonce == s.Nonce
+}
+
+// orderInDoubtError is a send that failed in a way that may still have
+// placed the order (timeout, reset, 5xx).
+type orderInDoubtError struct {
+	err  error
+	sent *sentOrder
+}
+
+func (e *orderInDoubtError) Error() string { return "order outcome unknown: " + e.err.Error() }
+func (e *orderInDoubtError) Unwrap() error { return e.err }
+
+const orderConfirmCheck = 15 * time.Second
+
+// confirmSent polls until a send in doubt is settled: the order showed
+// up, so it's journaled, becomes clientID's response and is returned, or
+// it can no longer land (its nonce was used or its tx expired), clientID
+// is released for a retry and nil is returned.
+func (tr *trading) confirmSent(clientID string, sent *sentOrder) *internal.OrderResponse {
+	tick := time.NewTicker(orderResolveEvery)
+	defer tick.Stop()
+	for range tick.C {
+		ctx, cancel := context.WithTimeout(context.Background(), orderConfirmCheck)
+		resp, gone, err := tr.checkSent(ctx, sent)
+		cancel()
+		switch {
+		case err != nil:
+			log.Printf("confirm order %s: %v", clientID, err)
+		case resp != nil:
+			log.Printf("confirm order %s: placed as %s", clientID, resp.OrderID)
+			tr.dedupe.settle(clientID, resp)
+			return resp
+		case gone:
+			log.Printf("confirm order %s: not placed", clientID)
+			tr.dedupe.settle(clientID, nil)
+			return nil
+		}
+	}
+	return nil
+}
+
+// checkSent looks for sent on the exchange. The nonce is read first so
+// an order that lands between the two calls is still seen.
+func (tr *trading) checkSent(ctx context.Context, sent *sentOrder) (*internal.OrderResponse, bool, error) {
+	next, err := tr.lc.NextNonce(ctx, tr.signer.AccountIndex(), tr.signer.APIKeyIndex())
+	if err != nil {
+		return nil, false, err
+	}
+	found, err := tr.findExchangeOrders(ctx, sent.Rows[0].MarketID, sent.match)
+	if err != nil {
+		return nil, false, err
+	}
+	// the entry is what the response and the legs are keyed on
+	if hasEntry(found) {
+		rows := append([]internal.OrderRow(nil), sent.Rows...)
+		placed := &internal.PlaceOrderResponse{Message: "confirmed after the send failed"}
+		return tr.recordPlaced(sent.Request, rows, placed, found, sent.match), false, nil
+	}
+	gone := len(found) == 0 && (next > sent.Nonce || time.Now().UnixMilli() > sent.ExpiredAt)
+	return nil, gone, nil
+}
+
+func hasEntry(found []internal.AccountOrder) bool {
+	for _, o := range found {
+		if orderKind(o.Type) == "entry" {
+			return true
+		}
+	}
+	return false
+}
+
+// ----- order index lookup -----
+
+const (
+	// auth tokens for the account order lookups
+	authTokenTTL        = 10 * time.Minute
+	orderLookupAttempts = 4
+	orderLookupDelay    = 250 * time.Millisecond
+	// background lookups for orders that didn't show up in time
+	orderResolveEvery = 2 * time.Second
+	orderResolveFor   = time.Minute
+)
+
+// findExchangeOrders returns our orders on marketID that match, open or
+// recently closed. No match with a nil error means the exchange doesn't
+// have the order (yet).
+func (tr *trading) findExchangeOrders(ctx context.Context, marketID int, match func(internal.AccountOrder) bool) ([]internal.AccountOrder, error) {
+	auth, err := tr.signer.AuthToken(time.Now().Add(authTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+	var out []internal.AccountOrder
+	for _, active := range []bool{true, false} {
+		orders, err := tr.lc.AccountOrders(ctx, tr.signer.AccountIndex(), marketID, auth, active)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range orders {
+			if match(o) {
+				out = append(out, o)
+			}
+		}
+	}
+	return out, nil
+}
+
+// orderGone is true when the exchange positively has no active order
+// for row; any doubt (no order index yet, lookup error) is false.
+func (tr *trading) orderGone(ctx context.Context, row internal.OrderRow) bool {
+	if row.OrderIndex == 0 {
+		return false
+	}
+	auth, err := tr.signer.AuthToken(time.Now().Add(authTokenTTL))
+	if err != nil {
+		return false
+	}
+	orders, err := tr.lc.AccountOrders(ctx, tr.signer.AccountIndex(), row.MarketID, auth, true)
+	if err != nil {
+		return false
+	}
+	for _, o := range orders {
+		if o.OrderIndex == row.OrderIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// awaitOrders polls briefly for want matching orders: a tx the exchange
+// accepted can take a moment to show up.
+func (tr *trading) awaitOrders(ctx context.Context, marketID int, match func(internal.AccountOrder) bool, want int) ([]internal.AccountOrder, error) {
+	var (
+		found []internal.AccountOrder
+		err   error
+	)
+	for i := 0; i < orderLookupAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return found, ctx.Err()
+			case <-time.After(orderLookupDelay << (i - 1)):
+			}
+		}
+		found, err = tr.findExchangeOrders(ctx, marketID, match)
+		if err == nil && len(found) >= want {
+			break
+		}
+	}
+	return found, err
+}
+
+// assignOrderIndexes copies exchange order indexes onto rows: the first
+// row is the entry, the rest its bracket legs, told apart by type. It
+// reports whether every row got one.
+func assignOrderIndexes(rows []internal.OrderRow, found []internal.AccountOrder) bool {
+	all := true
+	for i := range rows {
+		for _, o := range found {
+			if orderKind(o.Type) == orderKind(rows[i].Type) {
+				rows[i].OrderIndex = o.OrderIndex
+				break
+			}
+		}
+		if rows[i].OrderIndex == 0 {
+			all = false
+		}
+	}
+	return all
+}
+
+// orderKind maps journal and exchange order types onto entry/sl/tp.
+func orderKind(t string) string {
+	switch {
+	case strings.Contains(t, "stop"):
+		return "sl"
+	case strings.Contains(t, "take"):
+		return "tp"
+	}
+	return "entry"
+}
+
+// resolveLater keeps looking for orders awaitOrders gave up on and
+// records their indexes once the exchange shows them. Row ids stay as
+// they were journaled.
+func (tr *trading) resolveLater(rows []internal.OrderRow, match func(internal.AccountOrder) bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), orderResolveFor)
+	defer cancel()
+	ids := make([]string, len(rows))
+	for i, r := range rows {
+		ids[i] = r.OrderID
+	}
+
+	tick := time.NewTicker(orderResolveEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("order %s: order index not found", rows[0].TxHash)
+			return
+		case <-tick.C:
+		}
+		found, err := tr.findExchangeOrders(ctx, rows[0].MarketID, match)
+		if err != nil || len(found) == 0 {
+			continue
+		}
+		all := assignOrderIndexes(rows, found)
+		for i, r := range rows {
+			if idx := r.OrderIndex; idx != 0 {
+				updateOrder(ids[i], func(o *internal.OrderRow) { o.OrderIndex = idx })
+			}
+		}
+		if all {
+			return
+		}
+	}
+}
+
+// txSender is one of the LighterClient sendTx wrappers.
+type txSender func(ctx context.Context, txInfo string) (*internal.PlaceOrderResponse, error)
+
+// submit signs with a managed nonce and sends the tx, resyncing and
+// re-signing if the exchange rejects the nonce.
+func (tr *trading) submit(ctx context.Context, send txSender, sign func(nonce int64) (string, error)) (*internal.PlaceOrderResponse, error) {
+	var placed *internal.PlaceOrderResponse
+	err := tr.nonces.Do(ctx, func(nonce int64) error {
+		txInfo, err := sign(nonce)
+		if err != nil {
+			return err
+		}
+		placed, err = send(ctx, txInfo)
+		return err
+	})
+	return placed, err
+}
+
+func (tr *trading) placeOrder(ctx context.Context, txInfo string) (*internal.PlaceOrderResponse, error) {
+	return tr.lc.PlaceOrder(ctx, internal.PlaceOrderRequest{
+		TxType: internal.TxTypeCreateOrder,
+		TxInfo: txInfo,
+	})
+}
+
+// exchangeIndex is how txs refer to row: its order index, else its
+// client order index.
+func exchangeIndex(row internal.OrderRow) (int64, error) {
+	if row.OrderIndex != 0 {
+		return row.OrderIndex, nil
+	}
+	if row.ClientOrderIndex != 0 {
+		return row.ClientOrderIndex, nil
+	}
+	return 0, fmt.Errorf("order %s: order index not known yet", row.OrderID)
+}
+
+// cancelOrder cancels one resting order by its order index.
+func (tr *trading) cancelOrder(ctx context.Context, row internal.OrderRow) error {
+	market, err := internal.MarketIndexOf(row.MarketID)
+	if err != nil {
+		return err
+	}
+	index, err := exchangeIndex(row)
+	if err != nil {
+		return err
+	}
+	tx := &internal.CancelOrderTxInfo{
+		MarketIndex: market,
+		Index:       index,
+	}
+	_, err = tr.submit(ctx, tr.lc.CancelOrder, func(nonce int64) (string, error) {
+		tx.Nonce = nonce
+		return tr.signer.SignCancelOrder(tx)
+	})
+	return err
+}
+
+// modifyOrder amends price/size (and trigger, for a stop or target) of
+// a resting order, keeping its place in the queue where the exchange
+// allows it.
+func (tr *trading) modifyOrder(ctx context.Context, row internal.OrderRow, price, trigger uint32, base int64) (*internal.PlaceOrderResponse, error) {
+	market, err := internal.MarketIndexOf(row.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	index, err := exchangeIndex(row)
+	if err != nil {
+		return nil, err
+	}
+	tx := &internal.ModifyOrderTxInfo{
+		MarketIndex:  market,
+		Index:        index,
+		BaseAmount:   base,
+		Price:        price,
+		TriggerPrice: trigger,
+	}
+	return tr.submit(ctx, tr.lc.ModifyOrder, func(nonce int64) (string, error) {
+		tx.Nonce = nonce
+		return tr.signer.SignModifyOrder(tx)
+	})
+}
+
+// cancelAll cancels every open order on the account, across markets.
+func (tr *trading) cancelAll(ctx context.Context) error {
+	tx := &internal.CancelAllOrdersTxInfo{TimeInForce: internal.CancelAllImmediate}
+	_, err := tr.submit(ctx, tr.lc.CancelAllOrders, func(nonce int64) (string, error) {
+		tx.Nonce = nonce
+		return tr.signer.SignCancelAllOrders(tx)
+	})
+	return err
+}
+
+// cancelEverything sends cancel-all and marks every working order in
+// the journal cancelled, returning their ids.
+func (tr *trading) cancelEverything(ctx context.Context) ([]string, error) {
+	if err := tr.cancelAll(ctx); err != nil {
+		return nil, err
+	}
+	rows := findOrders(openOrderFilter(""))
+	cancelled := make([]string, 0, len(rows))
+	for _, row := range rows {
+		setOrderStatus(row.OrderID, "cancelled")
+		cancelled = append(cancelled, row.OrderID)
+	}
+	return cancelled, nil
+}
+
+// ----- pre-trade risk inputs -----
+
+// orderNotional values req at px (its limit price, or mark).
+func orderNotional(req internal.OrderRequest, px internal.Decimal) internal.Decimal {
+	if req.SizeContracts != nil && req.SizeContracts.IsPositive() {
+		return req.SizeContracts.Mul(px)
+	}
+	return derefDecimal(req.SizeUSD)
+}
+
+// derefDecimal is *d, or zero for an omitted field.
+func derefDecimal(d *internal.Decimal) internal.Decimal {
+	if d == nil {
+		return internal.Decimal{}
+	}
+	return *d
+}
+
+// riskContext gathers mark price, exposure, open orders and margin for
+// req. excludeOrderID leaves one journaled order out of the counts, so a
+// modify isn't measured against itself. Fetch failures leave fields
+// zero; the checks decide whether that is fatal.
+func (tr *trading) riskContext(ctx context.Context, req internal.OrderRequest, excludeOrderID string) *internal.RiskContext {
+	rc := &internal.RiskContext{Order: req}
+
+	markets, _, err := loadMarketsMerged(ctx, tr.lc)
+	if err != nil {
+		log.Printf("risk: loadMarketsMerged error: %v", err)
+	}
+	var mark internal.Decimal
+	for _, m := range markets {
+		if m.Symbol == req.Symbol {
+			mark = m.RefPrice()
+		}
+	}
+	rc.MarkPrice = mark
+
+	px := mark
+	if req.Price != nil {
+		px = *req.Price
+	}
+	rc.NotionalUsd = orderNotional(req, px)
+
+	if tr.modeOf(req) == internal.ModePaper {
+		paperRiskContext(rc, tr.paper.Account(), markets, excludeOrderID)
+		return rc
+	}
+
+	// legs are reduce-only and ride on their parent, so skip them
+	for _, o := range findOrders(openOrderFilter("")) {
+		if o.ParentOrderID != "" || o.OrderID == excludeOrderID {
+			continue
+		}
+		rc.OpenOrders++
+		if o.Symbol == req.Symbol && !o.ReduceOnly {
+			rc.SymbolExposureUsd = rc.SymbolExposureUsd.Add(o.Price.Mul(o.SizeContracts))
+		}
+	}
+
+	addr := os.Getenv("LIGHTER_L1_ADDRESS")
+	if addr == "" {
+		return rc
+	}
+	acct, err := tr.lc.AccountByL1(ctx, addr)
+	if err != nil {
+		log.Printf("risk: AccountByL1 error: %v", err)
+		return rc
+	}
+
+	rc.MarginKnown = true
+	rc.MarginAvailableUsd = summarizeAccount(addr, acct).MarginAvailableUsd
+	for _, p := range flattenPositions(acct, markets) {
+		if p.Symbol == req.Symbol {
+			rc.SymbolExposureUsd = rc.SymbolExposureUsd.Add(p.SizeUsd)
+		}
+	}
+
+	// orders placed outside this backend only show up on the account
+	exchangeOpen := 0
+	for _, a := range acct.Accounts {
+		for _, p := range a.Positions {
+			exchangeOpen += p.OpenOrderCount
+		}
+	}
+	if excludeOrderID != "" {
+		exchangeOpen--
+	}
+	if exchangeOpen > rc.OpenOrders {
+		rc.OpenOrders = exchangeOpen
+	}
+	return rc
+}
+
+// writeRiskError reports which rule refused the order.
+func writeRiskError(w http.ResponseWriter, err error) {
+	var rej *internal.RiskRejection
+	if errors.As(err, &rej) {
+		log.Printf("order refused: %v", rej)
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{
+			"error":   "risk check failed",
+			"rule":    rej.Rule,
+			"message": rej.Reason,
+		})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+// resolveMarket looks up symbol and writes the error response if it fails.
+func resolveMarket(w http.ResponseWriter, r *http.Request, tr *trading, symbol string) (internal.MarketSpec, bool) {
+	mkt, err := tr.markets.Lookup(r.Context(), symbol)
+	if errors.Is(err, internal.ErrUnknownMarket) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown symbol " + symbol})
+		return mkt, false
+	}
+	if err != nil {
+		writeUpstreamError(w, err, "failed to fetch market details")
+		return mkt, false
+	}
+	return mkt, true
+}
+
+// client order indexes must be unique per account; seed from the clock
+// so a restart doesn't reuse indexes from the previous run.
+var clientOrderSeq = time.Now().UnixMilli()
+
+func nextClientOrderIndex() int64 {
+	return atomic.AddInt64(&clientOrderSeq, 1)
+}
+
+// buildCreateOrderTx builds the unsigned create-order tx for req.
+// Market orders go out as IOC.
+func buildCreateOrderTx(req internal.OrderRequest, mkt internal.MarketSpec, mode internal.GridMode) (*internal.CreateOrderTxInfo, error) {
+	price, base, err := mkt.ScaleOrder(req, mode)
+	if err != nil {
+		return nil, err
+	}
+	market, err := internal.MarketIndexOf(mkt.MarketID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &internal.CreateOrderTxInfo{
+		MarketIndex:      market,
+		ClientOrderIndex: nextClientOrderIndex(),
+		BaseAmount:       base,
+		Price:            price,
+		Type:             internal.OrderTypeLimit,
+		TimeInForce:      internal.TimeInForceGoodTillTime,
+	}
+	if req.Type == "market" {
+		tx.Type = internal.OrderTypeMarket
+		tx.TimeInForce = internal.TimeInForceIOC
+	}
+	if req.Side == "sell" {
+		tx.IsAsk = 1
+	}
+	if req.ReduceOnly {
+		tx.ReduceOnly = 1
+	}
+	return tx, nil
+}
+
+// ----- bracket (stop-loss / take-profit) legs -----
+
+// bracketChild is a reduce-only exit attached to a parent order.
+type bracketChild struct {
+	kind    string // "stop_loss" | "take_profit"
+	trigger internal.Decimal
+	order   internal.GroupedOrderInfo
+}
+
+// bracketSuffix keys a leg whose order index isn't known yet off its
+// parent's id.
+func bracketSuffix(kind string) string {
+	if kind == "take_profit" {
+		return "-tp"
+	}
+	return "-sl"
+}
+
+// bracketRow is the order-log entry for a leg of parent. recordPlaced
+// sets its id and ParentOrderID once the order indexes are known.
+func bracketRow(parent internal.OrderRow, c bracketChild, mkt internal.MarketSpec) internal.OrderRow {
+	side := "sell"
+	if parent.Side == "sell" {
+		side = "buy"
+	}
+	return internal.OrderRow{
+		MarketID:       parent.MarketID,
+		Symbol:         parent.Symbol,
+		Side:           side,
+		Type:           c.kind,
+		Status:         "pending",
+		Price:          internal.NewDecimalScaled(int64(c.order.Price), mkt.PriceDecimals),
+		SizeContracts:  parent.SizeContracts,
+		Leverage:       parent.Leverage,
+		ReduceOnly:     true,
+		ClientID:       parent.ClientID,
+		CreatedAtEpoch: parent.CreatedAtEpoch,
+		TriggerPrice:   c.trigger,
+	}
+}
+
+// validateBracket checks the stop and target sit on the right side of
+// the entry: below/above it for a buy, the reverse for a sell.
+func validateBracket(req internal.OrderRequest, entry internal.Decimal) error {
+	long := req.Side == "buy"
+	if req.StopLoss != nil {
+		if long && req.StopLoss.Cmp(entry) >= 0 {
+			return fmt.Errorf("stop_loss must be below entry %v for a buy", entry)
+		}
+		if !long && req.StopLoss.Cmp(entry) <= 0 {
+			return fmt.Errorf("stop_loss must be above entry %v for a sell", entry)
+		}
+	}
+	if req.TakeProfit != nil {
+		if long && req.TakeProfit.Cmp(entry) <= 0 {
+			return fmt.Errorf("take_profit must be above entry %v for a buy", entry)
+		}
+		if !long && req.TakeProfit.Cmp(entry) >= 0 {
+			return fmt.Errorf("take_profit must be below entry %v for a sell", entry)
+		}
+	}
+	return nil
+}
+
+// buildBracketTx wraps parent and its SL/TP legs in one grouped tx.
+// With both legs it is OTOCO: the parent fill arms an OCO pair, and the
+// exchange drops the pair if the parent is cancelled first. Legs carry
+// no size of their own; they close what the parent filled. Grouped
+// orders can't have client order indexes, so parent's is cleared.
+// Off-tick triggers round toward the entry, so an exit never fires
+// later than asked.
+func buildBracketTx(req internal.OrderRequest, mkt internal.MarketSpec, parent *internal.CreateOrderTxInfo, mode internal.GridMode) (*internal.CreateGroupedOrdersTxInfo, []bracketChild, error) {
+	entry := mkt.LastTradePrice
+	if req.Type == "limit" {
+		entry = *req.Price
+	}
+	if err := validateBracket(req, entry); err != nil {
+		return nil, nil, err
+	}
+
+	exitSide := "sell"
+	if req.Side == "sell" {
+		exitSide = "buy"
+	}
+
+	var children []bracketChild
+	addLeg := func(kind string, trigger internal.Decimal, orderType uint8) error {
+		// exits fire as market orders; bound them like any market order
+		worst := trigger.Mul(decimalOne.Sub(internal.MarketOrderMaxSlippage))
+		if exitSide == "buy" {
+			worst = trigger.Mul(decimalOne.Add(internal.MarketOrderMaxSlippage))
+		}
+		trig, err := mkt.PriceTicks(trigger, mode, trigger.LessThan(entry))
+		if err != nil {
+			return fmt.Errorf("%s: %w", kind, err)
+		}
+		px, err := mkt.PriceTicks(worst, internal.GridRound, exitSide == "sell")
+		if err != nil {
+			return fmt.Errorf("%s: %w", kind, err)
+		}
+
+		leg := internal.GroupedOrderInfo{
+			MarketIndex:  parent.MarketIndex,
+			Price:        px,
+			IsAsk:        1 - parent.IsAsk,
+			Type:         orderType,
+			TimeInForce:  internal.TimeInForceIOC,
+			ReduceOnly:   1,
+			TriggerPrice: trig,
+		}
+		children = append(children, bracketChild{kind: kind, trigger: trigger, order: leg})
+		return nil
+	}
+
+	if req.StopLoss != nil {
+		if err := addLeg("stop_loss", *req.StopLoss, internal.OrderTypeStopLoss); err != nil {
+			return nil, nil, err
+		}
+	}
+	if req.TakeProfit != nil {
+		if err := addLeg("take_profit", *req.TakeProfit, internal.OrderTypeTakeProfit); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	parent.ClientOrderIndex = 0
+	group := &internal.CreateGroupedOrdersTxInfo{
+		GroupingType: internal.GroupingOTO,
+		Orders: []internal.GroupedOrderInfo{{
+			MarketIndex: parent.MarketIndex,
+			BaseAmount:  parent.BaseAmount,
+			Price:       parent.Price,
+			IsAsk:       parent.IsAsk,
+			Type:        parent.Type,
+			TimeInForce: parent.TimeInForce,
+			ReduceOnly:  parent.ReduceOnly,
+		}},
+	}
+	if len(children) == 2 {
+		group.GroupingType = internal.GroupingOTOCO
+	}
+	for _, c := range children {
+		group.Orders = append(group.Orders, c.order)
+	}
+	return group, children, nil
+}
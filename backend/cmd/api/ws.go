@@ -0,0 +1,350 @@
+// backend/cmd/api/ws.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+const (
+	wsWriteTimeout      = 10 * time.Second
+	wsHeartbeatInterval = 15 * time.Second
+)
+
+// ----- /ws/markets protocol -----
+//
+// client -> server
+//   {"op":"subscribe","symbols":["ETH"],"fields":["mark_price"]}  (empty = all)
+//   {"op":"unsubscribe","symbols":["ETH"]} / {"op":"unsubscribe","fields":[...]}
+//   {"op":"snapshot"}
+//   {"op":"ping"}
+//
+// server -> client
+//   {"type":"snapshot","seq":1,"ts":...,"as_of":...,"markets":[{"symbol":"ETH",...}]}
+//   {"type":"diff","seq":2,"ts":...,"as_of":...,"changes":{"ETH":{"mark_price":3001.5}},"removed":["XYZ"]}
+//   {"type":"heartbeat","seq":3,"ts":...}
+//   {"type":"subscribed","symbols":[...],"fields":[...]}, {"type":"pong"}, {"type":"error","error":"..."}
+//
+// A new connection is subscribed to everything and gets a snapshot;
+// after that only fields that changed are sent. as_of (unix ms) is when
+// the data was fetched upstream; "stale":true means upstream is failing
+// and the data is a cached copy.
+
+type wsRequest struct {
+	Op      string   `json:"op"`
+	Symbols []string `json:"symbols"`
+	Fields  []string `json:"fields"`
+}
+
+type wsMessage struct {
+	Type    string                                `json:"type"`
+	Seq     int64                                 `json:"seq,omitempty"`
+	Ts      int64                                 `json:"ts,omitempty"`
+	AsOf    int64                                 `json:"as_of,omitempty"`
+	Stale   bool                                  `json:"stale,omitempty"`
+	Markets []map[string]json.RawMessage          `json:"markets,omitempty"`
+	Changes map[string]map[string]json.RawMessage `json:"changes,omitempty"`
+	Removed []string                              `json:"removed,omitempty"`
+	Symbols []string                              `json:"symbols,omitempty"`
+	Fields  []string                              `json:"fields,omitempty"`
+	Error   string                                `json:"error,omitempty"`
+}
+
+// marketFields is every JSON field of a market row, for validation.
+var marketFields = func() map[string]bool {
+	b, _ := json.Marshal(internal.MarketRow{})
+	var m map[string]json.RawMessage
+	_ = json.Unmarshal(b, &m)
+	out := make(map[string]bool, len(m))
+	for k := range m {
+		out[k] = true
+	}
+	return out
+}()
+
+// rowFields is a market row as field -> encoded value, keyed by symbol.
+type rowFields map[string]map[string]json.RawMessage
+
+func encodeRows(rows []internal.MarketRow) rowFields {
+	out := make(rowFields, len(rows))
+	for _, r := range rows {
+		b, err := json.Marshal(r)
+		if err != nil {
+			continue
+		}
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(b, &m); err != nil {
+			continue
+		}
+		out[r.Symbol] = m
+	}
+	return out
+}
+
+// wsSession is one client's subscription and what it has been sent.
+type wsSession struct {
+	symbols map[string]bool // empty = all
+	fields  map[string]bool // empty = all
+	sent    rowFields
+	seq     int64
+}
+
+func newWSSession() *wsSession {
+	return &wsSession{
+		symbols: map[string]bool{},
+		fields:  map[string]bool{},
+		sent:    rowFields{},
+	}
+}
+
+func (s *wsSession) wants(symbol string) bool {
+	return len(s.symbols) == 0 || s.symbols[symbol]
+}
+
+// project keeps the subscribed fields (symbol always) of a row.
+func (s *wsSession) project(row map[string]json.RawMessage) map[string]json.RawMessage {
+	if len(s.fields) == 0 {
+		return row
+	}
+	out := map[string]json.RawMessage{"symbol": row["symbol"]}
+	for f := range s.fields {
+		if v, ok := row[f]; ok {
+			out[f] = v
+		}
+	}
+	return out
+}
+
+func (s *wsSession) next(typ string) wsMessage {
+	s.seq++
+	return wsMessage{Type: typ, Seq: s.seq, Ts: time.Now().UnixMilli()}
+}
+
+// snapshot sends every subscribed row and resets the diff baseline.
+func (s *wsSession) snapshot(cur rowFields) wsMessage {
+	msg := s.next("snapshot")
+	msg.Markets = []map[string]json.RawMessage{}
+	s.sent = rowFields{}
+
+	for _, sym := range sortedSymbols(cur) {
+		if !s.wants(sym) {
+			continue
+		}
+		row := s.project(cur[sym])
+		s.sent[sym] = row
+		msg.Markets = append(msg.Markets, row)
+	}
+	return msg
+}
+
+// diff returns the changed fields since the last send, or false when
+// nothing changed.
+func (s *wsSession) diff(cur rowFields) (wsMessage, bool) {
+	msg := s.next("diff")
+	msg.Changes = map[string]map[string]json.RawMessage{}
+
+	for sym, full := range cur {
+		if !s.wants(sym) {
+			continue
+		}
+		row := s.project(full)
+		prev := s.sent[sym]
+		changed := map[string]json.RawMessage{}
+		for f, v := range row {
+			if string(prev[f]) != string(v) {
+				changed[f] = v
+			}
+		}
+		if len(changed) > 0 {
+			msg.Changes[sym] = changed
+			s.sent[sym] = row
+		}
+	}
+	for sym := range s.sent {
+		if _, ok := cur[sym]; !ok {
+			msg.Removed = append(msg.Removed, sym)
+			delete(s.sent, sym)
+		}
+	}
+	sort.Strings(msg.Removed)
+
+	if len(msg.Changes) == 0 && len(msg.Removed) == 0 {
+		s.seq-- // nothing sent, don't burn a sequence number
+		return wsMessage{}, false
+	}
+	return msg, true
+}
+
+// apply handles a subscribe/unsubscribe and reports the new set.
+func (s *wsSession) apply(req wsRequest) (wsMessage, error) {
+	for _, f := range req.Fields {
+		if !marketFields[f] {
+			return wsMessage{}, fmt.Errorf("unknown field %q", f)
+		}
+	}
+	switch req.Op {
+	case "subscribe":
+		for _, sym := range req.Symbols {
+			s.symbols[sym] = true
+		}
+		for _, f := range req.Fields {
+			s.fields[f] = true
+		}
+	case "unsubscribe":
+		if len(req.Symbols) == 0 && len(req.Fields) == 0 {
+			s.symbols = map[string]bool{}
+			s.fields = map[string]bool{}
+		}
+		for _, sym := range req.Symbols {
+			delete(s.symbols, sym)
+		}
+		for _, f := range req.Fields {
+			delete(s.fields, f)
+		}
+	}
+	return wsMessage{
+		Type:    "subscribed",
+		Symbols: sortedKeys(s.symbols),
+		Fields:  sortedKeys(s.fields),
+	}, nil
+}
+
+// /ws/markets streams hub snapshots as diffs. Nothing here calls
+// upstream; a client that can't keep up is dropped by the hub.
+func handleMarketsWS(hub *internal.MarketHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("ws upgrade error: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		sub := hub.Subscribe()
+		defer hub.Unsubscribe(sub)
+
+		// read pump: client ops go to the write loop, which owns the conn
+		reqs := make(chan wsRequest, 8)
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				var req wsRequest
+				if err := json.Unmarshal(data, &req); err != nil {
+					req = wsRequest{} // answered as invalid below
+				}
+				select {
+				case reqs <- req:
+				default: // client is flooding us; drop the op
+				}
+			}
+		}()
+
+		send := func(msg wsMessage) bool {
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(msg); err != nil {
+				log.Printf("ws write error: %v", err)
+				return false
+			}
+			return true
+		}
+
+		sess := newWSSession()
+		var cur rowFields
+		var asOf int64
+		var stale bool
+		started := false
+
+		heartbeat := time.NewTicker(wsHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			var out wsMessage
+			ok := false
+
+			select {
+			case <-closed:
+				return
+
+			case snap, open := <-sub.C:
+				if !open {
+					if sub.Dropped() {
+						log.Printf("ws client %s too slow, dropped", r.RemoteAddr)
+					}
+					return
+				}
+				cur = encodeRows(snap.Markets)
+				asOf, stale = snap.At.UnixMilli(), snap.Stale
+				if !started {
+					out, ok, started = sess.snapshot(cur), true, true
+				} else {
+					out, ok = sess.diff(cur)
+				}
+
+			case req := <-reqs:
+				switch req.Op {
+				case "ping":
+					out, ok = wsMessage{Type: "pong", Ts: time.Now().UnixMilli()}, true
+				case "snapshot":
+					out, ok, started = sess.snapshot(cur), true, true
+				case "subscribe", "unsubscribe":
+					ack, err := sess.apply(req)
+					if err != nil {
+						out, ok = wsMessage{Type: "error", Error: err.Error()}, true
+						break
+					}
+					if !send(ack) {
+						return
+					}
+					// new set, new baseline
+					out, ok, started = sess.snapshot(cur), true, true
+				case "":
+					out, ok = wsMessage{Type: "error", Error: "invalid message"}, true
+				default:
+					out, ok = wsMessage{Type: "error", Error: fmt.Sprintf("unknown op %q", req.Op)}, true
+				}
+
+			case <-heartbeat.C:
+				out, ok = sess.next("heartbeat"), true
+			}
+
+			if ok {
+				if out.Type == "snapshot" || out.Type == "diff" {
+					out.AsOf, out.Stale = asOf, stale
+				}
+				if !send(out) {
+					return
+				}
+				heartbeat.Reset(wsHeartbeatInterval)
+			}
+		}
+	}
+}
+
+func sortedSymbols(rows rowFields) []string {
+	out := make([]string, 0, len(rows))
+	for sym := range rows {
+		out = append(out, sym)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
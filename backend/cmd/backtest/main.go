@@ -0,0 +1,151 @@
+// backend/cmd/backtest/main.go
+//
+// backtest replays market data recorded by the API (RECORD_MARKET_DATA)
+// through one of the built-in strategies on a paper account:
+//
+//	backtest -strategy grid -config @grid.json -from 2026-10-01 -to 2026-10-08
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	internal "github.com/SpaceCadetOG/lighter-cloud-bot/backend/internal/lighter"
+)
+
+func main() {
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+
+	var (
+		dir       = flag.String("dir", filepath.Join(dataDir, "recordings"), "recordings directory")
+		name      = flag.String("strategy", "", "strategy to run")
+		config    = flag.String("config", "", "strategy config: JSON, or @file")
+		from      = flag.String("from", "", "start, RFC3339 or YYYY-MM-DD (UTC); default: first record")
+		to        = flag.String("to", "", "end, RFC3339 or YYYY-MM-DD (UTC, through the end of that day); default: last record")
+		balance   = flag.String("balance", "10000", "starting paper balance, USD")
+		timer     = flag.Duration("timer", 5*time.Second, "strategy timer interval")
+		spreadBps = flag.String("spread-bps", "2", "synthetic book width around the last price, bps")
+		grid      = flag.String("grid", string(internal.GridRound), "off-grid orders: round or reject")
+		asJSON    = flag.Bool("json", false, "print the report as JSON")
+		verbose   = flag.Bool("v", false, "keep engine and strategy logs")
+	)
+	flag.Parse()
+
+	strategies := map[string]internal.Strategy{}
+	var names []string
+	for _, s := range internal.BuiltinStrategies() {
+		strategies[s.Name()] = s
+		names = append(names, s.Name())
+	}
+	s, ok := strategies[*name]
+	if !ok {
+		log.Fatalf("unknown strategy %q; available: %s", *name, strings.Join(names, ", "))
+	}
+
+	cfg := internal.BacktestConfig{
+		Dir:        *dir,
+		TimerEvery: *timer,
+		Grid:       internal.GridMode(*grid),
+	}
+	var err error
+	if cfg.From, err = parseTime(*from, false); err != nil {
+		log.Fatalf("-from: %v", err)
+	}
+	if cfg.To, err = parseTime(*to, true); err != nil {
+		log.Fatalf("-to: %v", err)
+	}
+	if cfg.Balance, err = internal.ParseDecimal(*balance); err != nil {
+		log.Fatalf("-balance: %v", err)
+	}
+	if cfg.SpreadBps, err = internal.ParseDecimal(*spreadBps); err != nil {
+		log.Fatalf("-spread-bps: %v", err)
+	}
+	if cfg.Config, err = readConfig(*config); err != nil {
+		log.Fatalf("-config: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// the engine logs every hook; a replay only wants the report
+	logOut := log.Writer()
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	report, err := internal.RunBacktest(ctx, s, cfg)
+	log.SetOutput(logOut)
+	if err != nil {
+		log.Fatalf("backtest: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+		return
+	}
+	printReport(report)
+}
+
+// parseTime takes RFC3339 or a bare date. A bare date is its midnight,
+// or with endOfDay its last instant, so -to 2026-10-08 includes that day.
+func parseTime(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// readConfig takes inline JSON or @path.
+func readConfig(s string) (json.RawMessage, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b := []byte(s)
+	if strings.HasPrefix(s, "@") {
+		var err error
+		if b, err = os.ReadFile(s[1:]); err != nil {
+			return nil, err
+		}
+	}
+	if !json.Valid(b) {
+		return nil, fmt.Errorf("invalid JSON")
+	}
+	return json.RawMessage(b), nil
+}
+
+func printReport(r *internal.BacktestReport) {
+	fmt.Printf("strategy        %s (%s)\n", r.Strategy, r.Status.State)
+	fmt.Printf("period          %s → %s (%d records)\n", r.From.UTC().Format(time.RFC3339), r.To.UTC().Format(time.RFC3339), r.Records)
+	fmt.Printf("equity          %s → %s\n", r.StartingEquity.StringFixed(2), r.FinalEquity.StringFixed(2))
+	fmt.Printf("pnl             %s (%.2f%%)\n", r.PnlUsd.StringFixed(2), r.ReturnPct)
+	fmt.Printf("max drawdown    %s (%.2f%%)\n", r.MaxDrawdownUsd.StringFixed(2), r.MaxDrawdownPct)
+	fmt.Printf("sharpe          %.2f\n", r.Sharpe)
+	fmt.Printf("turnover        %s\n", r.TurnoverUsd.StringFixed(2))
+	fmt.Printf("fees            %s\n", r.FeesUsd.StringFixed(4))
+	fmt.Printf("orders / fills  %d / %d\n", r.Orders, r.Fills)
+	for _, p := range r.OpenPositions {
+		fmt.Printf("open            %s %s @ %s\n", p.Symbol, p.Size, p.AvgEntry)
+	}
+	if r.Status.LastError != "" {
+		fmt.Printf("last error      %s\n", r.Status.LastError)
+	}
+}
@@ -1,7 +1,13 @@
 module github.com/SpaceCadetOG/lighter-cloud-bot/backend
 
-go 1.19
+go 1.22
 
 require github.com/gorilla/websocket v1.5.3
 
-require github.com/joho/godotenv v1.5.1 // indirect
+require github.com/joho/godotenv v1.5.1
+
+require (
+	github.com/bits-and-blooms/bitset v1.14.2 // indirect
+	github.com/consensys/gnark-crypto v0.12.2-0.20240215234832-d72fcb379d3e // indirect
+	github.com/elliottech/poseidon_crypto v0.0.11
+)
@@ -0,0 +1,326 @@
+// backend/internal/lighter/backtest.go
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const defaultBacktestSpreadBps = 2
+
+// synthetic levels never run dry
+var backtestBookSize = NewDecimalFromInt(1e12)
+
+// BacktestConfig says what to replay and how to simulate it.
+type BacktestConfig struct {
+	Dir        string    // recordings written by Recorder
+	From, To   time.Time // zero means the whole recording
+	Balance    Decimal   // starting paper balance
+	TimerEvery time.Duration
+	SpreadBps  Decimal // width of the synthetic book around the last price
+	Grid       GridMode
+	Config     json.RawMessage // handed to the strategy's Configure
+}
+
+// BacktestReport is the outcome of one replay. Sharpe is annualised
+// from hourly equity returns.
+type BacktestReport struct {
+	Strategy       string          `json:"strategy"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	Records        int             `json:"records"`
+	StartingEquity Decimal         `json:"starting_equity"`
+	FinalEquity    Decimal         `json:"final_equity"`
+	PnlUsd         Decimal         `json:"pnl_usd"`
+	ReturnPct      float64         `json:"return_pct"`
+	MaxDrawdownUsd Decimal         `json:"max_drawdown_usd"`
+	MaxDrawdownPct float64         `json:"max_drawdown_pct"`
+	Sharpe         float64         `json:"sharpe"`
+	TurnoverUsd    Decimal         `json:"turnover_usd"`
+	FeesUsd        Decimal         `json:"fees_usd"`
+	Orders         int             `json:"orders"`
+	Fills          int             `json:"fills"`
+	OpenPositions  []PaperPosition `json:"open_positions"`
+	Status         StrategyStatus  `json:"status"`
+}
+
+// backtestFeed is the replayed market: the latest rows, and a synthetic
+// one-level book either side of the last trade (or mark) price.
+type backtestFeed struct {
+	spreadBps Decimal
+	rows      map[string]MarketRow
+	last      map[int]Decimal
+}
+
+func (f *backtestFeed) setMarkets(rows []MarketRow) {
+	for _, m := range rows {
+		f.rows[m.Symbol] = m
+		if px := m.RefPrice(); px.IsPositive() {
+			f.last[m.MarketID] = px
+		}
+	}
+}
+
+func (f *backtestFeed) Book(_ context.Context, marketID int) (BookSnapshot, error) {
+	px, ok := f.last[marketID]
+	if !ok {
+		return BookSnapshot{}, fmt.Errorf("no price for market %d yet", marketID)
+	}
+	half, _ := px.Mul(f.spreadBps).Shift(-4).Div(NewDecimalFromInt(2))
+	return BookSnapshot{
+		MarketID: marketID,
+		Bids:     []BookLevel{{Price: px.Sub(half), Size: backtestBookSize}},
+		Asks:     []BookLevel{{Price: px.Add(half), Size: backtestBookSize}},
+	}, nil
+}
+
+func (f *backtestFeed) Market(symbol string) (MarketRow, bool) {
+	m, ok := f.rows[symbol]
+	return m, ok
+}
+
+// backtestRouter sends strategy orders straight to the paper account.
+// There are no risk checks or kill switch in a replay.
+type backtestRouter struct {
+	feed  *backtestFeed
+	specs map[string]MarketSpec
+	paper *PaperExecutor
+}
+
+func (r *backtestRouter) PlaceOrder(ctx context.Context, req OrderRequest) (*OrderResponse, error) {
+	mkt, ok := r.specs[req.Symbol]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, req.Symbol)
+	}
+	if req.Type == "limit" && (req.Price == nil || !req.Price.IsPositive()) {
+		return nil, errors.New("limit orders require positive price")
+	}
+	if (req.SizeUSD == nil || !req.SizeUSD.IsPositive()) &&
+		(req.SizeContracts == nil || !req.SizeContracts.IsPositive()) {
+		return nil, errors.New("size_usd or size_contracts must be > 0")
+	}
+	// market orders bound their worst price off the replayed last price
+	mkt.LastTradePrice = r.feed.last[mkt.MarketID]
+	req.Mode = ModePaper
+	return r.paper.Execute(ctx, req, mkt)
+}
+
+func (r *backtestRouter) CancelOrder(ctx context.Context, orderID string) error {
+	return r.paper.Cancel(ctx, orderID)
+}
+
+func (r *backtestRouter) MarketSpec(_ context.Context, symbol string) (MarketSpec, error) {
+	mkt, ok := r.specs[symbol]
+	if !ok {
+		return MarketSpec{}, ErrUnknownMarket
+	}
+	return mkt, nil
+}
+
+// equityCurve tracks peak-to-trough drawdown and the last equity of
+// each hour for Sharpe.
+type equityCurve struct {
+	peak, maxDD Decimal
+	maxDDPct    float64
+	hours       []float64
+	hourOf      time.Time
+}
+
+func (c *equityCurve) sample(t time.Time, equity Decimal) {
+	if equity.Cmp(c.peak) > 0 {
+		c.peak = equity
+	}
+	if dd := c.peak.Sub(equity); dd.Cmp(c.maxDD) > 0 {
+		c.maxDD = dd
+		if c.peak.IsPositive() {
+			pct, _ := dd.Div(c.peak)
+			c.maxDDPct = pct.Float64() * 100
+		}
+	}
+	hour := t.Truncate(time.Hour)
+	if len(c.hours) == 0 || hour.After(c.hourOf) {
+		c.hours = append(c.hours, equity.Float64())
+		c.hourOf = hour
+		return
+	}
+	c.hours[len(c.hours)-1] = equity.Float64()
+}
+
+// sharpe is 0 when there aren't two hourly returns to take a
+// deviation of, or they're all the same.
+func (c *equityCurve) sharpe() float64 {
+	var rets []float64
+	for i := 1; i < len(c.hours); i++ {
+		if c.hours[i-1] > 0 {
+			rets = append(rets, c.hours[i]/c.hours[i-1]-1)
+		}
+	}
+	if len(rets) < 2 {
+		return 0
+	}
+	var mean, sd float64
+	for _, r := range rets {
+		mean += r
+	}
+	mean /= float64(len(rets))
+	for _, r := range rets {
+		sd += (r - mean) * (r - mean)
+	}
+	sd = math.Sqrt(sd / float64(len(rets)-1))
+	if sd == 0 {
+		return 0
+	}
+	return mean / sd * math.Sqrt(24*365)
+}
+
+// RunBacktest replays the recordings in cfg.Dir through s on a
+// simulated clock: each market snapshot is published and dispatched as
+// the hub would, timer ticks fire on recorded time, and orders fill on a
+// paper account against a synthetic book around the replayed price.
+// The strategy starts at the first snapshot and is stopped at the end.
+func RunBacktest(ctx context.Context, s Strategy, cfg BacktestConfig) (*BacktestReport, error) {
+	if !cfg.Balance.IsPositive() {
+		cfg.Balance = NewDecimalFromInt(defaultPaperStartingBalance)
+	}
+	if cfg.TimerEvery <= 0 {
+		cfg.TimerEvery = defaultStrategyTimer
+	}
+	if !cfg.SpreadBps.IsPositive() {
+		cfg.SpreadBps = NewDecimalFromInt(defaultBacktestSpreadBps)
+	}
+	if cfg.Grid == "" {
+		cfg.Grid = GridRound
+	}
+
+	var clock time.Time
+	now := func() time.Time { return clock }
+
+	feed := &backtestFeed{spreadBps: cfg.SpreadBps, rows: map[string]MarketRow{}, last: map[int]Decimal{}}
+	paper := NewPaperExecutor(feed, cfg.Grid, cfg.Balance)
+	paper.SetClock(now)
+	router := &backtestRouter{feed: feed, specs: map[string]MarketSpec{}, paper: paper}
+
+	hub := NewMarketHub(nil, 0, 0)
+	engine := NewEngine(hub, router, cfg.TimerEvery)
+	engine.SetClock(now)
+	if err := engine.Register(s); err != nil {
+		return nil, err
+	}
+
+	report := &BacktestReport{Strategy: s.Name(), StartingEquity: cfg.Balance}
+	var (
+		queued    []Fill
+		turnover  Decimal
+		curve     equityCurve
+		funding   []FundingRate
+		started   bool
+		nextTimer time.Time
+	)
+	// fills land mid-hook; they're handed to the engine once it returns
+	paper.OnFill(func(f Fill) {
+		queued = append(queued, f)
+		report.Fills++
+		turnover = turnover.Add(f.Price.Mul(f.Size))
+	})
+	drain := func() {
+		for len(queued) > 0 {
+			f := queued[0]
+			queued = queued[1:]
+			engine.DispatchFill(f)
+		}
+	}
+	equity := func() Decimal {
+		acct := paper.Account()
+		eq := acct.Balance
+		for _, p := range acct.Positions {
+			if px, ok := feed.last[p.MarketID]; ok {
+				eq = eq.Add(px.Sub(p.AvgEntry).Mul(p.Size))
+			}
+		}
+		return eq
+	}
+
+	err := ReadRecordings(cfg.Dir, cfg.From, cfg.To, func(rec Record) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		t := rec.Time()
+		for started && !nextTimer.After(t) {
+			clock = nextTimer
+			engine.DispatchTimer(clock)
+			drain()
+			nextTimer = nextTimer.Add(cfg.TimerEvery)
+		}
+		clock = t
+		report.Records++
+
+		switch rec.Kind {
+		case RecordSpecs:
+			for _, m := range rec.Specs {
+				router.specs[m.Symbol] = m
+			}
+		case RecordFunding:
+			funding = rec.Funding
+		case RecordTrade:
+			if rec.Trade == nil {
+				return nil
+			}
+			feed.last[rec.Trade.MarketID] = rec.Trade.Price
+			paper.Match(ctx)
+			drain()
+		case RecordMarkets:
+			snap := MarketSnapshot{Markets: rec.Markets, Funding: funding, At: t, Stale: rec.Stale}
+			feed.setMarkets(rec.Markets)
+			hub.Publish(snap)
+			if !started {
+				if err := engine.Start(s.Name(), cfg.Config); err != nil {
+					return fmt.Errorf("start %s: %w", s.Name(), err)
+				}
+				started = true
+				report.From = t
+				nextTimer = t.Add(cfg.TimerEvery)
+				drain()
+			}
+			paper.Match(ctx)
+			drain()
+			engine.DispatchMarketUpdate(snap)
+			drain()
+			curve.sample(t, equity())
+			report.To = t
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, errors.New("no market snapshots in the recording range")
+	}
+
+	if st, _ := engine.Status(s.Name()); st.State == StrategyRunning {
+		_ = engine.Stop(s.Name())
+		drain()
+	}
+
+	acct := paper.Account()
+	final := equity()
+	curve.sample(clock, final)
+
+	report.FinalEquity = final
+	report.PnlUsd = final.Sub(cfg.Balance)
+	ret, _ := report.PnlUsd.Div(cfg.Balance) // Balance was defaulted above
+	report.ReturnPct = ret.Float64() * 100
+	report.MaxDrawdownUsd = curve.maxDD
+	report.MaxDrawdownPct = curve.maxDDPct
+	report.Sharpe = curve.sharpe()
+	report.TurnoverUsd = turnover
+	report.FeesUsd = acct.FeesPaid
+	report.OpenPositions = acct.SortedPositions()
+	report.Status, _ = engine.Status(s.Name())
+	report.Orders = report.Status.Orders
+	return report, nil
+}
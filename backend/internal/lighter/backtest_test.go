@@ -0,0 +1,28 @@
+// backend/internal/lighter/backtest_test.go
+package internal
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEquityCurveSharpe(t *testing.T) {
+	cases := []struct {
+		name  string
+		hours []float64
+		zero  bool
+	}{
+		{"no samples", nil, true},
+		{"one return", []float64{100, 101}, true},
+		{"flat", []float64{100, 100, 100, 100}, true},
+		{"one usable return after a zero", []float64{0, 100, 101}, true},
+		{"constant returns", []float64{100, 110, 121}, true},
+		{"varied returns", []float64{100, 101, 100.5, 102}, false},
+	}
+	for _, c := range cases {
+		got := (&equityCurve{hours: c.hours}).sharpe()
+		if math.IsNaN(got) || math.IsInf(got, 0) || (got == 0) != c.zero {
+			t.Errorf("%s: sharpe %v", c.name, got)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// backend/internal/lighter/book.go
+package internal
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errBookGap means an update didn't follow the last one applied; the
+// book must be rebuilt from a fresh snapshot.
+var errBookGap = errors.New("order book sequence gap")
+
+type BookLevel struct {
+	Price Decimal `json:"price"`
+	Size  Decimal `json:"size"`
+}
+
+// BookSnapshot is a copy of the top of one book, best levels first.
+type BookSnapshot struct {
+	MarketID  int         `json:"market_id"`
+	Bids      []BookLevel `json:"bids"`
+	Asks      []BookLevel `json:"asks"`
+	Offset    int64       `json:"offset"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// OrderBook is the local L2 state for one market, built from a stream
+// snapshot plus updates. A size of 0 in an update removes the level.
+// Levels are keyed on the exact price, never on a float.
+type OrderBook struct {
+	MarketID int
+
+	mu        sync.RWMutex
+	bids      map[string]BookLevel
+	asks      map[string]BookLevel
+	offset    int64
+	nonce     int64
+	synced    bool
+	updatedAt time.Time
+}
+
+func NewOrderBook(marketID int) *OrderBook {
+	return &OrderBook{
+		MarketID: marketID,
+		bids:     make(map[string]BookLevel),
+		asks:     make(map[string]BookLevel),
+	}
+}
+
+// applySnapshot replaces the whole book.
+func (b *OrderBook) applySnapshot(bids, asks []BookLevel, offset, nonce int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bids = make(map[string]BookLevel, len(bids))
+	b.asks = make(map[string]BookLevel, len(asks))
+	setLevels(b.bids, bids)
+	setLevels(b.asks, asks)
+	b.offset = offset
+	b.nonce = nonce
+	b.synced = true
+	b.updatedAt = time.Now()
+}
+
+// applyUpdate merges a diff. Updates older than the book are dropped; a
+// beginNonce that doesn't match the last nonce is a gap, and the book
+// goes unsynced until the next snapshot.
+func (b *OrderBook) applyUpdate(bids, asks []BookLevel, offset, beginNonce, nonce int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.synced {
+		return errBookGap
+	}
+	if offset != 0 && offset <= b.offset {
+		return nil // replay of something already applied
+	}
+	if beginNonce != 0 && b.nonce != 0 && beginNonce != b.nonce {
+		b.synced = false
+		return errBookGap
+	}
+	setLevels(b.bids, bids)
+	setLevels(b.asks, asks)
+	if offset != 0 {
+		b.offset = offset
+	}
+	if nonce != 0 {
+		b.nonce = nonce
+	}
+	b.updatedAt = time.Now()
+	return nil
+}
+
+// invalidate marks the book stale, e.g. after a disconnect.
+func (b *OrderBook) invalidate() {
+	b.mu.Lock()
+	b.synced = false
+	b.mu.Unlock()
+}
+
+// Synced is false until the first snapshot and after any gap.
+func (b *OrderBook) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// Snapshot copies the best depth levels per side; depth <= 0 means all.
+func (b *OrderBook) Snapshot(depth int) BookSnapshot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return BookSnapshot{
+		MarketID:  b.MarketID,
+		Bids:      sortedLevels(b.bids, true, depth),
+		Asks:      sortedLevels(b.asks, false, depth),
+		Offset:    b.offset,
+		UpdatedAt: b.updatedAt,
+	}
+}
+
+// setLevels keys on the canonical price string, so "3024.50" and
+// "3024.5" are the same level.
+func setLevels(side map[string]BookLevel, levels []BookLevel) {
+	for _, l := range levels {
+		key := l.Price.String()
+		if l.Size.IsZero() {
+			delete(side, key)
+			continue
+		}
+		side[key] = l
+	}
+}
+
+func sortedLevels(side map[string]BookLevel, desc bool, depth int) []BookLevel {
+	levels := make([]BookLevel, 0, len(side))
+	for _, l := range side {
+		levels = append(levels, l)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if desc {
+			return levels[j].Price.LessThan(levels[i].Price)
+		}
+		return levels[i].Price.LessThan(levels[j].Price)
+	})
+	if depth > 0 && len(levels) > depth {
+		levels = levels[:depth]
+	}
+	return levels
+}
+
+// ----- derived numbers -----
+
+// DepthBand is resting size within Bps of mid on each side.
+type DepthBand struct {
+	Bps     float64 `json:"bps"`
+	BidSize float64 `json:"bid_size"`
+	AskSize float64 `json:"ask_size"`
+	BidUsd  float64 `json:"bid_usd"`
+	AskUsd  float64 `json:"ask_usd"`
+}
+
+type BookStats struct {
+	BestBid    float64     `json:"best_bid"`
+	BestAsk    float64     `json:"best_ask"`
+	Spread     float64     `json:"spread"`
+	SpreadBps  float64     `json:"spread_bps"`
+	Mid        float64     `json:"mid"`
+	Microprice float64     `json:"microprice"` // mid weighted by top-of-book size
+	Depth      []DepthBand `json:"depth"`
+}
+
+// Stats derives top-of-book numbers and cumulative depth per band, in
+// floats: they're for display and signals, not for pricing orders. It
+// needs the full snapshot for depth to be right; a one-sided book
+// leaves the two-sided numbers at zero.
+func (s BookSnapshot) Stats(bandsBps []float64) BookStats {
+	var st BookStats
+	if len(s.Bids) == 0 || len(s.Asks) == 0 {
+		return st
+	}
+	bidPx, bidSz := s.Bids[0].Price.Float64(), s.Bids[0].Size.Float64()
+	askPx, askSz := s.Asks[0].Price.Float64(), s.Asks[0].Size.Float64()
+	st.BestBid, st.BestAsk = bidPx, askPx
+	st.Spread = askPx - bidPx
+	st.Mid = (askPx + bidPx) / 2
+	if st.Mid > 0 {
+		st.SpreadBps = st.Spread / st.Mid * 1e4
+	}
+	if sz := bidSz + askSz; sz > 0 {
+		st.Microprice = (bidPx*askSz + askPx*bidSz) / sz
+	}
+
+	st.Depth = make([]DepthBand, 0, len(bandsBps))
+	for _, bps := range bandsBps {
+		band := DepthBand{Bps: bps}
+		lo, hi := st.Mid*(1-bps/1e4), st.Mid*(1+bps/1e4)
+		for _, l := range s.Bids {
+			px, sz := l.Price.Float64(), l.Size.Float64()
+			if px < lo {
+				break
+			}
+			band.BidSize += sz
+			band.BidUsd += sz * px
+		}
+		for _, l := range s.Asks {
+			px, sz := l.Price.Float64(), l.Size.Float64()
+			if px > hi {
+				break
+			}
+			band.AskSize += sz
+			band.AskUsd += sz * px
+		}
+		st.Depth = append(st.Depth, band)
+	}
+	return st
+}
+
+// Truncate keeps the best depth levels per side; depth <= 0 keeps all.
+func (s BookSnapshot) Truncate(depth int) BookSnapshot {
+	if depth > 0 && len(s.Bids) > depth {
+		s.Bids = s.Bids[:depth]
+	}
+	if depth > 0 && len(s.Asks) > depth {
+		s.Asks = s.Asks[:depth]
+	}
+	return s
+}
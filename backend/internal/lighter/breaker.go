@@ -0,0 +1,130 @@
+// backend/internal/lighter/breaker.go
+package internal
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling upstream while the breaker
+// is open.
+var ErrCircuitOpen = errors.New("lighter upstream circuit open")
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// BreakerStatus is the breaker's state as shown on /api/status.
+type BreakerStatus struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	OpenedAt            int64  `json:"opened_at,omitempty"`
+	RetryInMs           int64  `json:"retry_in_ms,omitempty"`
+	LastError           string `json:"last_error,omitempty"`
+}
+
+// CircuitBreaker opens after threshold consecutive upstream failures and
+// fails fast for cooldown. Then one probe request is let through: success
+// closes it, failure opens it again.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probeAt  time.Time // when the half-open probe went out
+	lastErr  string
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+// newCircuitBreakerFromEnv reads LIGHTER_BREAKER_THRESHOLD and
+// LIGHTER_BREAKER_COOLDOWN.
+func newCircuitBreakerFromEnv() *CircuitBreaker {
+	threshold := defaultBreakerThreshold
+	if v := os.Getenv("LIGHTER_BREAKER_THRESHOLD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			threshold = n
+		}
+	}
+	return NewCircuitBreaker(threshold, envDuration("LIGHTER_BREAKER_COOLDOWN", defaultBreakerCooldown))
+}
+
+// Allow reports whether a request may go out now.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probeAt = now
+		return nil
+	case BreakerHalfOpen:
+		// one probe at a time; a probe that never reported back (its
+		// caller gave up) doesn't hold the breaker forever
+		if now.Sub(b.probeAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.probeAt = now
+		return nil
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+}
+
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if err != nil {
+		b.lastErr = err.Error()
+	}
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastErr,
+	}
+	if b.state != BreakerClosed {
+		st.OpenedAt = b.openedAt.Unix()
+	}
+	if b.state == BreakerOpen {
+		if left := b.cooldown - time.Since(b.openedAt); left > 0 {
+			st.RetryInMs = left.Milliseconds()
+		}
+	}
+	return st
+}
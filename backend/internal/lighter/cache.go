@@ -0,0 +1,168 @@
+// backend/internal/lighter/cache.go
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cache keys, also used for the per-endpoint TTL env vars
+const (
+	cacheOrderBookDetails = "order_book_details"
+	cacheExchangeStats    = "exchange_stats"
+	cacheFundingRates     = "funding_rates"
+)
+
+var defaultCacheTTLs = map[string]time.Duration{
+	cacheOrderBookDetails: 5 * time.Second,
+	cacheExchangeStats:    2 * time.Second,
+	cacheFundingRates:     60 * time.Second,
+}
+
+const (
+	// past this age a stale copy is no longer served on upstream errors
+	defaultMaxStale   = 10 * time.Minute
+	cacheFetchTimeout = 10 * time.Second
+)
+
+// CachedResponse is a body plus when it was fetched. Stale is set when
+// the upstream call failed and an older copy was served instead.
+type CachedResponse struct {
+	Raw       json.RawMessage
+	FetchedAt time.Time
+	Stale     bool
+}
+
+func (r CachedResponse) Age() time.Duration { return time.Since(r.FetchedAt) }
+
+type cacheEntry struct {
+	raw       json.RawMessage
+	fetchedAt time.Time
+}
+
+type cacheCall struct {
+	done chan struct{}
+	res  CachedResponse
+	err  error
+}
+
+// responseCache is a TTL cache with request coalescing: while one fetch
+// for a key is in flight, other callers wait for its result.
+type responseCache struct {
+	ttls     map[string]time.Duration
+	maxStale time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	calls   map[string]*cacheCall
+}
+
+// newResponseCacheFromEnv reads CACHE_TTL_<KEY> (Go durations, e.g.
+// CACHE_TTL_FUNDING_RATES=2m) and CACHE_MAX_STALE.
+func newResponseCacheFromEnv() *responseCache {
+	c := &responseCache{
+		ttls:     make(map[string]time.Duration, len(defaultCacheTTLs)),
+		maxStale: defaultMaxStale,
+		entries:  make(map[string]cacheEntry),
+		calls:    make(map[string]*cacheCall),
+	}
+	for key, ttl := range defaultCacheTTLs {
+		c.ttls[key] = envDuration("CACHE_TTL_"+strings.ToUpper(key), ttl)
+	}
+	c.maxStale = envDuration("CACHE_MAX_STALE", c.maxStale)
+	return c
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("bad %s %q, using %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+// get returns a fresh copy, coalescing concurrent misses into a single
+// fetch. When the fetch fails, a copy younger than maxStale is served
+// with Stale set.
+func (c *responseCache) get(ctx context.Context, key string, fetch func(context.Context) (json.RawMessage, error)) (CachedResponse, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && time.Since(e.fetchedAt) < c.ttls[key] {
+		c.mu.Unlock()
+		return CachedResponse{Raw: e.raw, FetchedAt: e.fetchedAt}, nil
+	}
+	call, ok := c.calls[key]
+	if !ok {
+		call = &cacheCall{done: make(chan struct{})}
+		c.calls[key] = call
+		go c.fill(key, call, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.res, call.err
+	case <-ctx.Done():
+		return CachedResponse{}, ctx.Err()
+	}
+}
+
+// fill runs the fetch detached from any one caller's context, so a
+// cancelled request doesn't fail everyone waiting on it.
+func (c *responseCache) fill(key string, call *cacheCall, fetch func(context.Context) (json.RawMessage, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), cacheFetchTimeout)
+	defer cancel()
+
+	raw, err := fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.calls, key)
+
+	if err == nil {
+		now := time.Now()
+		c.entries[key] = cacheEntry{raw: raw, fetchedAt: now}
+		call.res = CachedResponse{Raw: raw, FetchedAt: now}
+	} else if e, ok := c.entries[key]; ok && time.Since(e.fetchedAt) < c.maxStale {
+		log.Printf("%s fetch failed, serving %s-old copy: %v", key, time.Since(e.fetchedAt).Round(time.Second), err)
+		call.res = CachedResponse{Raw: e.raw, FetchedAt: e.fetchedAt, Stale: true}
+	} else {
+		call.err = err
+	}
+	close(call.done)
+}
+
+// CacheAges reports how old each cached endpoint is, for /api/status.
+func (c *LighterClient) CacheAges() map[string]time.Duration {
+	out := map[string]time.Duration{}
+	if c.cache == nil {
+		return out
+	}
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	for key, e := range c.cache.entries {
+		out[key] = time.Since(e.fetchedAt)
+	}
+	return out
+}
+
+func (c *LighterClient) cached(ctx context.Context, key, path string) (CachedResponse, error) {
+	fetch := func(ctx context.Context) (json.RawMessage, error) {
+		return c.doJSON(ctx, http.MethodGet, path, nil)
+	}
+	if c.cache == nil {
+		raw, err := fetch(ctx)
+		return CachedResponse{Raw: raw, FetchedAt: time.Now()}, err
+	}
+	return c.cache.get(ctx, key, fetch)
+}
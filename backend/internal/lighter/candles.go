@@ -0,0 +1,337 @@
+// backend/internal/lighter/candles.go
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Candle is one OHLCV bar. Time is the bar's open, unix seconds (UTC
+// aligned). Volume is base size, QuoteVolume is USD.
+type Candle struct {
+	Time        int64   `json:"time"`
+	Open        Decimal `json:"open"`
+	High        Decimal `json:"high"`
+	Low         Decimal `json:"low"`
+	Close       Decimal `json:"close"`
+	Volume      Decimal `json:"volume"`
+	QuoteVolume Decimal `json:"quote_volume"`
+	Trades      int     `json:"trades"`
+}
+
+// CandleResolutions are the bar sizes we build, in seconds.
+var CandleResolutions = map[string]int64{
+	"1m":  60,
+	"5m":  5 * 60,
+	"15m": 15 * 60,
+	"1h":  60 * 60,
+	"4h":  4 * 60 * 60,
+	"1d":  24 * 60 * 60,
+}
+
+const (
+	// bars kept per market+resolution; older ones fall off
+	maxCandlesPerSeries = 1500
+	candleBackfillBars  = 500
+	candleSaveInterval  = time.Minute
+)
+
+// CandleStore aggregates trades into bars for every resolution and
+// keeps them on disk under dir, one JSON file per market.
+type CandleStore struct {
+	dir string
+	lc  *LighterClient
+
+	mu     sync.Mutex
+	series map[int]map[string][]Candle // market -> resolution -> bars, oldest first
+	dirty  map[int]bool
+	filled map[int]bool // backfilled since start
+}
+
+// OpenCandleStore loads every market saved under dir.
+func OpenCandleStore(dir string, lc *LighterClient) (*CandleStore, error) {
+	s := &CandleStore{
+		dir:    dir,
+		lc:     lc,
+		series: make(map[int]map[string][]Candle),
+		dirty:  make(map[int]bool),
+		filled: make(map[int]bool),
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("candle dir: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		id, err := strconv.Atoi(trimExt(filepath.Base(f)))
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("read candles %s: %w", f, err)
+		}
+		var m map[string][]Candle
+		if err := json.Unmarshal(b, &m); err != nil {
+			log.Printf("skipping corrupt candle file %s: %v", f, err)
+			continue
+		}
+		s.series[id] = m
+	}
+	return s, nil
+}
+
+func trimExt(name string) string {
+	return name[:len(name)-len(filepath.Ext(name))]
+}
+
+// Markets lists the markets with stored bars, e.g. to resubscribe
+// their trade streams after a restart.
+func (s *CandleStore) Markets() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]int, 0, len(s.series))
+	for id := range s.series {
+		out = append(out, id)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// AddTrade folds one print into every resolution's current bar.
+func (s *CandleStore) AddTrade(marketID int, price, size Decimal, tsMs int64) {
+	if !price.IsPositive() || !size.IsPositive() {
+		return
+	}
+	sec := tsMs / 1000
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := s.marketLocked(marketID)
+	for res, step := range CandleResolutions {
+		m[res] = addToSeries(m[res], sec-sec%step, price, size)
+	}
+	s.dirty[marketID] = true
+}
+
+func addToSeries(bars []Candle, t int64, price, size Decimal) []Candle {
+	n := len(bars)
+	switch {
+	case n == 0 || bars[n-1].Time < t:
+		bars = append(bars, Candle{Time: t, Open: price, High: price, Low: price, Close: price})
+		if len(bars) > maxCandlesPerSeries {
+			bars = bars[len(bars)-maxCandlesPerSeries:]
+		}
+		n = len(bars)
+	case bars[n-1].Time > t:
+		// late print for an older bar; patch it if we still have it
+		i := sort.Search(n, func(i int) bool { return bars[i].Time >= t })
+		if i == n || bars[i].Time != t {
+			return bars
+		}
+		b := &bars[i]
+		b.High, b.Low = MaxDecimal(b.High, price), minDecimal(b.Low, price)
+		b.Volume = b.Volume.Add(size)
+		b.QuoteVolume = b.QuoteVolume.Add(size.Mul(price))
+		b.Trades++
+		return bars
+	}
+
+	b := &bars[n-1]
+	b.High, b.Low, b.Close = MaxDecimal(b.High, price), minDecimal(b.Low, price), price
+	b.Volume = b.Volume.Add(size)
+	b.QuoteVolume = b.QuoteVolume.Add(size.Mul(price))
+	b.Trades++
+	return bars
+}
+
+// Candles returns bars with from <= Time <= to (0 = open ended), the
+// newest limit of them.
+func (s *CandleStore) Candles(marketID int, res string, from, to int64, limit int) []Candle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bars := s.series[marketID][res]
+	out := make([]Candle, 0, len(bars))
+	for _, b := range bars {
+		if (from == 0 || b.Time >= from) && (to == 0 || b.Time <= to) {
+			out = append(out, b)
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// ----- backfill -----
+
+type wireCandle struct {
+	Timestamp int64   `json:"timestamp"` // ms
+	Open      Decimal `json:"open"`
+	High      Decimal `json:"high"`
+	Low       Decimal `json:"low"`
+	Close     Decimal `json:"close"`
+	Volume0   Decimal `json:"volume0"` // base
+	Volume1   Decimal `json:"volume1"` // quote
+}
+
+type candlesticksResponse struct {
+	Code         int          `json:"code"`
+	Resolution   string       `json:"resolution"`
+	Candlesticks []wireCandle `json:"candlesticks"`
+}
+
+// EnsureBackfilled pulls recent bars for every resolution from REST the
+// first time a market is asked for since start. Bars built from trades
+// since then stay; older ones are replaced by the exchange's.
+func (s *CandleStore) EnsureBackfilled(ctx context.Context, marketID int) error {
+	s.mu.Lock()
+	done := s.filled[marketID]
+	s.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	now := time.Now()
+	for res, step := range CandleResolutions {
+		start := now.Add(-time.Duration(step*candleBackfillBars) * time.Second)
+		raw, err := s.lc.Candlesticks(ctx, marketID, res, start.UnixMilli(), now.UnixMilli(), candleBackfillBars)
+		if err != nil {
+			return fmt.Errorf("backfill %d %s: %w", marketID, res, err)
+		}
+		var resp candlesticksResponse
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return fmt.Errorf("decode candlesticks: %w", err)
+		}
+
+		bars := make([]Candle, 0, len(resp.Candlesticks))
+		for _, c := range resp.Candlesticks {
+			bars = append(bars, Candle{
+				Time:        c.Timestamp / 1000,
+				Open:        c.Open,
+				High:        c.High,
+				Low:         c.Low,
+				Close:       c.Close,
+				Volume:      c.Volume0,
+				QuoteVolume: c.Volume1,
+			})
+		}
+
+		s.mu.Lock()
+		m := s.marketLocked(marketID)
+		m[res] = mergeCandles(m[res], bars)
+		s.dirty[marketID] = true
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.filled[marketID] = true
+	s.mu.Unlock()
+	return nil
+}
+
+// mergeCandles takes the exchange's bars for every time it covers and
+// keeps our own bars for anything newer.
+func mergeCandles(local, remote []Candle) []Candle {
+	if len(remote) == 0 {
+		return local
+	}
+	byTime := make(map[int64]Candle, len(local)+len(remote))
+	for _, c := range local {
+		byTime[c.Time] = c
+	}
+	for _, c := range remote {
+		byTime[c.Time] = c
+	}
+	out := make([]Candle, 0, len(byTime))
+	for _, c := range byTime {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time < out[j].Time })
+	if len(out) > maxCandlesPerSeries {
+		out = out[len(out)-maxCandlesPerSeries:]
+	}
+	return out
+}
+
+// ----- persistence -----
+
+// Run saves changed markets every candleSaveInterval, and once more on
+// the way out.
+func (s *CandleStore) Run(ctx context.Context) {
+	t := time.NewTicker(candleSaveInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Save(); err != nil {
+				log.Printf("candle save error: %v", err)
+			}
+			return
+		case <-t.C:
+			if err := s.Save(); err != nil {
+				log.Printf("candle save error: %v", err)
+			}
+		}
+	}
+}
+
+// Save writes every dirty market.
+func (s *CandleStore) Save() error {
+	s.mu.Lock()
+	pending := make(map[int][]byte, len(s.dirty))
+	for id := range s.dirty {
+		b, err := json.Marshal(s.series[id])
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		pending[id] = b
+	}
+	s.dirty = make(map[int]bool)
+	s.mu.Unlock()
+
+	var firstErr error
+	for id, b := range pending {
+		if err := s.writeMarket(id, b); err != nil {
+			s.mu.Lock()
+			s.dirty[id] = true // try again next round
+			s.mu.Unlock()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (s *CandleStore) writeMarket(id int, b []byte) error {
+	path := filepath.Join(s.dir, strconv.Itoa(id)+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("write candles: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *CandleStore) marketLocked(marketID int) map[string][]Candle {
+	m, ok := s.series[marketID]
+	if !ok {
+		m = make(map[string][]Candle, len(CandleResolutions))
+		s.series[marketID] = m
+	}
+	return m
+}
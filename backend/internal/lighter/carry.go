@@ -0,0 +1,369 @@
+// backend/internal/lighter/carry.go
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	CarryNeutral     = "neutral"
+	CarryDirectional = "directional"
+
+	defaultCarryCooldown = 30 * time.Second
+	carryEntryTimeout    = 5 * time.Minute
+	fundingPeriod        = 8 * time.Hour
+)
+
+// CarryConfig is the carry strategy's start config. Thresholds are the
+// gap between Lighter's funding and the reference, in bps per 8h.
+//
+//	{"symbol":"BTC","versus":"binance","style":"directional",
+//	 "entry_bps":1.5,"exit_bps":0.5,"size_usd":1000}
+type CarryConfig struct {
+	Symbol      string  `json:"symbol"`
+	Versus      string  `json:"versus,omitempty"` // exchange to compare with; empty: median of the others
+	Style       string  `json:"style"`            // directional (default); neutral is refused, see validate
+	EntryBps    Decimal `json:"entry_bps"`
+	ExitBps     Decimal `json:"exit_bps"` // at or under: close; must be below entry_bps
+	SizeUSD     Decimal `json:"size_usd"`
+	Leverage    float64 `json:"leverage"`
+	CooldownSec int     `json:"cooldown_sec"` // between orders; default 30
+	CloseOnStop bool    `json:"close_on_stop"`
+	Mode        string  `json:"mode,omitempty"` // live | paper; empty follows TRADING_MODE
+}
+
+func (c CarryConfig) validate() error {
+	switch {
+	case c.Symbol == "":
+		return errors.New("symbol is required")
+	case strings.EqualFold(c.Versus, ExchangeLighter):
+		return errors.New("versus must be another exchange")
+	case c.Style == CarryNeutral:
+		// it needs the opposite leg on the reference exchange, and the bot
+		// only trades Lighter
+		return errors.New("style 'neutral' needs a hedge venue, which this bot doesn't have; use 'directional'")
+	case c.Style != CarryDirectional:
+		return errors.New("style must be 'directional'")
+	case !c.EntryBps.IsPositive():
+		return errors.New("entry_bps must be > 0")
+	case c.ExitBps.Sign() < 0 || !c.ExitBps.LessThan(c.EntryBps):
+		return errors.New("need 0 <= exit_bps < entry_bps")
+	case !c.SizeUSD.IsPositive():
+		return errors.New("size_usd must be > 0")
+	case c.CooldownSec < 0:
+		return errors.New("cooldown_sec must be >= 0")
+	case c.Mode != "" && c.Mode != ModeLive && c.Mode != ModePaper:
+		return errors.New("mode must be 'live' or 'paper'")
+	}
+	return nil
+}
+
+// carryStatus is what GET /api/strategies/carry shows. Projections use
+// the current rates and notional; accrued is the same estimate summed
+// over time in the position, not funding actually paid.
+type carryStatus struct {
+	Config        CarryConfig `json:"config"`
+	LighterRate   *Decimal    `json:"lighter_rate,omitempty"`
+	VersusRate    *Decimal    `json:"versus_rate,omitempty"`
+	DivergenceBps *Decimal    `json:"divergence_bps,omitempty"`
+	Position      Decimal     `json:"position"`
+	NotionalUsd   Decimal     `json:"notional_usd"`
+	Projected8h   Decimal     `json:"projected_8h_usd"`
+	ProjectedDay  Decimal     `json:"projected_daily_usd"`
+	ProjectedApr  Decimal     `json:"projected_apr_pct"`
+	AccruedUsd    Decimal     `json:"accrued_usd"`
+	FeesPaid      Decimal     `json:"fees_paid"`
+	Entries       int         `json:"entries"`
+	Exits         int         `json:"exits"`
+	PendingEntry  string      `json:"pending_entry,omitempty"`
+}
+
+// CarryStrategy trades Lighter's funding against another exchange's.
+// When Lighter's rate sits entry_bps or more above the reference it
+// shorts on Lighter (longs there pay), and below it goes long. The
+// position is closed once the gap narrows to exit_bps or flips sign;
+// the space between the two thresholds keeps it from churning.
+//
+// It is directional: it holds only the Lighter leg and earns Lighter's
+// rate, price risk and all. A delta-neutral carry would need the
+// opposite leg on the reference exchange, which this bot can't trade, so
+// style "neutral" is refused rather than run unhedged.
+//
+// An entry order is pending until its first fill; no other entry goes
+// out meanwhile. One that never fills is taken as rejected after
+// carryEntryTimeout, since strategies only hear about fills.
+type CarryStrategy struct {
+	cfg CarryConfig
+	mkt MarketSpec
+
+	lighter, versus *Decimal
+	position        Decimal
+	mark            Decimal
+	accrued         Decimal
+	accruedAt       time.Time
+	fees            Decimal
+	entries, exits  int
+	lastOrder       time.Time
+	pendingEntry    string // entry order id, until it fills
+	pendingAt       time.Time
+}
+
+func NewCarryStrategy() *CarryStrategy { return &CarryStrategy{} }
+
+func (c *CarryStrategy) Name() string { return "carry" }
+
+func (c *CarryStrategy) Configure(raw json.RawMessage) error {
+	var cfg CarryConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return err
+	}
+	cfg.Style = strings.ToLower(cfg.Style)
+	if cfg.Style == "" {
+		cfg.Style = CarryDirectional
+	}
+	cfg.Versus = strings.ToLower(cfg.Versus)
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	c.cfg = cfg
+	return nil
+}
+
+func (c *CarryStrategy) OnStart(sc *StrategyContext) error {
+	if c.cfg.Symbol == "" {
+		return errors.New("carry needs a config: symbol, entry_bps, exit_bps, size_usd")
+	}
+	mkt, err := sc.MarketSpec(c.cfg.Symbol)
+	if err != nil {
+		return fmt.Errorf("market %s: %w", c.cfg.Symbol, err)
+	}
+	if c.cfg.SizeUSD.LessThan(mkt.MinQuoteAmount) {
+		return fmt.Errorf("size_usd %v is under the %s minimum of %v", c.cfg.SizeUSD, mkt.Symbol, mkt.MinQuoteAmount)
+	}
+	*c = CarryStrategy{cfg: c.cfg, mkt: mkt}
+	c.update(sc.Markets())
+	sc.Logf("carry on %s vs %s, entry %v bps, exit %v bps", c.cfg.Symbol, c.versusName(), c.cfg.EntryBps, c.cfg.ExitBps)
+	return nil
+}
+
+func (c *CarryStrategy) versusName() string {
+	if c.cfg.Versus == "" {
+		return "median"
+	}
+	return c.cfg.Versus
+}
+
+// update takes the symbol's mark and funding out of a snapshot and
+// accrues carry for the time since the last one.
+func (c *CarryStrategy) update(snap MarketSnapshot) {
+	for _, m := range snap.Markets {
+		if m.Symbol == c.cfg.Symbol {
+			if px := m.RefPrice(); px.IsPositive() {
+				c.mark = px
+			}
+			break
+		}
+	}
+
+	if len(snap.Funding) > 0 {
+		c.lighter, c.versus = nil, nil
+		byEx := NewFundingTable(snap.Funding)[c.cfg.Symbol]
+		if r, ok := byEx[ExchangeLighter]; ok {
+			c.lighter = &r
+		}
+		if c.cfg.Versus != "" {
+			if r, ok := byEx[c.cfg.Versus]; ok {
+				c.versus = &r
+			}
+		} else {
+			c.versus = medianRate(byEx)
+		}
+	}
+
+	if !snap.At.IsZero() {
+		if !c.accruedAt.IsZero() && snap.At.After(c.accruedAt) {
+			dt := NewDecimalFromInt(int64(snap.At.Sub(c.accruedAt)))
+			accrued, _ := c.projected8h().Mul(dt).Div(NewDecimalFromInt(int64(fundingPeriod)))
+			c.accrued = c.accrued.Add(accrued)
+		}
+		c.accruedAt = snap.At
+	}
+}
+
+// medianRate is the median of every exchange but Lighter.
+func medianRate(byEx map[string]Decimal) *Decimal {
+	var rates []Decimal
+	for ex, r := range byEx {
+		if ex != ExchangeLighter {
+			rates = append(rates, r)
+		}
+	}
+	if len(rates) == 0 {
+		return nil
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].LessThan(rates[j]) })
+	mid := rates[len(rates)/2]
+	if len(rates)%2 == 0 {
+		mid, _ = mid.Add(rates[len(rates)/2-1]).Div(NewDecimalFromInt(2))
+	}
+	return &mid
+}
+
+// divergence is Lighter's rate minus the reference, in bps.
+func (c *CarryStrategy) divergence() (Decimal, bool) {
+	if c.lighter == nil || c.versus == nil {
+		return Decimal{}, false
+	}
+	return c.lighter.Sub(*c.versus).Shift(4), true
+}
+
+// projected8h is what the position earns over one funding period at
+// Lighter's current rate; with no hedge, the reference's rate earns it
+// nothing. Shorts receive a positive rate.
+func (c *CarryStrategy) projected8h() Decimal {
+	if c.position.IsZero() || c.lighter == nil {
+		return Decimal{}
+	}
+	return c.lighter.Mul(c.position.Mul(c.mark)).Neg()
+}
+
+func (c *CarryStrategy) OnMarketUpdate(sc *StrategyContext, snap MarketSnapshot) {
+	c.update(snap)
+	if snap.Stale || sc.Now().Sub(c.lastOrder) < c.cooldown() {
+		return
+	}
+	div, ok := c.divergence()
+	if !ok {
+		return
+	}
+
+	if c.position.IsZero() {
+		if c.pendingEntry != "" {
+			if sc.Now().Sub(c.pendingAt) < carryEntryTimeout {
+				return
+			}
+			sc.Logf("entry %s unfilled after %s, taking it as rejected", c.pendingEntry, carryEntryTimeout)
+			c.pendingEntry = ""
+		}
+		if div.Abs().LessThan(c.cfg.EntryBps) {
+			return
+		}
+		side := "buy"
+		if div.Sign() > 0 {
+			side = "sell"
+		}
+		c.enter(sc, side, div)
+		return
+	}
+
+	// exit on the narrow threshold, or once the gap turns against us
+	flipped := div.Sign() != 0 && div.Sign() == c.position.Sign()
+	if flipped || !c.cfg.ExitBps.LessThan(div.Abs()) {
+		c.exit(sc, fmt.Sprintf("divergence %v bps", div.Round(2)))
+	}
+}
+
+func (c *CarryStrategy) cooldown() time.Duration {
+	if c.cfg.CooldownSec == 0 {
+		return defaultCarryCooldown
+	}
+	return time.Duration(c.cfg.CooldownSec) * time.Second
+}
+
+func (c *CarryStrategy) enter(sc *StrategyContext, side string, div Decimal) {
+	c.lastOrder = sc.Now()
+	size := c.cfg.SizeUSD
+	resp, err := sc.PlaceOrder(OrderRequest{
+		Symbol:   c.cfg.Symbol,
+		Side:     side,
+		Type:     "market",
+		SizeUSD:  &size,
+		Leverage: c.cfg.Leverage,
+		Mode:     c.cfg.Mode,
+	})
+	if err != nil {
+		sc.Logf("enter %s: %v", side, err)
+		return
+	}
+	c.pendingEntry, c.pendingAt = resp.OrderID, sc.Now()
+	c.entries++
+	sc.Logf("enter %s %v USD, divergence %v bps vs %s", side, size, div.Round(2), c.versusName())
+}
+
+func (c *CarryStrategy) exit(sc *StrategyContext, reason string) {
+	side := "sell"
+	if c.position.Sign() < 0 {
+		side = "buy"
+	}
+	size := c.position.Abs().Floor(c.mkt.SizeDecimals)
+	if !size.IsPositive() {
+		return
+	}
+	c.lastOrder = sc.Now()
+	if _, err := sc.PlaceOrder(OrderRequest{
+		Symbol:        c.cfg.Symbol,
+		Side:          side,
+		Type:          "market",
+		SizeContracts: &size,
+		ReduceOnly:    true,
+		Mode:          c.cfg.Mode,
+	}); err != nil {
+		sc.Logf("exit %s: %v", side, err)
+		return
+	}
+	c.exits++
+	sc.Logf("exit %s %v: %s", side, size, reason)
+}
+
+func (c *CarryStrategy) OnFill(_ *StrategyContext, f Fill) {
+	size := f.Size
+	if f.Side == "sell" {
+		size = size.Neg()
+	}
+	c.position = c.position.Add(size)
+	c.fees = c.fees.Add(f.FeeUsd)
+	if f.OrderID == c.pendingEntry {
+		c.pendingEntry = ""
+	}
+	if c.mark.IsZero() {
+		c.mark = f.Price
+	}
+}
+
+func (c *CarryStrategy) OnTimer(*StrategyContext, time.Time) {}
+
+// OnStop leaves the position open unless close_on_stop is set.
+func (c *CarryStrategy) OnStop(sc *StrategyContext) {
+	if c.cfg.CloseOnStop && !c.position.IsZero() {
+		c.exit(sc, "strategy stopped")
+	}
+}
+
+func (c *CarryStrategy) Status() any {
+	p8h := c.projected8h()
+	st := carryStatus{
+		Config:       c.cfg,
+		LighterRate:  c.lighter,
+		VersusRate:   c.versus,
+		Position:     c.position,
+		NotionalUsd:  c.position.Mul(c.mark).Abs(),
+		Projected8h:  p8h,
+		ProjectedDay: p8h.Mul(NewDecimalFromInt(3)),
+		AccruedUsd:   c.accrued,
+		FeesPaid:     c.fees,
+		Entries:      c.entries,
+		Exits:        c.exits,
+		PendingEntry: c.pendingEntry,
+	}
+	if div, ok := c.divergence(); ok {
+		st.DivergenceBps = &div
+	}
+	// 0 when flat
+	st.ProjectedApr, _ = p8h.Mul(NewDecimalFromInt(3 * 365 * 100)).Div(st.NotionalUsd)
+	return st
+}
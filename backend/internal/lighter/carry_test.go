@@ -0,0 +1,125 @@
+// backend/internal/lighter/carry_test.go
+package internal
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// countingRouter accepts every order and numbers them o1, o2, ...
+type countingRouter struct{ placed []OrderRequest }
+
+func (r *countingRouter) PlaceOrder(_ context.Context, req OrderRequest) (*OrderResponse, error) {
+	r.placed = append(r.placed, req)
+	return &OrderResponse{OrderID: fmt.Sprintf("o%d", len(r.placed)), Status: "submitted"}, nil
+}
+func (r *countingRouter) CancelOrder(context.Context, string) error { return nil }
+func (r *countingRouter) MarketSpec(context.Context, string) (MarketSpec, error) {
+	return MarketSpec{Symbol: "BTC", SizeDecimals: 4, PriceDecimals: 1}, nil
+}
+
+func carrySnapshot(at time.Time, rates ...string) MarketSnapshot {
+	snap := MarketSnapshot{At: at, Markets: []MarketRow{{Symbol: "BTC", MarkPrice: MustDecimal("100000")}}}
+	for i, ex := range []string{ExchangeLighter, "binance", "bybit"} {
+		if i < len(rates) {
+			snap.Funding = append(snap.Funding, FundingRate{Exchange: ex, Symbol: "BTC", Rate: MustDecimal(rates[i])})
+		}
+	}
+	return snap
+}
+
+func startCarry(t *testing.T, cfg string) (*Engine, *countingRouter, *time.Time) {
+	t.Helper()
+	router := &countingRouter{}
+	now := time.Unix(1700000000, 0)
+	e := NewEngine(NewMarketHub(nil, 0, 0), router, time.Hour)
+	e.SetClock(func() time.Time { return now })
+	if err := e.Register(NewCarryStrategy()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Start("carry", []byte(cfg)); err != nil {
+		t.Fatal(err)
+	}
+	return e, router, &now
+}
+
+func TestCarryWaitsForPendingEntry(t *testing.T) {
+	e, router, now := startCarry(t, `{"symbol":"BTC","versus":"binance","entry_bps":1,"exit_bps":0.5,"size_usd":1000,"cooldown_sec":1}`)
+
+	// lighter 3 bps over binance: short, then keep seeing the gap
+	steps := []struct {
+		name   string
+		after  time.Duration
+		fill   string // order id to fill before the update
+		orders int
+	}{
+		{"enters", 0, "", 1},
+		{"pending past the cooldown: no second entry", 10 * time.Second, "", 1},
+		{"still pending", carryEntryTimeout - time.Minute, "", 1},
+		{"unfilled past the timeout: enters again", 2 * time.Minute, "", 2},
+		{"filled: holds, no entry", 10 * time.Second, "o2", 2},
+	}
+	for _, s := range steps {
+		*now = now.Add(s.after)
+		if s.fill != "" {
+			e.DispatchFill(Fill{OrderID: s.fill, Symbol: "BTC", Side: "sell", Price: MustDecimal("100000"), Size: MustDecimal("0.01")})
+		}
+		e.DispatchMarketUpdate(carrySnapshot(*now, "0.0004", "0.0001"))
+		if len(router.placed) != s.orders {
+			t.Errorf("%s: %d orders, want %d", s.name, len(router.placed), s.orders)
+		}
+	}
+	st, _ := e.Status("carry")
+	if d := st.Detail.(carryStatus); d.PendingEntry != "" || d.Position.String() != "-0.01" {
+		t.Errorf("pending %q, position %s; want none, -0.01", d.PendingEntry, d.Position)
+	}
+}
+
+func TestCarryConfigure(t *testing.T) {
+	cases := []struct {
+		name  string
+		cfg   string
+		style string // "" for refused
+	}{
+		{"directional by default", `{"symbol":"BTC","entry_bps":1,"exit_bps":0.5,"size_usd":1000}`, CarryDirectional},
+		{"directional", `{"symbol":"BTC","style":"Directional","entry_bps":1,"exit_bps":0.5,"size_usd":1000}`, CarryDirectional},
+		{"neutral has no hedge venue", `{"symbol":"BTC","style":"neutral","entry_bps":1,"exit_bps":0.5,"size_usd":1000}`, ""},
+		{"unknown style", `{"symbol":"BTC","style":"hedged","entry_bps":1,"exit_bps":0.5,"size_usd":1000}`, ""},
+	}
+	for _, c := range cases {
+		s := NewCarryStrategy()
+		err := s.Configure([]byte(c.cfg))
+		if c.style == "" {
+			if err == nil {
+				t.Errorf("%s: accepted, want refused", c.name)
+			}
+			continue
+		}
+		if err != nil || s.cfg.Style != c.style {
+			t.Errorf("%s: style %q, %v; want %q", c.name, s.cfg.Style, err, c.style)
+		}
+	}
+}
+
+func TestCarryProjectsLighterRateOnly(t *testing.T) {
+	e, router, now := startCarry(t, `{"symbol":"BTC","entry_bps":1,"exit_bps":0.5,"size_usd":1000}`)
+
+	// lighter 3 bps under the median of the others: long 0.01 at 100000
+	e.DispatchMarketUpdate(carrySnapshot(*now, "-0.0002", "0.0001", "0.0001"))
+	if len(router.placed) != 1 || router.placed[0].Side != "buy" {
+		t.Fatalf("orders %+v, want one buy", router.placed)
+	}
+	e.DispatchFill(Fill{OrderID: "o1", Symbol: "BTC", Side: "buy", Price: MustDecimal("100000"), Size: MustDecimal("0.01")})
+
+	// a long earns lighter's -2 bps on 1000 notional; the others' rate
+	// earns an unhedged position nothing
+	*now = now.Add(fundingPeriod)
+	e.DispatchMarketUpdate(carrySnapshot(*now, "-0.0002", "0.0001", "0.0001"))
+	st, _ := e.Status("carry")
+	d := st.Detail.(carryStatus)
+	if d.Projected8h.String() != "0.2" || d.AccruedUsd.String() != "0.2" {
+		t.Errorf("projected %s, accrued %s; want 0.2, 0.2", d.Projected8h, d.AccruedUsd)
+	}
+}
@@ -4,10 +4,14 @@ package internal
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,6 +19,9 @@ import (
 type LighterClient struct {
 	baseURL string
 	http    *http.Client
+	cache   *responseCache // market data endpoints; nil = no caching
+	limiter *TokenBucket
+	breaker *CircuitBreaker
 }
 
 // NewLighterClientFromEnv builds a client using LIGHTER_BASE_URL, or mainnet default.
@@ -29,6 +36,9 @@ func NewLighterClientFromEnv() *LighterClient {
 		http: &http.Client{
 			Timeout: 5 * time.Second,
 		},
+		cache:   newResponseCacheFromEnv(),
+		limiter: newTokenBucketFromEnv(),
+		breaker: newCircuitBreakerFromEnv(),
 	}
 }
 
@@ -39,56 +49,221 @@ func (c *LighterClient) doJSON(
 	path string,
 	query map[string]string,
 ) (json.RawMessage, error) {
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	build := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if query != nil {
+			q := req.URL.Query()
+			for k, v := range query {
+				q.Set(k, v)
+			}
+			req.URL.RawQuery = q.Encode()
+		}
+		return req, nil
+	}
+
+	op := method + " " + path
+	status, bodyBytes, err := c.roundTrip(ctx, path, method == http.MethodGet, build)
 	if err != nil {
+		return nil, c.transportError(op, err)
+	}
+	if err := checkResponse(op, status, bodyBytes); err != nil {
 		return nil, err
 	}
 
-	if query != nil {
-		q := req.URL.Query()
-		for k, v := range query {
-			q.Set(k, v)
+	var raw json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &raw); err != nil {
+		return nil, fmt.Errorf("%s: decode: %w", op, err)
+	}
+	return raw, nil
+}
+
+// ----- transport: rate limit, retry, circuit breaker -----
+
+const (
+	maxGetAttempts = 3
+	retryBaseDelay = 250 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+	// a Retry-After longer than this isn't worth waiting on
+	maxRetryAfter = 30 * time.Second
+)
+
+// roundTrip sends one logical request. It waits on the token bucket for
+// the endpoint's weight and refuses fast while the breaker is open.
+// Idempotent requests are retried on network errors, 429 and 5xx with
+// jittered backoff (or the server's Retry-After). The final status and
+// body are returned for the caller to interpret.
+func (c *LighterClient) roundTrip(ctx context.Context, path string, idempotent bool, build func() (*http.Request, error)) (int, []byte, error) {
+	attempts := 1
+	if idempotent {
+		attempts = maxGetAttempts
+	}
+
+	var (
+		status int
+		header http.Header
+		body   []byte
+		err    error
+	)
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			delay := retryDelay(attempt, header)
+			if dl, ok := ctx.Deadline(); ok && time.Until(dl) < delay {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return 0, nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		status, header, body, err = c.attempt(ctx, path, build)
+		if ctx.Err() != nil {
+			return 0, nil, ctx.Err()
+		}
+		if errors.Is(err, ErrCircuitOpen) || !retryable(status, err) {
+			break
+		}
+	}
+	return status, body, err
+}
+
+func (c *LighterClient) attempt(ctx context.Context, path string, build func() (*http.Request, error)) (int, http.Header, []byte, error) {
+	if c.breaker != nil {
+		if err := c.breaker.Allow(); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx, endpointWeight(path)); err != nil {
+			return 0, nil, nil, err
 		}
-		req.URL.RawQuery = q.Encode()
 	}
 
+	req, err := build()
+	if err != nil {
+		return 0, nil, nil, err
+	}
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return nil, err
+		c.recordOutcome(ctx, 0, err)
+		return 0, nil, nil, err
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
+	c.recordOutcome(ctx, resp.StatusCode, err)
 	if err != nil {
-		return nil, err
+		return 0, nil, nil, err
 	}
+	return resp.StatusCode, resp.Header, bodyBytes, nil
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("%s %s => %d: %s", method, path, resp.StatusCode, string(bodyBytes))
+// recordOutcome feeds the breaker. Only upstream trouble counts: our own
+// cancellations and 4xx answers say nothing about Lighter's health.
+func (c *LighterClient) recordOutcome(ctx context.Context, status int, err error) {
+	if c.breaker == nil || ctx.Err() != nil {
+		return
 	}
+	switch {
+	case err != nil:
+		c.breaker.Failure(err)
+	case status == http.StatusTooManyRequests || status >= 500:
+		c.breaker.Failure(fmt.Errorf("http %d", status))
+	default:
+		c.breaker.Success()
+	}
+}
 
-	var raw json.RawMessage
-	if err := json.Unmarshal(bodyBytes, &raw); err != nil {
-		return nil, err
+func retryable(status int, err error) bool {
+	if err != nil {
+		return true // network error
 	}
-	return raw, nil
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryDelay honors Retry-After (seconds or an HTTP date) when the
+// server sent one, else full-jitter exponential backoff.
+func retryDelay(attempt int, h http.Header) time.Duration {
+	if d, ok := parseRetryAfter(h.Get("Retry-After")); ok {
+		if d > maxRetryAfter {
+			d = maxRetryAfter
+		}
+		return d
+	}
+	ceil := retryBaseDelay << uint(attempt)
+	if ceil > retryMaxDelay {
+		ceil = retryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceil)) + 1)
+}
+
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+// UpstreamStatus is the transport's health for /api/status.
+type UpstreamStatus struct {
+	Breaker         BreakerStatus `json:"breaker"`
+	RateLimitTokens float64       `json:"rate_limit_tokens"`
+}
+
+func (c *LighterClient) UpstreamStatus() UpstreamStatus {
+	var st UpstreamStatus
+	if c.breaker != nil {
+		st.Breaker = c.breaker.Status()
+	}
+	if c.limiter != nil {
+		st.RateLimitTokens = c.limiter.Available()
+	}
+	return st
 }
 
 // ----- Public market endpoints -----
 
 // OrderBookDetails hits /api/v1/orderBookDetails (list of markets, OI, fees, etc.).
 func (c *LighterClient) OrderBookDetails(ctx context.Context) (json.RawMessage, error) {
-	return c.doJSON(ctx, http.MethodGet, "/api/v1/orderBookDetails", nil)
+	r, err := c.OrderBookDetailsCached(ctx)
+	return r.Raw, err
 }
 
 // ExchangeStats hits /api/v1/exchangeStats (prices + 24h stats).
 func (c *LighterClient) ExchangeStats(ctx context.Context) (json.RawMessage, error) {
-	return c.doJSON(ctx, http.MethodGet, "/api/v1/exchangeStats", nil)
+	r, err := c.ExchangeStatsCached(ctx)
+	return r.Raw, err
 }
 
 // FundingRates hits /api/v1/funding-rates (multi-exchange funding data).
 func (c *LighterClient) FundingRates(ctx context.Context) (json.RawMessage, error) {
-	return c.doJSON(ctx, http.MethodGet, "/api/v1/funding-rates", nil)
+	r, err := c.FundingRatesCached(ctx)
+	return r.Raw, err
+}
+
+// The *Cached variants also say how old the data is and whether it's a
+// stale copy served because upstream failed.
+
+func (c *LighterClient) OrderBookDetailsCached(ctx context.Context) (CachedResponse, error) {
+	return c.cached(ctx, cacheOrderBookDetails, "/api/v1/orderBookDetails")
+}
+
+func (c *LighterClient) ExchangeStatsCached(ctx context.Context) (CachedResponse, error) {
+	return c.cached(ctx, cacheExchangeStats, "/api/v1/exchangeStats")
+}
+
+func (c *LighterClient) FundingRatesCached(ctx context.Context) (CachedResponse, error) {
+	return c.cached(ctx, cacheFundingRates, "/api/v1/funding-rates")
 }
 
 // Fundings hits /api/v1/fundings (historical funding events).
@@ -101,47 +276,82 @@ func (c *LighterClient) Liquidations(ctx context.Context, query map[string]strin
 	return c.doJSON(ctx, http.MethodGet, "/api/v1/liquidations", query)
 }
 
+// Candlesticks hits /api/v1/candlesticks for one market and resolution
+// ("1m", "5m", ... "1d"). Timestamps are unix milliseconds.
+func (c *LighterClient) Candlesticks(ctx context.Context, marketID int, resolution string, startMs, endMs int64, countBack int) (json.RawMessage, error) {
+	return c.doJSON(ctx, http.MethodGet, "/api/v1/candlesticks", map[string]string{
+		"market_id":       strconv.Itoa(marketID),
+		"resolution":      resolution,
+		"start_timestamp": strconv.FormatInt(startMs, 10),
+		"end_timestamp":   strconv.FormatInt(endMs, 10),
+		"count_back":      strconv.Itoa(countBack),
+	})
+}
+
+// ----- Tx nonce -----
+
+type NextNonceResponse struct {
+	Code  int   `json:"code"`
+	Nonce int64 `json:"nonce"`
+}
+
+// NextNonce wraps GET /api/v1/nextNonce for one account + API key.
+func (c *LighterClient) NextNonce(ctx context.Context, accountIndex int64, apiKeyIndex uint8) (int64, error) {
+	raw, err := c.doJSON(ctx, http.MethodGet, "/api/v1/nextNonce", map[string]string{
+		"account_index": strconv.FormatInt(accountIndex, 10),
+		"api_key_index": strconv.Itoa(int(apiKeyIndex)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	var out NextNonceResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return 0, fmt.Errorf("decode next nonce: %w", err)
+	}
+	return out.Nonce, nil
+}
+
 // ----- Account / positions via /api/v1/account (by l1_address) -----
 
 type AccountPosition struct {
-	MarketID             int    `json:"market_id"`
-	Symbol               string `json:"symbol"`
-	InitialMarginFraction string `json:"initial_margin_fraction"`
-	OpenOrderCount       int    `json:"open_order_count"`
-	PendingOrderCount    int    `json:"pending_order_count"`
-	PositionTiedOrderCount int  `json:"position_tied_order_count"`
-	Sign                 int    `json:"sign"`
-	Position             string `json:"position"`
-	AvgEntryPrice        string `json:"avg_entry_price"`
-	PositionValue        string `json:"position_value"`
-	UnrealizedPnl        string `json:"unrealized_pnl"`
-	RealizedPnl          string `json:"realized_pnl"`
-	LiquidationPrice     string `json:"liquidation_price"`
-	MarginMode           int    `json:"margin_mode"`
-	AllocatedMargin      string `json:"allocated_margin"`
+	MarketID               int     `json:"market_id"`
+	Symbol                 string  `json:"symbol"`
+	InitialMarginFraction  Decimal `json:"initial_margin_fraction"`
+	OpenOrderCount         int     `json:"open_order_count"`
+	PendingOrderCount      int     `json:"pending_order_count"`
+	PositionTiedOrderCount int     `json:"position_tied_order_count"`
+	Sign                   int     `json:"sign"`
+	Position               Decimal `json:"position"`
+	AvgEntryPrice          Decimal `json:"avg_entry_price"`
+	PositionValue          Decimal `json:"position_value"`
+	UnrealizedPnl          Decimal `json:"unrealized_pnl"`
+	RealizedPnl            Decimal `json:"realized_pnl"`
+	LiquidationPrice       Decimal `json:"liquidation_price"`
+	MarginMode             int     `json:"margin_mode"`
+	AllocatedMargin        Decimal `json:"allocated_margin"`
 }
 
 type Account struct {
-	Code                    int               `json:"code"`
-	AccountType             int               `json:"account_type"`
-	Index                   int64             `json:"index"`
-	L1Address               string            `json:"l1_address"`
-	CancelAllTime           int64             `json:"cancel_all_time"`
-	TotalOrderCount         int               `json:"total_order_count"`
-	TotalIsolatedOrderCount int               `json:"total_isolated_order_count"`
-	PendingOrderCount       int               `json:"pending_order_count"`
-	AvailableBalance        string            `json:"available_balance"`
-	Status                  int               `json:"status"`
-	Collateral              string            `json:"collateral"`
-	AccountIndex            int64             `json:"account_index"`
-	Name                    string            `json:"name"`
-	Description             string            `json:"description"`
-	CanInvite               bool              `json:"can_invite"`
-	ReferralPointsPercentage string           `json:"referral_points_percentage"`
-	Positions               []AccountPosition `json:"positions"`
-	TotalAssetValue         string            `json:"total_asset_value"`
-	CrossAssetValue         string            `json:"cross_asset_value"`
-	Shares                  []any             `json:"shares"`
+	Code                     int               `json:"code"`
+	AccountType              int               `json:"account_type"`
+	Index                    int64             `json:"index"`
+	L1Address                string            `json:"l1_address"`
+	CancelAllTime            int64             `json:"cancel_all_time"`
+	TotalOrderCount          int               `json:"total_order_count"`
+	TotalIsolatedOrderCount  int               `json:"total_isolated_order_count"`
+	PendingOrderCount        int               `json:"pending_order_count"`
+	AvailableBalance         Decimal           `json:"available_balance"`
+	Status                   int               `json:"status"`
+	Collateral               Decimal           `json:"collateral"`
+	AccountIndex             int64             `json:"account_index"`
+	Name                     string            `json:"name"`
+	Description              string            `json:"description"`
+	CanInvite                bool              `json:"can_invite"`
+	ReferralPointsPercentage string            `json:"referral_points_percentage"`
+	Positions                []AccountPosition `json:"positions"`
+	TotalAssetValue          string            `json:"total_asset_value"`
+	CrossAssetValue          string            `json:"cross_asset_value"`
+	Shares                   []any             `json:"shares"`
 }
 
 type AccountByL1Response struct {
@@ -169,14 +379,14 @@ func (c *LighterClient) AccountByL1(ctx context.Context, addr string) (*AccountB
 // (Optional legacy) AccountsByL1Address using /api/v1/accountsByL1Address.
 // Not used by the current backend, but fixed here for completeness.
 type SubAccount struct {
-	Code              int    `json:"code"`
-	AccountType       int    `json:"account_type"`
-	Index             int64  `json:"index"`
-	L1Address         string `json:"l1_address"`
-	PendingOrderCount int    `json:"pending_order_count"`
-	AvailableBalance  string `json:"available_balance"`
-	Status            int    `json:"status"`
-	Collateral        string `json:"collateral"`
+	Code              int     `json:"code"`
+	AccountType       int     `json:"account_type"`
+	Index             int64   `json:"index"`
+	L1Address         string  `json:"l1_address"`
+	PendingOrderCount int     `json:"pending_order_count"`
+	AvailableBalance  Decimal `json:"available_balance"`
+	Status            int     `json:"status"`
+	Collateral        Decimal `json:"collateral"`
 }
 
 type AccountsByL1AddressResponse struct {
@@ -186,23 +396,15 @@ type AccountsByL1AddressResponse struct {
 }
 
 func (c *LighterClient) AccountsByL1Address(ctx context.Context, addr string) (*AccountsByL1AddressResponse, error) {
-	url := fmt.Sprintf("%s/api/v1/accountsByL1Address?l1_address=%s", c.baseURL, addr)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("accept", "application/json")
-
-	resp, err := c.http.Do(req)
+	raw, err := c.doJSON(ctx, http.MethodGet, "/api/v1/accountsByL1Address", map[string]string{
+		"l1_address": addr,
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
 	var out AccountsByL1AddressResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	if err := json.Unmarshal(raw, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
-}
\ No newline at end of file
+}
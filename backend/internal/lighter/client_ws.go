@@ -2,13 +2,18 @@
 package internal
 
 import (
-    // "context"
-    // "encoding/json"
-    // "log"
-    "net/http"
-    // "time"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-    "github.com/gorilla/websocket"
+	"github.com/gorilla/websocket"
 )
 
 // --- types ---
@@ -17,24 +22,517 @@ type MarketRow struct {
 	Symbol       string  `json:"symbol"`
 	MarketID     int     `json:"market_id"`
 	Status       string  `json:"status"`
-	TakerFee     string  `json:"taker_fee"`
-	MakerFee     string  `json:"maker_fee"`
-	OpenInterest float64 `json:"open_interest"`
-	// add more fields later if you want
+	TakerFee     Decimal `json:"taker_fee"`
+	MakerFee     Decimal `json:"maker_fee"`
+	OpenInterest Decimal `json:"open_interest"`
 	// these will be 0 if Lighter doesn’t send them yet
-	IndexPrice   float64 `json:"index_price"`
-	MarkPrice    float64 `json:"mark_price"`
-	Change24hPct float64 `json:"change_24h_pct"`
+	IndexPrice   Decimal `json:"index_price"`
+	MarkPrice    Decimal `json:"mark_price"`
+	Change24hPct Decimal `json:"change_24h_pct"`
+
+	// filled in by the backend's merge of stats + funding
+	OpenInterestUsd Decimal `json:"open_interest_usd"`
+	Volume24hUsd    Decimal `json:"volume_24h_usd"`
+	FundingRate8h   Decimal `json:"funding_rate_8h"`
+	// every exchange's rate for the symbol, lighter included
+	FundingRates map[string]Decimal `json:"funding_rates"`
+}
+
+// RefPrice is mark price, falling back to index; zero when neither is
+// known yet.
+func (m MarketRow) RefPrice() Decimal {
+	if !m.MarkPrice.IsZero() {
+		return m.MarkPrice
+	}
+	return m.IndexPrice
+}
+
+// StreamTrade is one print from a trade or account_all channel.
+type StreamTrade struct {
+	TradeID      int64   `json:"trade_id"`
+	TxHash       string  `json:"tx_hash"`
+	MarketID     int     `json:"market_id"`
+	Size         Decimal `json:"size"`
+	Price        Decimal `json:"price"`
+	UsdAmount    Decimal `json:"usd_amount"`
+	AskID        int64   `json:"ask_id"`
+	BidID        int64   `json:"bid_id"`
+	AskClientID  int64   `json:"ask_client_id"`
+	BidClientID  int64   `json:"bid_client_id"`
+	AskAccountID int64   `json:"ask_account_id"`
+	BidAccountID int64   `json:"bid_account_id"`
+	IsMakerAsk   bool    `json:"is_maker_ask"`
+	Timestamp    int64   `json:"timestamp"` // ms
+}
+
+// StreamMarketStats is one market's entry from market_stats.
+type StreamMarketStats struct {
+	MarketID              int     `json:"market_id"`
+	IndexPrice            Decimal `json:"index_price"`
+	MarkPrice             Decimal `json:"mark_price"`
+	LastTradePrice        Decimal `json:"last_trade_price"`
+	OpenInterest          Decimal `json:"open_interest"`
+	CurrentFundingRate    Decimal `json:"current_funding_rate"`
+	FundingRate           Decimal `json:"funding_rate"`
+	DailyPriceChange      Decimal `json:"daily_price_change"`
+	DailyBaseTokenVolume  Decimal `json:"daily_base_token_volume"`
+	DailyQuoteTokenVolume Decimal `json:"daily_quote_token_volume"`
+}
+
+// StreamHandlers are called from the read loop, so they must not block.
+// Any of them may be nil.
+type StreamHandlers struct {
+	OnBook        func(book *OrderBook)
+	OnTrades      func(marketID int, trades []StreamTrade)
+	OnMarketStats func(stats []StreamMarketStats)
+	// OnAccount gets the raw account_all payload plus its trades, new
+	// ones only (trades already seen are filtered across reconnects).
+	OnAccount func(accountID int64, raw json.RawMessage, trades []StreamTrade)
+}
+
+// ----- stream client -----
+
+const (
+	streamPingInterval = 30 * time.Second
+	streamReadTimeout  = 90 * time.Second
+	streamWriteTimeout = 10 * time.Second
+	streamMinBackoff   = time.Second
+	streamMaxBackoff   = 30 * time.Second
+	// a book that keeps gapping is resubscribed at most this often
+	streamResyncEvery = 5 * time.Second
+)
+
+// StreamClient holds one websocket to Lighter's /stream, reconnecting
+// with backoff and resubscribing everything on each new connection.
+// Order books are rebuilt from a fresh snapshot after a reconnect or a
+// sequence gap; trades are deduped on trade_id.
+type StreamClient struct {
+	url    string
+	dialer *websocket.Dialer
+	h      StreamHandlers
+
+	mu        sync.Mutex
+	channels  map[string]struct{} // "order_book/0", "market_stats/all", ...
+	books     map[int]*OrderBook
+	lastTrade map[string]int64 // channel -> highest trade_id seen
+	resyncAt  map[int]time.Time
+	conn      *websocket.Conn
+	connected bool
+
+	writeMu sync.Mutex
+}
+
+func NewStreamClient(url string, h StreamHandlers) *StreamClient {
+	return &StreamClient{
+		url:       url,
+		dialer:    websocket.DefaultDialer,
+		h:         h,
+		channels:  make(map[string]struct{}),
+		books:     make(map[int]*OrderBook),
+		lastTrade: make(map[string]int64),
+		resyncAt:  make(map[int]time.Time),
+	}
+}
+
+// NewStreamClientFromEnv uses LIGHTER_WS_URL, or the /stream endpoint
+// next to LIGHTER_BASE_URL (mainnet by default).
+func NewStreamClientFromEnv(h StreamHandlers) *StreamClient {
+	url := os.Getenv("LIGHTER_WS_URL")
+	if url == "" {
+		base := os.Getenv("LIGHTER_BASE_URL")
+		if base == "" {
+			base = "https://mainnet.zklighter.elliot.ai"
+		}
+		url = strings.Replace(strings.TrimSuffix(base, "/"), "http", "ws", 1) + "/stream"
+	}
+	return NewStreamClient(url, h)
+}
+
+func (c *StreamClient) SubscribeOrderBook(marketID int) {
+	c.mu.Lock()
+	if _, ok := c.books[marketID]; !ok {
+		c.books[marketID] = NewOrderBook(marketID)
+	}
+	c.mu.Unlock()
+	c.subscribe(fmt.Sprintf("order_book/%d", marketID))
+}
+
+func (c *StreamClient) SubscribeTrades(marketID int) {
+	c.subscribe(fmt.Sprintf("trade/%d", marketID))
+}
+
+// SubscribeMarketStats takes a market id, or -1 for every market.
+func (c *StreamClient) SubscribeMarketStats(marketID int) {
+	if marketID < 0 {
+		c.subscribe("market_stats/all")
+		return
+	}
+	c.subscribe(fmt.Sprintf("market_stats/%d", marketID))
+}
+
+func (c *StreamClient) SubscribeAccount(accountIndex int64) {
+	c.subscribe(fmt.Sprintf("account_all/%d", accountIndex))
+}
+
+// Unsubscribe drops a channel, e.g. "order_book/0".
+func (c *StreamClient) Unsubscribe(channel string) {
+	c.mu.Lock()
+	delete(c.channels, channel)
+	delete(c.lastTrade, channel)
+	if id, ok := marketFromChannel(channel, "order_book"); ok {
+		delete(c.books, id)
+	}
+	c.mu.Unlock()
+	c.send(map[string]string{"type": "unsubscribe", "channel": channel})
+}
+
+// Book returns the local book for a subscribed market.
+func (c *StreamClient) Book(marketID int) (*OrderBook, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.books[marketID]
+	return b, ok
+}
+
+func (c *StreamClient) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+func (c *StreamClient) subscribe(channel string) {
+	c.mu.Lock()
+	_, had := c.channels[channel]
+	c.channels[channel] = struct{}{}
+	c.mu.Unlock()
+	if !had {
+		c.send(map[string]string{"type": "subscribe", "channel": channel})
+	}
+}
+
+// send writes on the live connection, if any. While disconnected the
+// channel set is the record; Run resubscribes on connect.
+func (c *StreamClient) send(msg any) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	if err := c.write(conn, msg); err != nil {
+		log.Printf("lighter ws write error: %v", err)
+	}
+}
+
+func (c *StreamClient) write(conn *websocket.Conn, msg any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return conn.WriteJSON(msg)
+}
+
+// Run connects and reads until ctx is done, reconnecting on any error.
+func (c *StreamClient) Run(ctx context.Context) {
+	backoff := streamMinBackoff
+	for {
+		start := time.Now()
+		err := c.runOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > streamMaxBackoff {
+			backoff = streamMinBackoff // it was a healthy session
+		}
+		log.Printf("lighter ws disconnected: %v; reconnecting in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > streamMaxBackoff {
+			backoff = streamMaxBackoff
+		}
+	}
+}
+
+func (c *StreamClient) runOnce(ctx context.Context) error {
+	conn, _, err := c.dialer.DialContext(ctx, c.url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	c.mu.Lock()
+	c.conn = conn
+	c.connected = true
+	channels := make([]string, 0, len(c.channels))
+	for ch := range c.channels {
+		channels = append(channels, ch)
+	}
+	for _, b := range c.books {
+		b.invalidate()
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.connected = false
+		c.mu.Unlock()
+	}()
+
+	for _, ch := range channels {
+		if err := c.write(conn, map[string]string{"type": "subscribe", "channel": ch}); err != nil {
+			return err
+		}
+	}
+
+	// close the socket when ctx ends so ReadMessage returns; keep pinging
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(streamPingInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-t.C:
+				if err := c.write(conn, map[string]string{"type": "ping"}); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := c.handle(conn, data); err != nil {
+			log.Printf("lighter ws message error: %v", err)
+		}
+	}
+}
+
+// ----- message handling -----
+
+type streamEnvelope struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel"`
+	Error   json.RawMessage `json:"error"`
+}
+
+// wireLevel keeps the exact price and size; Decimal refuses empty
+// strings.
+type wireLevel struct {
+	Price Decimal `json:"price"`
+	Size  Decimal `json:"size"`
+}
+
+type wireOrderBook struct {
+	Asks       []wireLevel `json:"asks"`
+	Bids       []wireLevel `json:"bids"`
+	Offset     int64       `json:"offset"`
+	Nonce      int64       `json:"nonce"`
+	BeginNonce int64       `json:"begin_nonce"`
+}
+
+func (c *StreamClient) handle(conn *websocket.Conn, data []byte) error {
+	var env streamEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+	if len(env.Error) > 0 && string(env.Error) != "null" {
+		return fmt.Errorf("server error on %q: %s", env.Channel, env.Error)
+	}
+
+	kind := env.Type
+	if i := strings.IndexByte(kind, '/'); i >= 0 {
+		kind = kind[i+1:]
+	}
+	snapshot := strings.HasPrefix(env.Type, "subscribed/")
+
+	switch {
+	case env.Type == "ping":
+		return c.write(conn, map[string]string{"type": "pong"})
+	case kind == "order_book":
+		return c.handleBook(env.Channel, data, snapshot)
+	case kind == "trade":
+		return c.handleTrades(env.Channel, data)
+	case kind == "market_stats":
+		return c.handleMarketStats(data)
+	case kind == "account_all":
+		return c.handleAccount(env.Channel, data)
+	}
+	return nil // connected, pong, unknown channels
+}
+
+func (c *StreamClient) handleBook(channel string, data []byte, snapshot bool) error {
+	id, ok := marketFromChannel(channel, "order_book")
+	if !ok {
+		return fmt.Errorf("bad order_book channel %q", channel)
+	}
+	c.mu.Lock()
+	book := c.books[id]
+	c.mu.Unlock()
+	if book == nil {
+		return nil // unsubscribed meanwhile
+	}
+
+	var msg struct {
+		OrderBook wireOrderBook `json:"order_book"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	ob := msg.OrderBook
+	bids, asks := toLevels(ob.Bids), toLevels(ob.Asks)
+
+	if snapshot {
+		book.applySnapshot(bids, asks, ob.Offset, ob.Nonce)
+	} else if err := book.applyUpdate(bids, asks, ob.Offset, ob.BeginNonce, ob.Nonce); err != nil {
+		if !errors.Is(err, errBookGap) {
+			return err
+		}
+		c.resync(id)
+		return nil
+	}
+	if c.h.OnBook != nil {
+		c.h.OnBook(book)
+	}
+	return nil
+}
+
+// resync re-subscribes a book so the server sends a new snapshot.
+// Updates that land before it are refused by the unsynced book.
+func (c *StreamClient) resync(marketID int) {
+	c.mu.Lock()
+	if time.Since(c.resyncAt[marketID]) < streamResyncEvery {
+		c.mu.Unlock()
+		return
+	}
+	c.resyncAt[marketID] = time.Now()
+	c.mu.Unlock()
+
+	log.Printf("lighter ws order_book/%d gap, resyncing", marketID)
+	ch := fmt.Sprintf("order_book/%d", marketID)
+	c.send(map[string]string{"type": "unsubscribe", "channel": ch})
+	c.send(map[string]string{"type": "subscribe", "channel": ch})
+}
+
+func (c *StreamClient) handleTrades(channel string, data []byte) error {
+	id, ok := marketFromChannel(channel, "trade")
+	if !ok {
+		return fmt.Errorf("bad trade channel %q", channel)
+	}
+	var msg struct {
+		Trades []StreamTrade `json:"trades"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	fresh := c.newTrades(channel, msg.Trades)
+	if len(fresh) > 0 && c.h.OnTrades != nil {
+		c.h.OnTrades(id, fresh)
+	}
+	return nil
+}
+
+func (c *StreamClient) handleMarketStats(data []byte) error {
+	var msg struct {
+		MarketStats json.RawMessage `json:"market_stats"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+
+	// market_stats/all sends a map keyed by market id, a single market
+	// sends the object itself
+	var stats []StreamMarketStats
+	var all map[string]StreamMarketStats
+	if err := json.Unmarshal(msg.MarketStats, &all); err == nil {
+		for _, st := range all {
+			stats = append(stats, st)
+		}
+	} else {
+		var one StreamMarketStats
+		if err := json.Unmarshal(msg.MarketStats, &one); err != nil {
+			return err
+		}
+		stats = append(stats, one)
+	}
+	if c.h.OnMarketStats != nil {
+		c.h.OnMarketStats(stats)
+	}
+	return nil
+}
+
+func (c *StreamClient) handleAccount(channel string, data []byte) error {
+	id, ok := marketFromChannel(channel, "account_all")
+	if !ok {
+		return fmt.Errorf("bad account channel %q", channel)
+	}
+	var msg struct {
+		Trades map[string][]StreamTrade `json:"trades"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	var all []StreamTrade
+	for _, ts := range msg.Trades {
+		all = append(all, ts...)
+	}
+	fresh := c.newTrades(channel, all)
+	if c.h.OnAccount != nil {
+		c.h.OnAccount(int64(id), data, fresh)
+	}
+	return nil
 }
 
-type lighterMarketsResponse struct {
-    Code             int         `json:"code"`
-    OrderBookDetails []MarketRow `json:"order_book_details"`
+// newTrades drops trades at or below the channel's high-water mark, so
+// the history replayed on (re)subscribe isn't delivered twice.
+func (c *StreamClient) newTrades(channel string, trades []StreamTrade) []StreamTrade {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last := c.lastTrade[channel]
+	out := make([]StreamTrade, 0, len(trades))
+	high := last
+	for _, t := range trades {
+		if t.TradeID <= last {
+			continue
+		}
+		out = append(out, t)
+		if t.TradeID > high {
+			high = t.TradeID
+		}
+	}
+	c.lastTrade[channel] = high
+	return out
 }
 
-var upgrader = websocket.Upgrader{
-    CheckOrigin: func(r *http.Request) bool {
-        // dev: allow all origins (Next.js, etc.)
-        return true
-    },
-}
\ No newline at end of file
+// marketFromChannel parses "order_book:3" (or "order_book/3") into 3.
+func marketFromChannel(channel, prefix string) (int, bool) {
+	rest := strings.TrimPrefix(channel, prefix)
+	if rest == channel || rest == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest[1:])
+	return n, err == nil
+}
+
+func toLevels(in []wireLevel) []BookLevel {
+	out := make([]BookLevel, len(in))
+	for i, l := range in {
+		out[i] = BookLevel(l)
+	}
+	return out
+}
@@ -0,0 +1,213 @@
+// backend/internal/lighter/client_ws_test.go
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeWS hands each accepted connection to the test, which then plays
+// the server side of it.
+type fakeWS struct {
+	t     *testing.T
+	url   string
+	conns chan *websocket.Conn
+}
+
+func newFakeWS(t *testing.T) *fakeWS {
+	t.Helper()
+	f := &fakeWS{t: t, conns: make(chan *websocket.Conn, 4)}
+	up := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		f.conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+	f.url = "ws" + strings.TrimPrefix(srv.URL, "http")
+	return f
+}
+
+func (f *fakeWS) accept() *websocket.Conn {
+	f.t.Helper()
+	select {
+	case conn := <-f.conns:
+		f.t.Cleanup(func() { conn.Close() })
+		return conn
+	case <-time.After(5 * time.Second):
+		f.t.Fatal("client didn't connect")
+		return nil
+	}
+}
+
+// expect reads n client messages (pings skipped) as "type channel".
+func (f *fakeWS) expect(conn *websocket.Conn, n int) []string {
+	f.t.Helper()
+	var got []string
+	for len(got) < n {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg map[string]string
+		if err := conn.ReadJSON(&msg); err != nil {
+			f.t.Fatalf("reading client message: %v", err)
+		}
+		if msg["type"] != "ping" {
+			got = append(got, msg["type"]+" "+msg["channel"])
+		}
+	}
+	return got
+}
+
+func (f *fakeWS) send(conn *websocket.Conn, msg string) {
+	f.t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func waitFor[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+		var zero T
+		return zero
+	}
+}
+
+func bookString(b *OrderBook) string {
+	s := b.Snapshot(0)
+	j, _ := json.Marshal([]any{s.Bids, s.Asks})
+	return string(j)
+}
+
+func TestStreamClientReconnectResync(t *testing.T) {
+	f := newFakeWS(t)
+	books := make(chan *OrderBook, 8)
+	trades := make(chan []StreamTrade, 8)
+	c := NewStreamClient(f.url, StreamHandlers{
+		OnBook:   func(b *OrderBook) { books <- b },
+		OnTrades: func(_ int, ts []StreamTrade) { trades <- ts },
+	})
+	c.SubscribeOrderBook(1)
+	c.SubscribeTrades(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	conn := f.accept()
+	subs := f.expect(conn, 2)
+	sort.Strings(subs)
+	if strings.Join(subs, ",") != "subscribe order_book/1,subscribe trade/1" {
+		t.Fatalf("subscribed %v", subs)
+	}
+
+	// snapshot, then a contiguous update that restates a price with a
+	// trailing zero: it's the same level
+	f.send(conn, `{"type":"subscribed/order_book","channel":"order_book:1","order_book":{"bids":[{"price":"100.10","size":"2"}],"asks":[{"price":"100.20","size":"1"},{"price":"100.30","size":"4"}],"offset":1,"nonce":10}}`)
+	waitFor(t, books)
+	f.send(conn, `{"type":"update/order_book","channel":"order_book:1","order_book":{"bids":[{"price":"100.1","size":"3"}],"asks":[{"price":"100.2","size":"0"}],"offset":2,"begin_nonce":10,"nonce":11}}`)
+	book := waitFor(t, books)
+	if got, want := bookString(book), `[[{"price":100.1,"size":3}],[{"price":100.3,"size":4}]]`; got != want {
+		t.Fatalf("book %s, want %s", got, want)
+	}
+
+	// a gap unsyncs the book and resubscribes it
+	f.send(conn, `{"type":"update/order_book","channel":"order_book:1","order_book":{"bids":[{"price":"99","size":"1"}],"offset":3,"begin_nonce":42,"nonce":43}}`)
+	if got := f.expect(conn, 2); strings.Join(got, ",") != "unsubscribe order_book/1,subscribe order_book/1" {
+		t.Fatalf("resync sent %v", got)
+	}
+	if book.Synced() {
+		t.Fatal("book still synced after a gap")
+	}
+	f.send(conn, `{"type":"subscribed/order_book","channel":"order_book:1","order_book":{"bids":[{"price":"99.5","size":"1"}],"asks":[{"price":"100.5","size":"1"}],"offset":5,"nonce":50}}`)
+	waitFor(t, books)
+	if got, want := bookString(book), `[[{"price":99.5,"size":1}],[{"price":100.5,"size":1}]]`; !book.Synced() || got != want {
+		t.Fatalf("resynced book %s (synced %v), want %s", got, book.Synced(), want)
+	}
+
+	f.send(conn, `{"type":"update/trade","channel":"trade:1","trades":[{"trade_id":1,"market_id":1,"price":"100","size":"1"},{"trade_id":2,"market_id":1,"price":"100","size":"1"}]}`)
+	if ts := waitFor(t, trades); len(ts) != 2 {
+		t.Fatalf("got %d trades, want 2", len(ts))
+	}
+
+	// drop the connection: the client comes back, resubscribes both
+	// channels and holds the book unsynced until the new snapshot
+	conn.Close()
+	conn = f.accept()
+	subs = f.expect(conn, 2)
+	sort.Strings(subs)
+	if strings.Join(subs, ",") != "subscribe order_book/1,subscribe trade/1" {
+		t.Fatalf("resubscribed %v", subs)
+	}
+	if book.Synced() {
+		t.Fatal("book synced across a reconnect")
+	}
+	f.send(conn, `{"type":"update/order_book","channel":"order_book:1","order_book":{"bids":[{"price":"1","size":"1"}],"offset":6,"begin_nonce":50,"nonce":51}}`)
+	f.send(conn, `{"type":"subscribed/order_book","channel":"order_book:1","order_book":{"bids":[{"price":"99.6","size":"2"}],"asks":[{"price":"100.4","size":"2"}],"offset":7,"nonce":60}}`)
+	waitFor(t, books)
+	if got, want := bookString(book), `[[{"price":99.6,"size":2}],[{"price":100.4,"size":2}]]`; got != want {
+		t.Fatalf("book after reconnect %s, want %s", got, want)
+	}
+
+	// replayed trade history isn't delivered twice
+	f.send(conn, `{"type":"subscribed/trade","channel":"trade:1","trades":[{"trade_id":2,"market_id":1,"price":"100","size":"1"},{"trade_id":3,"market_id":1,"price":"101","size":"1"}]}`)
+	if ts := waitFor(t, trades); len(ts) != 1 || ts[0].TradeID != 3 {
+		t.Fatalf("after replay got %+v, want only trade 3", ts)
+	}
+}
+
+func TestHandleMarketStats(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		want    string // market_id:mark_price:funding_rate, sorted by id
+		wantErr bool
+	}{
+		{"one market, strings", `{"market_stats":{"market_id":1,"mark_price":"3012.25","funding_rate":"-0.000012"}}`, "1:3012.25:-0.000012", false},
+		{"all markets, numbers", `{"market_stats":{"0":{"market_id":0,"mark_price":1.5},"2":{"market_id":2,"mark_price":"0.1","funding_rate":null}}}`, "0:1.5:0 2:0.1:0", false},
+		{"empty string is refused", `{"market_stats":{"market_id":1,"mark_price":""}}`, "", true},
+		{"not a number", `{"market_stats":{"market_id":1,"mark_price":"abc"}}`, "", true},
+	}
+	for _, c := range cases {
+		var got []string
+		sc := &StreamClient{h: StreamHandlers{OnMarketStats: func(stats []StreamMarketStats) {
+			sort.Slice(stats, func(i, j int) bool { return stats[i].MarketID < stats[j].MarketID })
+			for _, st := range stats {
+				got = append(got, fmt.Sprintf("%d:%s:%s", st.MarketID, st.MarkPrice, st.FundingRate))
+			}
+		}}}
+		err := sc.handleMarketStats([]byte(c.in))
+		if (err != nil) != c.wantErr || strings.Join(got, " ") != c.want {
+			t.Errorf("%s: got %q, %v", c.name, strings.Join(got, " "), err)
+		}
+	}
+}
+
+func TestOrderBookLevelsKeyedOnPrice(t *testing.T) {
+	b := NewOrderBook(1)
+	lvl := func(p, s string) BookLevel { return BookLevel{MustDecimal(p), MustDecimal(s)} }
+
+	b.applySnapshot([]BookLevel{lvl("0.3", "1"), lvl("0.1", "1")}, nil, 1, 1)
+	// "0.30" and "0.3" are the same level
+	if err := b.applyUpdate([]BookLevel{lvl("0.30", "0")}, nil, 2, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	s := b.Snapshot(0)
+	if len(s.Bids) != 1 || s.Bids[0].Price.String() != "0.1" {
+		t.Fatalf("bids %+v, want only 0.1", s.Bids)
+	}
+}
@@ -3,5 +3,12 @@ package internal
 const LIGHTER_BASE_URL = "mainnet.zklighter.elliot.ai"
 const LIGHTER_TEST_URL = "testnet.zklighter.elliot.ai"
 
- const LIGHTER_API_PRIVATE_KEY = ""
- const ACCOUNT_INDEX = ""
\ No newline at end of file
+// Env var names for the trading credentials. Secrets never live in source.
+const LIGHTER_API_PRIVATE_KEY = "LIGHTER_API_PRIVATE_KEY"
+const ACCOUNT_INDEX = "LIGHTER_ACCOUNT_INDEX"
+const API_KEY_INDEX = "LIGHTER_API_KEY_INDEX"
+const CHAIN_ID = "LIGHTER_CHAIN_ID"
+
+// Chain ids used in the signed tx hash.
+const MAINNET_CHAIN_ID = 304
+const TESTNET_CHAIN_ID = 300
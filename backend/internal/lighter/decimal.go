@@ -0,0 +1,290 @@
+// backend/internal/lighter/decimal.go
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DecimalPlaces is how many fractional digits a Decimal keeps. Results
+// with more are rounded half away from zero.
+const DecimalPlaces = 18
+
+const maxDecimalExponent = 64
+
+// ErrDivisionByZero is what Div returns for a zero divisor.
+var ErrDivisionByZero = errors.New("decimal: division by zero")
+
+var (
+	decimalOne = pow10(DecimalPlaces)
+	bigTen     = big.NewInt(10)
+)
+
+// Decimal is an exact fixed-point number for prices, sizes and money.
+// The zero value is 0, values are immutable, and JSON is a number on
+// output; input may be a number or a numeric string.
+type Decimal struct {
+	v *big.Int // value * 10^DecimalPlaces; nil means 0
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) int() *big.Int {
+	if d.v == nil {
+		return new(big.Int)
+	}
+	return d.v
+}
+
+// ParseDecimal reads a plain decimal such as "-12.5", "0.0003" or
+// "1.2e-05". Anything else is an error.
+func ParseDecimal(s string) (Decimal, error) {
+	if s == "" {
+		return Decimal{}, fmt.Errorf("decimal: empty string")
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789.+-eE", c) {
+			return Decimal{}, fmt.Errorf("decimal: invalid number %q", s)
+		}
+	}
+	// a huge exponent would make big.Rat allocate without bound
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil || exp > maxDecimalExponent || exp < -maxDecimalExponent {
+			return Decimal{}, fmt.Errorf("decimal: invalid number %q", s)
+		}
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, fmt.Errorf("decimal: invalid number %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(decimalOne))
+	return Decimal{v: quoRound(r.Num(), r.Denom())}, nil
+}
+
+// MustDecimal is ParseDecimal for constants; it panics on bad input.
+func MustDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func NewDecimalFromInt(i int64) Decimal {
+	return Decimal{v: new(big.Int).Mul(big.NewInt(i), decimalOne)}
+}
+
+// NewDecimalFromFloat converts f by its shortest decimal form, so 0.1
+// becomes exactly 0.1. NaN and ±Inf become 0.
+func NewDecimalFromFloat(f float64) Decimal {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}
+	}
+	d, _ := ParseDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+	return d
+}
+
+// NewDecimalScaled is v / 10^decimals, e.g. an integer price or base
+// amount from a tx.
+func NewDecimalScaled(v int64, decimals int) Decimal {
+	return NewDecimalFromInt(v).Shift(-decimals)
+}
+
+// quoRound is n/d rounded half away from zero.
+func quoRound(n, d *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	r2 := new(big.Int).Abs(r)
+	r2.Lsh(r2, 1)
+	if r2.Cmp(new(big.Int).Abs(d)) >= 0 {
+		if n.Sign()*d.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// ----- arithmetic -----
+
+func (d Decimal) Add(o Decimal) Decimal {
+	return Decimal{v: new(big.Int).Add(d.int(), o.int())}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	return Decimal{v: new(big.Int).Sub(d.int(), o.int())}
+}
+
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{v: quoRound(new(big.Int).Mul(d.int(), o.int()), decimalOne)}
+}
+
+// Div is d/o. A zero divisor gives 0 and ErrDivisionByZero, so callers
+// that want 0 there can drop the error.
+func (d Decimal) Div(o Decimal) (Decimal, error) {
+	if o.IsZero() {
+		return Decimal{}, ErrDivisionByZero
+	}
+	return Decimal{v: quoRound(new(big.Int).Mul(d.int(), decimalOne), o.int())}, nil
+}
+
+func (d Decimal) Neg() Decimal { return Decimal{v: new(big.Int).Neg(d.int())} }
+
+func (d Decimal) Abs() Decimal { return Decimal{v: new(big.Int).Abs(d.int())} }
+
+// Shift multiplies by 10^n; n may be negative.
+func (d Decimal) Shift(n int) Decimal {
+	if n >= 0 {
+		return Decimal{v: new(big.Int).Mul(d.int(), pow10(n))}
+	}
+	return Decimal{v: quoRound(d.int(), pow10(-n))}
+}
+
+// Round keeps places fractional digits, rounding half away from zero.
+func (d Decimal) Round(places int) Decimal {
+	if places >= DecimalPlaces {
+		return d
+	}
+	unit := pow10(DecimalPlaces - places)
+	q := quoRound(d.int(), unit)
+	return Decimal{v: q.Mul(q, unit)}
+}
+
+// Truncate keeps places fractional digits, dropping the rest.
+func (d Decimal) Truncate(places int) Decimal {
+	if places >= DecimalPlaces {
+		return d
+	}
+	unit := pow10(DecimalPlaces - places)
+	q := new(big.Int).Quo(d.int(), unit)
+	return Decimal{v: q.Mul(q, unit)}
+}
+
+// Floor rounds down to places fractional digits.
+func (d Decimal) Floor(places int) Decimal {
+	t := d.Truncate(places)
+	if d.Sign() < 0 && !t.Equal(d) {
+		t = t.Sub(NewDecimalFromInt(1).Shift(-places))
+	}
+	return t
+}
+
+// Ceil rounds up to places fractional digits.
+func (d Decimal) Ceil(places int) Decimal {
+	t := d.Truncate(places)
+	if d.Sign() > 0 && !t.Equal(d) {
+		t = t.Add(NewDecimalFromInt(1).Shift(-places))
+	}
+	return t
+}
+
+// ----- comparison -----
+
+func (d Decimal) Cmp(o Decimal) int       { return d.int().Cmp(o.int()) }
+func (d Decimal) Equal(o Decimal) bool    { return d.Cmp(o) == 0 }
+func (d Decimal) LessThan(o Decimal) bool { return d.Cmp(o) < 0 }
+func (d Decimal) Sign() int               { return d.int().Sign() }
+func (d Decimal) IsZero() bool            { return d.Sign() == 0 }
+func (d Decimal) IsPositive() bool        { return d.Sign() > 0 }
+
+// MaxDecimal returns the larger of a and b.
+func MaxDecimal(a, b Decimal) Decimal {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// ----- conversion -----
+
+// Int64 is d's value when it is a whole number that fits an int64.
+func (d Decimal) Int64() (int64, bool) {
+	q, r := new(big.Int).QuoRem(d.int(), decimalOne, new(big.Int))
+	if r.Sign() != 0 || !q.IsInt64() {
+		return 0, false
+	}
+	return q.Int64(), true
+}
+
+// Float64 is the nearest float64, for display and float-based checks.
+func (d Decimal) Float64() float64 {
+	f, _ := new(big.Rat).SetFrac(d.int(), decimalOne).Float64()
+	return f
+}
+
+// String is the shortest exact form: "0", "-1.5", "12.34".
+func (d Decimal) String() string {
+	s := d.StringFixed(DecimalPlaces)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// StringFixed formats d rounded to exactly places fractional digits.
+func (d Decimal) StringFixed(places int) string {
+	if places > DecimalPlaces {
+		places = DecimalPlaces
+	}
+	if places < 0 {
+		places = 0
+	}
+	v := d.Round(places).int()
+	neg := v.Sign() < 0
+	digits := new(big.Int).Abs(v).String()
+	if len(digits) <= DecimalPlaces {
+		digits = strings.Repeat("0", DecimalPlaces-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-DecimalPlaces], digits[len(digits)-DecimalPlaces:]
+
+	s := whole
+	if places > 0 {
+		s += "." + frac[:places]
+	}
+	if neg && strings.Trim(s, "0.") != "" {
+		s = "-" + s
+	}
+	return s
+}
+
+// ----- JSON -----
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts 1.5 or "1.5"; null leaves d unchanged.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if string(b) == "null" {
+		return nil
+	}
+	s := string(b)
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		s = strings.TrimSpace(s)
+	}
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
@@ -0,0 +1,154 @@
+// backend/internal/lighter/decimal_test.go
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string // "" means an error
+	}{
+		{"0", "0"},
+		{"-12.50", "-12.5"},
+		{"0.0003", "0.0003"},
+		{"1.2e-05", "0.000012"},
+		{"3e2", "300"},
+		{"+7", "7"},
+		{"0.0000000000000000005", "0.000000000000000001"}, // rounds at 18 places
+		{"", ""},
+		{"abc", ""},
+		{"1.2.3", ""},
+		{"1e999", ""},
+		{"0x10", ""},
+	}
+	for _, c := range cases {
+		d, err := ParseDecimal(c.in)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("%q: parsed as %s, want an error", c.in, d)
+			}
+			continue
+		}
+		if err != nil || d.String() != c.want {
+			t.Errorf("%q: got %s, %v, want %s", c.in, d, err, c.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	d := MustDecimal
+	cases := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{"add is exact", d("0.1").Add(d("0.2")), "0.3"},
+		{"sub", d("1").Sub(d("1.25")), "-0.25"},
+		{"mul", d("3024.5").Mul(d("0.012")), "36.294"},
+		{"neg", d("2.5").Neg(), "-2.5"},
+		{"abs", d("-2.5").Abs(), "2.5"},
+		{"shift up", d("1.2345").Shift(2), "123.45"},
+		{"shift down", d("12345").Shift(-4), "1.2345"},
+		{"round half away", d("-2.345").Round(2), "-2.35"},
+		{"truncate", d("-2.349").Truncate(2), "-2.34"},
+		{"floor negative", d("-2.341").Floor(2), "-2.35"},
+		{"ceil positive", d("2.341").Ceil(2), "2.35"},
+		{"ceil exact", d("2.34").Ceil(2), "2.34"},
+		{"scaled", NewDecimalScaled(302450, 2), "3024.5"},
+		{"from float", NewDecimalFromFloat(0.1), "0.1"},
+		{"max", MaxDecimal(d("1"), d("-3")), "1"},
+	}
+	for _, c := range cases {
+		if c.got.String() != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestDecimalDiv(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want string
+		err  error
+	}{
+		{"1", "3", "0.333333333333333333", nil},
+		{"2", "3", "0.666666666666666667", nil},
+		{"-10", "4", "-2.5", nil},
+		{"5", "0", "0", ErrDivisionByZero},
+		{"0", "0", "0", ErrDivisionByZero},
+	}
+	for _, c := range cases {
+		got, err := MustDecimal(c.a).Div(MustDecimal(c.b))
+		if !errors.Is(err, c.err) || got.String() != c.want {
+			t.Errorf("%s/%s: got %s, %v, want %s, %v", c.a, c.b, got, err, c.want, c.err)
+		}
+	}
+}
+
+func TestDecimalStringFixed(t *testing.T) {
+	cases := []struct {
+		in     string
+		places int
+		want   string
+	}{
+		{"1.005", 2, "1.01"},
+		{"-0.001", 2, "0.00"},
+		{"42", 3, "42.000"},
+		{"0.5", 0, "1"},
+	}
+	for _, c := range cases {
+		if got := MustDecimal(c.in).StringFixed(c.places); got != c.want {
+			t.Errorf("%s to %d: got %s, want %s", c.in, c.places, got, c.want)
+		}
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{`1.5`, "1.5", false},
+		{`"1.5"`, "1.5", false},
+		{`" 2 "`, "2", false},
+		{`null`, "7", false}, // leaves the value as it was
+		{`""`, "", true},
+		{`"x"`, "", true},
+		{`true`, "", true},
+	}
+	for _, c := range cases {
+		d := NewDecimalFromInt(7)
+		err := json.Unmarshal([]byte(c.in), &d)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: decoded as %s, want an error", c.in, d)
+			}
+			continue
+		}
+		if err != nil || d.String() != c.want {
+			t.Errorf("%s: got %s, %v, want %s", c.in, d, err, c.want)
+		}
+	}
+
+	b, err := json.Marshal(struct{ P Decimal }{MustDecimal("3024.50")})
+	if err != nil || string(b) != `{"P":3024.5}` {
+		t.Errorf("marshal: %s, %v", b, err)
+	}
+}
+
+func TestDecimalInt64(t *testing.T) {
+	if n, ok := MustDecimal("42").Int64(); !ok || n != 42 {
+		t.Errorf("42: got %d, %v", n, ok)
+	}
+	if _, ok := MustDecimal("4.2").Int64(); ok {
+		t.Error("4.2 converted to an int64")
+	}
+	if _, ok := MustDecimal("1e30").Int64(); ok {
+		t.Error("1e30 converted to an int64")
+	}
+}
@@ -1 +1,571 @@
-package internal
\ No newline at end of file
+// backend/internal/lighter/engine.go
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownStrategy = errors.New("unknown strategy")
+	ErrStrategyRunning = errors.New("strategy already running")
+	ErrStrategyStopped = errors.New("strategy not running")
+)
+
+const (
+	StrategyStopped = "stopped"
+	StrategyRunning = "running"
+	StrategyFailed  = "failed"
+
+	defaultStrategyTimer = 5 * time.Second
+	engineFillBuffer     = 256
+	// how long a fill nobody has claimed waits for its order id
+	engineOrphanTTL = 5 * time.Minute
+)
+
+// OrderRouter is how strategies reach the exchange. The API backs it
+// with the same validation, kill-switch and risk checks as
+// /api/trade/order.
+type OrderRouter interface {
+	PlaceOrder(ctx context.Context, req OrderRequest) (*OrderResponse, error)
+	CancelOrder(ctx context.Context, orderID string) error
+	MarketSpec(ctx context.Context, symbol string) (MarketSpec, error)
+}
+
+// OrderPendingError is an OrderRouter's answer for a send that failed
+// but may still have placed the order. Resolved delivers the order if it
+// turns up, or nil once it can't have landed; the engine then routes the
+// order's fills to the strategy that sent it.
+type OrderPendingError struct {
+	Err      error
+	Resolved <-chan *OrderResponse
+}
+
+func (e *OrderPendingError) Error() string { return e.Err.Error() }
+func (e *OrderPendingError) Unwrap() error { return e.Err }
+
+// Strategy is a trading algorithm the Engine drives. Hooks are called
+// one at a time, never concurrently, and only while it is running.
+type Strategy interface {
+	Name() string
+	// OnStart runs when the strategy is started; an error leaves it stopped.
+	OnStart(sc *StrategyContext) error
+	// OnMarketUpdate runs on every hub snapshot.
+	OnMarketUpdate(sc *StrategyContext, snap MarketSnapshot)
+	// OnFill runs for fills of orders this strategy placed.
+	OnFill(sc *StrategyContext, f Fill)
+	// OnTimer runs every engine timer interval.
+	OnTimer(sc *StrategyContext, now time.Time)
+}
+
+// StrategyConfigurer is implemented by strategies that take a JSON
+// config on start.
+type StrategyConfigurer interface {
+	Configure(raw json.RawMessage) error
+}
+
+// StrategyStopper is implemented by strategies that clean up on stop
+// (cancel resting orders and the like).
+type StrategyStopper interface {
+	OnStop(sc *StrategyContext)
+}
+
+// StrategyReporter is implemented by strategies with state worth
+// showing in their status. Status is called after each hook, under the
+// strategy's lock; StrategyStatus.Detail is the latest result.
+type StrategyReporter interface {
+	Status() any
+}
+
+// StrategyStatus is one strategy as shown on /api/strategies.
+type StrategyStatus struct {
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	StartedAt int64  `json:"started_at,omitempty"`
+	StoppedAt int64  `json:"stopped_at,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	Orders    int    `json:"orders"`
+	Fills     int    `json:"fills"`
+	Detail    any    `json:"detail,omitempty"`
+}
+
+type strategySlot struct {
+	s Strategy
+	// hookMu serialises this strategy's hooks, so it never needs locks
+	// for its own state; other strategies aren't held up by it
+	hookMu sync.Mutex
+
+	sc      *StrategyContext
+	cancel  context.CancelFunc
+	status  StrategyStatus
+	orderID map[string]bool // orders it placed, for fill routing
+}
+
+// StrategyContext is a running strategy's handle on the engine. Its
+// context is cancelled when the strategy stops.
+type StrategyContext struct {
+	ctx  context.Context
+	e    *Engine
+	slot *strategySlot
+}
+
+func (sc *StrategyContext) Context() context.Context { return sc.ctx }
+
+// Now is the engine clock; use it rather than time.Now.
+func (sc *StrategyContext) Now() time.Time { return sc.e.now() }
+
+// Markets is the latest hub snapshot.
+func (sc *StrategyContext) Markets() MarketSnapshot {
+	snap, _ := sc.e.hub.Latest()
+	return snap
+}
+
+// Market is the latest row for symbol.
+func (sc *StrategyContext) Market(symbol string) (MarketRow, bool) {
+	for _, m := range sc.Markets().Markets {
+		if m.Symbol == symbol {
+			return m, true
+		}
+	}
+	return MarketRow{}, false
+}
+
+// PlaceOrder sends req through the engine's router and remembers the
+// order ids so their fills come back to this strategy. An
+// *OrderPendingError is returned as is, and the order's ids are
+// remembered if it turns up later.
+func (sc *StrategyContext) PlaceOrder(req OrderRequest) (*OrderResponse, error) {
+	resp, err := sc.e.router.PlaceOrder(sc.ctx, req)
+	var pending *OrderPendingError
+	if errors.As(err, &pending) {
+		go func() {
+			if resp := <-pending.Resolved; resp != nil {
+				sc.e.adopt(sc, resp)
+			}
+		}()
+	}
+	if err != nil {
+		return nil, err
+	}
+	sc.e.adopt(sc, resp)
+	return resp, nil
+}
+
+func (sc *StrategyContext) CancelOrder(orderID string) error {
+	return sc.e.router.CancelOrder(sc.ctx, orderID)
+}
+
+// MarketSpec is symbol's tick, step and minimums, for strategies that
+// lay out their own prices.
+func (sc *StrategyContext) MarketSpec(symbol string) (MarketSpec, error) {
+	return sc.e.router.MarketSpec(sc.ctx, symbol)
+}
+
+// Logf logs with the strategy's name in front.
+func (sc *StrategyContext) Logf(format string, args ...any) {
+	log.Printf("strategy %s: %s", sc.slot.s.Name(), fmt.Sprintf(format, args...))
+}
+
+// Engine runs registered strategies against hub snapshots, a timer and
+// the account's fills.
+type Engine struct {
+	hub        *MarketHub
+	router     OrderRouter
+	timerEvery time.Duration
+	now        func() time.Time
+	fills      chan Fill
+
+	mu    sync.Mutex
+	slots map[string]*strategySlot
+	// fills that arrived before any strategy had their order id, which
+	// happens when a paper order fills before PlaceOrder returns
+	orphans []orphanFill
+}
+
+type orphanFill struct {
+	f  Fill
+	at time.Time
+}
+
+// NewEngine builds an engine; a zero timerEvery takes the default.
+func NewEngine(hub *MarketHub, router OrderRouter, timerEvery time.Duration) *Engine {
+	if timerEvery <= 0 {
+		timerEvery = defaultStrategyTimer
+	}
+	return &Engine{
+		hub:        hub,
+		router:     router,
+		timerEvery: timerEvery,
+		now:        time.Now,
+		fills:      make(chan Fill, engineFillBuffer),
+		slots:      make(map[string]*strategySlot),
+	}
+}
+
+// NewEngineFromEnv reads STRATEGY_TIMER_INTERVAL.
+func NewEngineFromEnv(hub *MarketHub, router OrderRouter) *Engine {
+	return NewEngine(hub, router, envDuration("STRATEGY_TIMER_INTERVAL", defaultStrategyTimer))
+}
+
+// BuiltinStrategies returns a fresh instance of every strategy that
+// ships with the bot, for the API's engine and the backtest command.
+func BuiltinStrategies() []Strategy {
+	return []Strategy{
+		NewGridStrategy(),
+		NewCarryStrategy(),
+	}
+}
+
+// SetClock replaces time.Now, for replaying recorded data. Call it
+// before starting anything.
+func (e *Engine) SetClock(now func() time.Time) { e.now = now }
+
+// Register adds a strategy in the stopped state.
+func (e *Engine) Register(s Strategy) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.slots[s.Name()]; ok {
+		return fmt.Errorf("strategy %q already registered", s.Name())
+	}
+	e.slots[s.Name()] = &strategySlot{
+		s:      s,
+		status: StrategyStatus{Name: s.Name(), State: StrategyStopped},
+	}
+	return nil
+}
+
+// Start configures (if cfg is given) and starts a strategy.
+func (e *Engine) Start(name string, cfg json.RawMessage) error {
+	e.mu.Lock()
+	slot, ok := e.slots[name]
+	e.mu.Unlock()
+	if !ok {
+		return ErrUnknownStrategy
+	}
+	slot.hookMu.Lock()
+	defer slot.hookMu.Unlock()
+	if e.state(slot) == StrategyRunning {
+		return ErrStrategyRunning
+	}
+
+	if len(cfg) > 0 {
+		c, ok := slot.s.(StrategyConfigurer)
+		if !ok {
+			return fmt.Errorf("strategy %q takes no config", name)
+		}
+		if err := c.Configure(cfg); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sc := &StrategyContext{ctx: ctx, e: e, slot: slot}
+
+	e.mu.Lock()
+	slot.sc, slot.cancel = sc, cancel
+	slot.orderID = make(map[string]bool)
+	slot.status = StrategyStatus{Name: name, State: StrategyRunning, StartedAt: e.now().Unix()}
+	e.mu.Unlock()
+
+	var err error
+	e.call(slot, "OnStart", func() { err = slot.s.OnStart(sc) })
+	if err != nil {
+		e.finish(slot, StrategyFailed, err.Error())
+		return err
+	}
+	e.mu.Lock()
+	st := slot.status
+	e.mu.Unlock()
+	if st.State != StrategyRunning {
+		return fmt.Errorf("strategy %q failed to start: %s", name, st.LastError)
+	}
+	log.Printf("strategy %s started", name)
+	return nil
+}
+
+// Stop stops a running strategy, giving it a chance to clean up.
+func (e *Engine) Stop(name string) error {
+	e.mu.Lock()
+	slot, ok := e.slots[name]
+	e.mu.Unlock()
+	if !ok {
+		return ErrUnknownStrategy
+	}
+	slot.hookMu.Lock()
+	defer slot.hookMu.Unlock()
+	if e.state(slot) != StrategyRunning {
+		return ErrStrategyStopped
+	}
+
+	if st, ok := slot.s.(StrategyStopper); ok {
+		e.call(slot, "OnStop", func() { st.OnStop(slot.sc) })
+	}
+	if e.state(slot) == StrategyRunning {
+		e.finish(slot, StrategyStopped, "")
+	}
+	log.Printf("strategy %s stopped", name)
+	return nil
+}
+
+// StopAll stops every running strategy and returns their names.
+func (e *Engine) StopAll() []string {
+	stopped := []string{}
+	for _, st := range e.Statuses() {
+		if st.State != StrategyRunning {
+			continue
+		}
+		if err := e.Stop(st.Name); err != nil {
+			log.Printf("strategy %s stop error: %v", st.Name, err)
+			continue
+		}
+		stopped = append(stopped, st.Name)
+	}
+	return stopped
+}
+
+// Status reports one strategy.
+func (e *Engine) Status(name string) (StrategyStatus, error) {
+	e.mu.Lock()
+	slot, ok := e.slots[name]
+	e.mu.Unlock()
+	if !ok {
+		return StrategyStatus{}, ErrUnknownStrategy
+	}
+	return e.statusOf(slot), nil
+}
+
+// Statuses reports every registered strategy, by name.
+func (e *Engine) Statuses() []StrategyStatus {
+	e.mu.Lock()
+	slots := make([]*strategySlot, 0, len(e.slots))
+	for _, s := range e.slots {
+		slots = append(slots, s)
+	}
+	e.mu.Unlock()
+
+	out := make([]StrategyStatus, 0, len(slots))
+	for _, s := range slots {
+		out = append(out, e.statusOf(s))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// statusOf copies the slot's status, including the detail snapshot
+// taken after the last hook, so it never waits on a running hook.
+func (e *Engine) statusOf(slot *strategySlot) StrategyStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slot.status
+}
+
+// NotifyFill hands the engine one of our fills. It never blocks; if the
+// engine is that far behind the fill is dropped and logged.
+func (e *Engine) NotifyFill(f Fill) {
+	select {
+	case e.fills <- f:
+	default:
+		log.Printf("engine: fill queue full, dropped fill for %s", f.OrderID)
+	}
+}
+
+// Run feeds hub snapshots, fills and timer ticks to running strategies
+// until ctx is done, then stops them all.
+func (e *Engine) Run(ctx context.Context) {
+	sub := e.hub.Subscribe()
+	defer func() { e.hub.Unsubscribe(sub) }()
+
+	ticker := time.NewTicker(e.timerEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			for _, st := range e.Statuses() {
+				if st.State == StrategyRunning {
+					_ = e.Stop(st.Name)
+				}
+			}
+			return
+		case snap, ok := <-sub.C:
+			if !ok {
+				// cut off for falling behind; the next snapshot is all we need
+				log.Printf("engine: hub subscription dropped, resubscribing")
+				sub = e.hub.Subscribe()
+				continue
+			}
+			e.DispatchMarketUpdate(snap)
+		case f := <-e.fills:
+			e.DispatchFill(f)
+		case <-ticker.C:
+			e.DispatchTimer(e.now())
+		}
+	}
+}
+
+// DispatchMarketUpdate, DispatchTimer and DispatchFill are Run's three
+// events, exported so a backtest can drive the engine on its own clock.
+// Don't mix them with Run.
+func (e *Engine) DispatchMarketUpdate(snap MarketSnapshot) {
+	e.dispatch("OnMarketUpdate", func(s Strategy, sc *StrategyContext) { s.OnMarketUpdate(sc, snap) })
+}
+
+func (e *Engine) DispatchTimer(now time.Time) {
+	e.dispatch("OnTimer", func(s Strategy, sc *StrategyContext) { s.OnTimer(sc, now) })
+}
+
+func (e *Engine) running() []*strategySlot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := []*strategySlot{}
+	for _, s := range e.slots {
+		if s.status.State == StrategyRunning {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].status.Name < out[j].status.Name })
+	return out
+}
+
+func (e *Engine) dispatch(hook string, fn func(Strategy, *StrategyContext)) {
+	for _, slot := range e.running() {
+		slot.hookMu.Lock()
+		// it may have stopped since running() looked
+		if e.state(slot) == StrategyRunning {
+			e.call(slot, hook, func() { fn(slot.s, slot.sc) })
+		}
+		slot.hookMu.Unlock()
+	}
+}
+
+// DispatchFill routes a fill to the strategy that placed the order. A
+// fill for an order id no strategy has yet is held for engineOrphanTTL
+// and queued again if one registers it.
+func (e *Engine) DispatchFill(f Fill) {
+	for _, slot := range e.running() {
+		e.mu.Lock()
+		mine := slot.orderID[f.OrderID]
+		if mine {
+			slot.status.Fills++
+		}
+		e.mu.Unlock()
+		if !mine {
+			continue
+		}
+		slot.hookMu.Lock()
+		if e.state(slot) == StrategyRunning {
+			e.call(slot, "OnFill", func() { slot.s.OnFill(slot.sc, f) })
+		}
+		slot.hookMu.Unlock()
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pruneOrphansLocked()
+	if len(e.orphans) == engineFillBuffer {
+		e.orphans = e.orphans[1:]
+	}
+	e.orphans = append(e.orphans, orphanFill{f: f, at: e.now()})
+}
+
+// adopt registers resp's order ids to sc's strategy, if it is still the
+// same run, and queues any of their fills that came in first.
+func (e *Engine) adopt(sc *StrategyContext, resp *OrderResponse) {
+	e.mu.Lock()
+	if sc.slot.sc != sc {
+		e.mu.Unlock()
+		return
+	}
+	sc.slot.status.Orders++
+	ids := append([]string{resp.OrderID}, resp.ChildOrderIDs...)
+	for _, id := range ids {
+		sc.slot.orderID[id] = true
+	}
+	e.pruneOrphansLocked()
+	var early []Fill
+	kept := e.orphans[:0]
+	for _, o := range e.orphans {
+		if sc.slot.orderID[o.f.OrderID] {
+			early = append(early, o.f)
+		} else {
+			kept = append(kept, o)
+		}
+	}
+	e.orphans = kept
+	e.mu.Unlock()
+
+	// through the queue, so they're dispatched once the current hook
+	// has returned
+	for _, f := range early {
+		e.NotifyFill(f)
+	}
+}
+
+func (e *Engine) pruneOrphansLocked() {
+	cutoff := e.now().Add(-engineOrphanTTL)
+	i := 0
+	for i < len(e.orphans) && e.orphans[i].at.Before(cutoff) {
+		i++
+	}
+	e.orphans = e.orphans[i:]
+}
+
+// call runs one hook with slot.hookMu held, then snapshots the
+// strategy's detail for Status. A panic fails the strategy instead of
+// the process.
+func (e *Engine) call(slot *strategySlot, hook string, fn func()) {
+	defer e.snapshot(slot)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("strategy %s panicked in %s: %v", slot.s.Name(), hook, r)
+			e.finish(slot, StrategyFailed, fmt.Sprintf("panic in %s: %v", hook, r))
+		}
+	}()
+	fn()
+}
+
+// snapshot stores the strategy's Status, with slot.hookMu held.
+func (e *Engine) snapshot(slot *strategySlot) {
+	r, ok := slot.s.(StrategyReporter)
+	if !ok {
+		return
+	}
+	var detail any
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("strategy %s panicked in Status: %v", slot.s.Name(), p)
+			}
+		}()
+		detail = r.Status()
+	}()
+	e.mu.Lock()
+	slot.status.Detail = detail
+	e.mu.Unlock()
+}
+
+func (e *Engine) finish(slot *strategySlot, state, lastErr string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if slot.cancel != nil {
+		slot.cancel()
+	}
+	slot.status.State = state
+	slot.status.StoppedAt = e.now().Unix()
+	if lastErr != "" {
+		slot.status.LastError = lastErr
+	}
+}
+
+func (e *Engine) state(slot *strategySlot) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slot.status.State
+}
@@ -0,0 +1,187 @@
+// backend/internal/lighter/engine_test.go
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingStrategy parks in OnTimer until release is closed.
+type blockingStrategy struct {
+	name    string
+	ticks   int
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStrategy) Name() string                                    { return s.name }
+func (s *blockingStrategy) OnStart(*StrategyContext) error                  { return nil }
+func (s *blockingStrategy) OnMarketUpdate(*StrategyContext, MarketSnapshot) {}
+func (s *blockingStrategy) OnFill(*StrategyContext, Fill)                   {}
+func (s *blockingStrategy) Status() any                                     { return s.ticks }
+
+func (s *blockingStrategy) OnTimer(*StrategyContext, time.Time) {
+	s.ticks++
+	if s.release != nil {
+		close(s.entered)
+		<-s.release
+	}
+}
+
+type nopRouter struct{}
+
+func (nopRouter) PlaceOrder(context.Context, OrderRequest) (*OrderResponse, error) {
+	return &OrderResponse{}, nil
+}
+func (nopRouter) CancelOrder(context.Context, string) error { return nil }
+func (nopRouter) MarketSpec(context.Context, string) (MarketSpec, error) {
+	return MarketSpec{}, ErrUnknownMarket
+}
+
+func TestEngineHookDoesNotBlockOthers(t *testing.T) {
+	slow := &blockingStrategy{name: "slow", entered: make(chan struct{}), release: make(chan struct{})}
+	other := &blockingStrategy{name: "other"}
+	e := NewEngine(NewMarketHub(nil, 0, 0), nopRouter{}, time.Hour)
+	for _, s := range []Strategy{slow, other} {
+		if err := e.Register(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Start("slow", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		e.DispatchTimer(time.Now())
+		close(done)
+	}()
+	<-slow.entered
+
+	// slow is mid-hook: its status is the snapshot from before, and the
+	// other strategy starts and stops without waiting
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		if st, err := e.Status("slow"); err != nil || st.Detail != 0 {
+			t.Errorf("slow status %+v, %v; want detail 0", st, err)
+		}
+		if err := e.Start("other", nil); err != nil {
+			t.Errorf("start other: %v", err)
+		}
+		if err := e.Stop("other"); err != nil {
+			t.Errorf("stop other: %v", err)
+		}
+	}()
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatal("blocked behind another strategy's hook")
+	}
+
+	close(slow.release)
+	<-done
+	if st, _ := e.Status("slow"); st.Detail != 1 {
+		t.Errorf("detail after the hook %v, want 1", st.Detail)
+	}
+}
+
+// earlyFillRouter dispatches each order's fill before PlaceOrder
+// returns, as a paper fill racing the engine's Run loop does.
+type earlyFillRouter struct {
+	nopRouter
+	e *Engine
+}
+
+func (r earlyFillRouter) PlaceOrder(context.Context, OrderRequest) (*OrderResponse, error) {
+	r.e.DispatchFill(Fill{OrderID: "p1", Size: MustDecimal("1")})
+	return &OrderResponse{OrderID: "p1"}, nil
+}
+
+// fillCounter places one order on start and counts its fills.
+type fillCounter struct {
+	blockingStrategy
+	fills int
+}
+
+func (s *fillCounter) OnStart(sc *StrategyContext) error {
+	_, err := sc.PlaceOrder(OrderRequest{Symbol: "ETH"})
+	return err
+}
+func (s *fillCounter) OnFill(*StrategyContext, Fill) { s.fills++ }
+
+func TestEngineFillBeforeOrderID(t *testing.T) {
+	e := NewEngine(NewMarketHub(nil, 0, 0), nil, time.Hour)
+	e.router = earlyFillRouter{e: e}
+	s := &fillCounter{blockingStrategy: blockingStrategy{name: "early"}}
+	if err := e.Register(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Start("early", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the fill went round again once the id was known
+	select {
+	case f := <-e.fills:
+		e.DispatchFill(f)
+	default:
+		t.Fatal("early fill was dropped")
+	}
+	if st, _ := e.Status("early"); s.fills != 1 || st.Fills != 1 {
+		t.Errorf("%d fills seen, %d counted; want 1", s.fills, st.Fills)
+	}
+	if len(e.orphans) != 0 {
+		t.Errorf("%d orphans left", len(e.orphans))
+	}
+}
+
+// doubtRouter fails every send as in doubt and resolves it on demand.
+type doubtRouter struct {
+	nopRouter
+	resolved chan *OrderResponse
+}
+
+func (r doubtRouter) PlaceOrder(context.Context, OrderRequest) (*OrderResponse, error) {
+	return nil, &OrderPendingError{Err: context.DeadlineExceeded, Resolved: r.resolved}
+}
+
+// pendingOnce places one order on start and carries on if it's in
+// doubt.
+type pendingOnce struct{ fillCounter }
+
+func (s *pendingOnce) OnStart(sc *StrategyContext) error {
+	_, err := sc.PlaceOrder(OrderRequest{Symbol: "ETH"})
+	var pending *OrderPendingError
+	if errors.As(err, &pending) {
+		return nil
+	}
+	return err
+}
+
+func TestEngineAdoptsPendingOrder(t *testing.T) {
+	router := doubtRouter{resolved: make(chan *OrderResponse, 1)}
+	e := NewEngine(NewMarketHub(nil, 0, 0), router, time.Hour)
+	s := &pendingOnce{fillCounter{blockingStrategy: blockingStrategy{name: "doubt"}}}
+	if err := e.Register(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Start("doubt", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// its fill comes in before the order is confirmed
+	e.DispatchFill(Fill{OrderID: "late", Size: MustDecimal("1")})
+	router.resolved <- &OrderResponse{OrderID: "late"}
+	select {
+	case f := <-e.fills:
+		e.DispatchFill(f)
+	case <-time.After(2 * time.Second):
+		t.Fatal("confirmed order's fill never came back")
+	}
+	if s.fills != 1 {
+		t.Errorf("%d fills, want 1", s.fills)
+	}
+}
@@ -0,0 +1,108 @@
+// backend/internal/lighter/errors.go
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError is any failed Lighter call: a non-2xx status, a 2xx whose
+// body carries an error code, or a transport failure (HTTPStatus 0, Err
+// set). Retryable means the same request may succeed later.
+type APIError struct {
+	Op         string // "GET /api/v1/account"
+	HTTPStatus int
+	Code       int // Lighter's response code, 0 if none
+	Message    string
+	Retryable  bool
+	RetryAfter time.Duration // set while the circuit breaker is open
+	Err        error         // underlying transport error, if any
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	case e.Code != 0:
+		return fmt.Sprintf("%s => http %d, code %d: %s", e.Op, e.HTTPStatus, e.Code, e.Message)
+	default:
+		return fmt.Sprintf("%s => http %d: %s", e.Op, e.HTTPStatus, e.Message)
+	}
+}
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// AsAPIError unwraps err to an *APIError.
+func AsAPIError(err error) (*APIError, bool) {
+	var ae *APIError
+	ok := errors.As(err, &ae)
+	return ae, ok
+}
+
+// Lighter puts code 200 in the body on success; other values are errors
+// even when the HTTP status is 200.
+const codeOK = 200
+
+type responseCode struct {
+	Code    *int   `json:"code"`
+	Message string `json:"message"`
+}
+
+// checkResponse turns a status + body into an *APIError, or nil when the
+// call succeeded. Bodies without a code field are judged on status alone.
+func checkResponse(op string, status int, body []byte) error {
+	var rc responseCode
+	_ = json.Unmarshal(body, &rc) // not every error body is JSON
+
+	if status < 200 || status >= 300 {
+		msg := rc.Message
+		if msg == "" {
+			msg = strings.TrimSpace(string(body))
+		}
+		if msg == "" {
+			msg = http.StatusText(status)
+		}
+		code := 0
+		if rc.Code != nil {
+			code = *rc.Code
+		}
+		return &APIError{
+			Op:         op,
+			HTTPStatus: status,
+			Code:       code,
+			Message:    msg,
+			Retryable:  status == http.StatusTooManyRequests || status >= 500,
+		}
+	}
+	if rc.Code != nil && *rc.Code != codeOK && *rc.Code != 0 {
+		return &APIError{Op: op, HTTPStatus: status, Code: *rc.Code, Message: rc.Message}
+	}
+	return nil
+}
+
+// transportError wraps a failure that never produced a response.
+func (c *LighterClient) transportError(op string, err error) error {
+	ae := &APIError{Op: op, Message: err.Error(), Retryable: true, Err: err}
+	if errors.Is(err, ErrCircuitOpen) && c.breaker != nil {
+		ae.RetryAfter = time.Duration(c.breaker.Status().RetryInMs) * time.Millisecond
+	}
+	return ae
+}
+
+// IsAmbiguous is true when a failed write may still have reached the
+// exchange: the connection broke or timed out, or the server failed
+// with a 5xx. A call refused before sending (open circuit) isn't.
+func IsAmbiguous(err error) bool {
+	ae, ok := AsAPIError(err)
+	if !ok {
+		return false
+	}
+	if ae.Err != nil {
+		return !errors.Is(ae.Err, ErrCircuitOpen)
+	}
+	return ae.HTTPStatus >= 500
+}
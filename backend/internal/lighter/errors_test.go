@@ -0,0 +1,31 @@
+// backend/internal/lighter/errors_test.go
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsAmbiguous(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection reset", &APIError{Err: errors.New("connection reset by peer")}, true},
+		{"timeout", &APIError{Err: context.DeadlineExceeded}, true},
+		{"wrapped 502", fmt.Errorf("send: %w", &APIError{HTTPStatus: 502}), true},
+		{"circuit open", &APIError{Err: fmt.Errorf("wait: %w", ErrCircuitOpen)}, false},
+		{"rejected", &APIError{HTTPStatus: 400, Code: 21701, Message: "invalid order"}, false},
+		{"rejected in a 200", &APIError{HTTPStatus: 200, Code: codeInvalidNonce}, false},
+		{"rate limited", &APIError{HTTPStatus: 429}, false},
+		{"not an api error", errors.New("sign: bad key"), false},
+	}
+	for _, c := range cases {
+		if got := IsAmbiguous(c.err); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+// backend/internal/lighter/grid.go
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	GridArithmetic = "arithmetic"
+	GridGeometric  = "geometric"
+
+	maxGridLevels = 200
+)
+
+// GridConfig is the grid strategy's start config.
+//
+//	{"symbol":"ETH","lower":3000,"upper":3600,"levels":13,
+//	 "spacing":"geometric","size_contracts":0.05,
+//	 "stop_low":2800,"stop_high":3800,"close_on_stop":true}
+type GridConfig struct {
+	Symbol        string   `json:"symbol"`
+	Lower         Decimal  `json:"lower"`
+	Upper         Decimal  `json:"upper"`
+	Levels        int      `json:"levels"`  // prices from lower to upper, both included
+	Spacing       string   `json:"spacing"` // arithmetic (default) | geometric
+	SizeContracts Decimal  `json:"size_contracts"`
+	Leverage      float64  `json:"leverage"`
+	StopLow       *Decimal `json:"stop_low,omitempty"` // at or below: stop out
+	StopHigh      *Decimal `json:"stop_high,omitempty"`
+	CloseOnStop   bool     `json:"close_on_stop"`
+	Mode          string   `json:"mode,omitempty"` // live | paper; empty follows TRADING_MODE
+}
+
+func (c GridConfig) validate() error {
+	switch {
+	case c.Symbol == "":
+		return errors.New("symbol is required")
+	case !c.Lower.IsPositive() || !c.Lower.LessThan(c.Upper):
+		return errors.New("need 0 < lower < upper")
+	case c.Levels < 2 || c.Levels > maxGridLevels:
+		return fmt.Errorf("levels must be 2..%d", maxGridLevels)
+	case c.Spacing != GridArithmetic && c.Spacing != GridGeometric:
+		return errors.New("spacing must be 'arithmetic' or 'geometric'")
+	case !c.SizeContracts.IsPositive():
+		return errors.New("size_contracts must be > 0")
+	case c.StopLow != nil && !c.StopLow.LessThan(c.Lower):
+		return errors.New("stop_low must be below lower")
+	case c.StopHigh != nil && !c.Upper.LessThan(*c.StopHigh):
+		return errors.New("stop_high must be above upper")
+	case c.Mode != "" && c.Mode != ModeLive && c.Mode != ModePaper:
+		return errors.New("mode must be 'live' or 'paper'")
+	}
+	return nil
+}
+
+// gridPrices lays out the levels, rounded to the tick. Lower and upper
+// must already be on it, and no two levels may round to the same tick.
+func gridPrices(c GridConfig, mkt MarketSpec) ([]Decimal, error) {
+	for _, px := range []Decimal{c.Lower, c.Upper} {
+		if !mkt.OnTick(px) {
+			return nil, fmt.Errorf("%v is not a multiple of the %s tick %v", px, mkt.Symbol, mkt.TickSize())
+		}
+	}
+
+	n := c.Levels - 1
+	prices := make([]Decimal, c.Levels)
+	step, err := c.Upper.Sub(c.Lower).Div(NewDecimalFromInt(int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	ratio := c.Upper.Float64() / c.Lower.Float64()
+	for i := range prices {
+		switch {
+		case i == 0:
+			prices[i] = c.Lower
+		case i == n:
+			prices[i] = c.Upper
+		case c.Spacing == GridGeometric:
+			px := c.Lower.Mul(NewDecimalFromFloat(math.Pow(ratio, float64(i)/float64(n))))
+			prices[i] = px.Round(mkt.PriceDecimals)
+		default:
+			prices[i] = c.Lower.Add(step.Mul(NewDecimalFromInt(int64(i)))).Round(mkt.PriceDecimals)
+		}
+		if i > 0 && !prices[i-1].LessThan(prices[i]) {
+			return nil, fmt.Errorf("levels are closer than the %s tick %v; use fewer levels or a wider range", mkt.Symbol, mkt.TickSize())
+		}
+	}
+	return prices, nil
+}
+
+// gridInBand refuses a range whose outer levels the PriceBand risk check
+// would reject against ref, rather than laying a grid with dead rungs.
+// A band of 0 is off.
+func gridInBand(c GridConfig, ref Decimal, bandPct float64) error {
+	if bandPct <= 0 {
+		return nil
+	}
+	band := PriceBand{MaxDeviationPct: bandPct}
+	for _, px := range []Decimal{c.Lower, c.Upper} {
+		if err := band.Check(&RiskContext{Order: OrderRequest{Symbol: c.Symbol, Price: &px}, MarkPrice: ref}); err != nil {
+			return fmt.Errorf("grid range is outside the risk price band: %w", err)
+		}
+	}
+	return nil
+}
+
+// gridLevel is one rung. At most one order works on it at a time; a
+// closing order carries the price of the fill it closes.
+type gridLevel struct {
+	Price   Decimal  `json:"price"`
+	Side    string   `json:"side,omitempty"` // "" while the rung is empty
+	OrderID string   `json:"order_id,omitempty"`
+	Filled  Decimal  `json:"filled"`
+	Closes  *Decimal `json:"closes,omitempty"`
+}
+
+// gridStatus is what GET /api/strategies/grid shows.
+type gridStatus struct {
+	Config         GridConfig  `json:"config"`
+	Levels         []gridLevel `json:"levels"`
+	Position       Decimal     `json:"position"`
+	RoundTrips     int         `json:"round_trips"`
+	RealizedProfit Decimal     `json:"realized_profit"` // closed pairs, before fees
+	FeesPaid       Decimal     `json:"fees_paid"`
+	NetProfit      Decimal     `json:"net_profit"`
+	StoppedOut     string      `json:"stopped_out,omitempty"`
+}
+
+// GridStrategy lays limit orders on every level between lower and
+// upper: buys below the price, sells above, and the rung nearest the
+// price left empty. When a rung fills, the opposite order goes on the
+// next rung over (a buy at i becomes a sell at i+1, a sell at i a buy
+// at i-1), so each round trip earns one level's spacing. On perps that
+// makes it a neutral grid; sells above the price open shorts.
+//
+// Crossing stop_low/stop_high cancels every grid order and, with
+// close_on_stop, flattens the grid's position with a reduce-only
+// market order. The grid then idles until it is stopped.
+type GridStrategy struct {
+	cfg    GridConfig
+	mkt    MarketSpec
+	levels []gridLevel
+	byID   map[string]int
+
+	position  Decimal
+	trips     int
+	realized  Decimal
+	fees      Decimal
+	stoppedAt string
+}
+
+func NewGridStrategy() *GridStrategy { return &GridStrategy{} }
+
+func (g *GridStrategy) Name() string { return "grid" }
+
+func (g *GridStrategy) Configure(raw json.RawMessage) error {
+	var cfg GridConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return err
+	}
+	cfg.Spacing = strings.ToLower(cfg.Spacing)
+	if cfg.Spacing == "" {
+		cfg.Spacing = GridArithmetic
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	g.cfg = cfg
+	return nil
+}
+
+// OnStart checks the config against the market's grid and the risk
+// price band, and lays the initial orders.
+func (g *GridStrategy) OnStart(sc *StrategyContext) error {
+	if g.cfg.Symbol == "" {
+		return errors.New("grid needs a config: symbol, lower, upper, levels, size_contracts")
+	}
+	mkt, err := sc.MarketSpec(g.cfg.Symbol)
+	if err != nil {
+		return fmt.Errorf("market %s: %w", g.cfg.Symbol, err)
+	}
+	prices, err := gridPrices(g.cfg, mkt)
+	if err != nil {
+		return err
+	}
+	base, err := mkt.BaseAmount(g.cfg.SizeContracts, GridReject)
+	if err != nil {
+		return err
+	}
+	for _, px := range prices {
+		ticks, err := mkt.PriceTicks(px, GridReject, false)
+		if err != nil {
+			return err
+		}
+		if err := mkt.CheckMinimums(ticks, base); err != nil {
+			return err
+		}
+	}
+
+	row, ok := sc.Market(g.cfg.Symbol)
+	ref := row.RefPrice()
+	if !ok || !ref.IsPositive() {
+		return fmt.Errorf("no price for %s yet", g.cfg.Symbol)
+	}
+	if err := gridInBand(g.cfg, ref, RiskConfigFromEnv().PriceBandPct); err != nil {
+		return err
+	}
+
+	*g = GridStrategy{cfg: g.cfg, mkt: mkt, byID: map[string]int{}}
+	g.levels = make([]gridLevel, len(prices))
+	skip := -1
+	if !ref.LessThan(g.cfg.Lower) && !g.cfg.Upper.LessThan(ref) {
+		skip = nearestLevel(prices, ref)
+	}
+	for i, px := range prices {
+		g.levels[i].Price = px
+		if i == skip {
+			continue
+		}
+		g.levels[i].Side = "sell"
+		if px.LessThan(ref) {
+			g.levels[i].Side = "buy"
+		}
+	}
+	g.placeMissing(sc)
+	sc.Logf("grid on %s: %d levels %v..%v, price %v", g.cfg.Symbol, len(prices), g.cfg.Lower, g.cfg.Upper, ref)
+	return nil
+}
+
+func nearestLevel(prices []Decimal, px Decimal) int {
+	best := 0
+	for i := range prices {
+		if prices[i].Sub(px).Abs().LessThan(prices[best].Sub(px).Abs()) {
+			best = i
+		}
+	}
+	return best
+}
+
+// OnMarketUpdate watches the stop bounds.
+func (g *GridStrategy) OnMarketUpdate(sc *StrategyContext, snap MarketSnapshot) {
+	if g.stoppedAt != "" {
+		return
+	}
+	for _, m := range snap.Markets {
+		if m.Symbol != g.cfg.Symbol {
+			continue
+		}
+		px := m.RefPrice()
+		if !px.IsPositive() {
+			return
+		}
+		switch {
+		case g.cfg.StopLow != nil && !g.cfg.StopLow.LessThan(px):
+			g.stopOut(sc, fmt.Sprintf("price %v at or below stop_low %v", px, *g.cfg.StopLow))
+		case g.cfg.StopHigh != nil && !px.LessThan(*g.cfg.StopHigh):
+			g.stopOut(sc, fmt.Sprintf("price %v at or above stop_high %v", px, *g.cfg.StopHigh))
+		}
+		return
+	}
+}
+
+// OnFill books a fill against its rung. Once the rung's order is done
+// the opposite order goes on the neighbouring rung, and a closing fill
+// realizes the spacing between the two.
+func (g *GridStrategy) OnFill(sc *StrategyContext, f Fill) {
+	fee := f.FeeUsd
+	size := f.Size
+	g.fees = g.fees.Add(fee)
+	if f.Side == "buy" {
+		g.position = g.position.Add(size)
+	} else {
+		g.position = g.position.Sub(size)
+	}
+
+	i, ok := g.byID[f.OrderID]
+	if !ok {
+		return // the stop-out close, or an order we've let go of
+	}
+	lvl := &g.levels[i]
+	lvl.Filled = lvl.Filled.Add(size)
+
+	if lvl.Closes != nil {
+		px := f.Price
+		gain := px.Sub(*lvl.Closes).Mul(size)
+		if lvl.Side == "buy" {
+			gain = gain.Neg()
+		}
+		g.realized = g.realized.Add(gain)
+	}
+	if lvl.Filled.LessThan(g.cfg.SizeContracts) {
+		return
+	}
+
+	side, opened := lvl.Side, lvl.Price
+	if lvl.Closes != nil {
+		g.trips++
+		opened = Decimal{} // a closing fill opens nothing
+	}
+	delete(g.byID, f.OrderID)
+	*lvl = gridLevel{Price: lvl.Price}
+
+	next := i + 1
+	nextSide := "sell"
+	if side == "sell" {
+		next, nextSide = i-1, "buy"
+	}
+	if next < 0 || next >= len(g.levels) || g.stoppedAt != "" {
+		return
+	}
+	nl := &g.levels[next]
+	if nl.OrderID != "" {
+		return // already working; leave it
+	}
+	nl.Side = nextSide
+	if !opened.IsZero() {
+		closes := opened
+		nl.Closes = &closes
+	}
+	g.placeMissing(sc)
+}
+
+// OnTimer retries rungs whose order failed to place.
+func (g *GridStrategy) OnTimer(sc *StrategyContext, _ time.Time) {
+	if g.stoppedAt == "" {
+		g.placeMissing(sc)
+	}
+}
+
+// OnStop cancels every grid order; the position is left alone.
+func (g *GridStrategy) OnStop(sc *StrategyContext) {
+	g.cancelAll(sc)
+}
+
+func (g *GridStrategy) Status() any {
+	return gridStatus{
+		Config:         g.cfg,
+		Levels:         append([]gridLevel(nil), g.levels...),
+		Position:       g.position,
+		RoundTrips:     g.trips,
+		RealizedProfit: g.realized,
+		FeesPaid:       g.fees,
+		NetProfit:      g.realized.Sub(g.fees),
+		StoppedOut:     g.stoppedAt,
+	}
+}
+
+// placeMissing places an order on every rung that wants one and has
+// none working. Failures are logged and retried on the timer.
+func (g *GridStrategy) placeMissing(sc *StrategyContext) {
+	for i := range g.levels {
+		lvl := &g.levels[i]
+		if lvl.Side == "" || lvl.OrderID != "" {
+			continue
+		}
+		px, size := lvl.Price, g.cfg.SizeContracts
+		resp, err := sc.PlaceOrder(OrderRequest{
+			Symbol:        g.cfg.Symbol,
+			Side:          lvl.Side,
+			Type:          "limit",
+			Price:         &px,
+			SizeContracts: &size,
+			Leverage:      g.cfg.Leverage,
+			Mode:          g.cfg.Mode,
+		})
+		if err != nil {
+			sc.Logf("place %s %v: %v", lvl.Side, px, err)
+			continue
+		}
+		lvl.OrderID = resp.OrderID
+		lvl.Filled = Decimal{}
+		g.byID[resp.OrderID] = i
+	}
+}
+
+func (g *GridStrategy) cancelAll(sc *StrategyContext) {
+	for i := range g.levels {
+		lvl := &g.levels[i]
+		if lvl.OrderID == "" {
+			continue
+		}
+		if err := sc.CancelOrder(lvl.OrderID); err != nil {
+			sc.Logf("cancel %s: %v", lvl.OrderID, err)
+		}
+		delete(g.byID, lvl.OrderID)
+		lvl.OrderID, lvl.Side, lvl.Closes = "", "", nil
+	}
+}
+
+// stopOut cancels the grid and, if configured, closes what it holds.
+func (g *GridStrategy) stopOut(sc *StrategyContext, reason string) {
+	g.stoppedAt = reason
+	sc.Logf("stopped out: %s", reason)
+	g.cancelAll(sc)
+
+	if !g.cfg.CloseOnStop || g.position.IsZero() {
+		return
+	}
+	side := "sell"
+	if g.position.Sign() < 0 {
+		side = "buy"
+	}
+	size := g.position.Abs().Floor(g.mkt.SizeDecimals)
+	if !size.IsPositive() {
+		return
+	}
+	if _, err := sc.PlaceOrder(OrderRequest{
+		Symbol:        g.cfg.Symbol,
+		Side:          side,
+		Type:          "market",
+		SizeContracts: &size,
+		ReduceOnly:    true,
+		Mode:          g.cfg.Mode,
+	}); err != nil {
+		sc.Logf("close %s %v: %v", side, size, err)
+	}
+}
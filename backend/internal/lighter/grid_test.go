@@ -0,0 +1,72 @@
+// backend/internal/lighter/grid_test.go
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGridPrices(t *testing.T) {
+	mkt := MarketSpec{Symbol: "ETH", PriceDecimals: 2}
+	cases := []struct {
+		name    string
+		lower   string
+		upper   string
+		levels  int
+		spacing string
+		want    string // levels joined by spaces, or "" for an error
+	}{
+		{"arithmetic", "100", "110", 3, GridArithmetic, "100 105 110"},
+		{"arithmetic rounds to the tick", "100", "100.1", 4, GridArithmetic, "100 100.03 100.07 100.1"},
+		{"two levels are the ends", "100", "200", 2, GridArithmetic, "100 200"},
+		{"geometric", "100", "400", 3, GridGeometric, "100 200 400"},
+		{"geometric rounds to the tick", "100", "200", 4, GridGeometric, "100 125.99 158.74 200"},
+		{"lower off the tick", "100.001", "110", 3, GridArithmetic, ""},
+		{"upper off the tick", "100", "110.005", 3, GridArithmetic, ""},
+		{"levels collide on the tick", "100", "100.02", 4, GridArithmetic, ""},
+	}
+	for _, c := range cases {
+		cfg := GridConfig{Symbol: "ETH", Lower: MustDecimal(c.lower), Upper: MustDecimal(c.upper), Levels: c.levels, Spacing: c.spacing}
+		prices, err := gridPrices(cfg, mkt)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", c.name, prices)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		got := make([]string, len(prices))
+		for i, px := range prices {
+			got[i] = px.String()
+		}
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, strings.Join(got, " "), c.want)
+		}
+	}
+}
+
+func TestGridInBand(t *testing.T) {
+	cases := []struct {
+		name  string
+		lower string
+		upper string
+		band  float64
+		ok    bool
+	}{
+		{"inside", "96", "104", 5, true},
+		{"on the edges", "95", "105", 5, true},
+		{"lower too far", "94", "104", 5, false},
+		{"upper too far", "96", "106", 5, false},
+		{"band off", "50", "200", 0, true},
+	}
+	for _, c := range cases {
+		cfg := GridConfig{Symbol: "ETH", Lower: MustDecimal(c.lower), Upper: MustDecimal(c.upper)}
+		err := gridInBand(cfg, MustDecimal("100"), c.band)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: got %v, want ok %v", c.name, err, c.ok)
+		}
+	}
+}
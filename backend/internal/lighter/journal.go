@@ -0,0 +1,392 @@
+// backend/internal/lighter/journal.go
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ----- journal records -----
+
+type OrderRow struct {
+	OrderID          string  `json:"order_id"` // order index, or tx hash if it wasn't known yet
+	OrderIndex       int64   `json:"order_index,omitempty"`
+	TxHash           string  `json:"tx_hash,omitempty"`
+	ClientOrderIndex int64   `json:"client_order_index,omitempty"`
+	MarketID         int     `json:"market_id"`
+	Symbol           string  `json:"symbol"`
+	Side             string  `json:"side"`   // buy / sell
+	Type             string  `json:"type"`   // market / limit
+	Status           string  `json:"status"` // pending / open / submitted / filled / cancelled
+	Price            Decimal `json:"price"`
+	SizeUsd          Decimal `json:"size_usd"`
+	SizeContracts    Decimal `json:"size_contracts"`
+	Leverage         float64 `json:"leverage"`
+	ReduceOnly       bool    `json:"reduce_only"`
+	ClientID         string  `json:"client_id,omitempty"`
+	CreatedAtEpoch   int64   `json:"created_at_epoch"`
+	UpdatedAtEpoch   int64   `json:"updated_at_epoch,omitempty"`
+
+	// bracket legs: Type is "stop_loss"/"take_profit" and they point
+	// back at the entry order they protect
+	ParentOrderID string  `json:"parent_order_id,omitempty"`
+	TriggerPrice  Decimal `json:"trigger_price"`
+}
+
+// Fill is one execution against an order.
+type Fill struct {
+	OrderID   string  `json:"order_id"`
+	TradeID   string  `json:"trade_id,omitempty"`
+	Symbol    string  `json:"symbol"`
+	Side      string  `json:"side"`
+	Price     Decimal `json:"price"`
+	Size      Decimal `json:"size"`
+	FeeUsd    Decimal `json:"fee_usd"`
+	TimeEpoch int64   `json:"time_epoch"`
+}
+
+// OrderQuery filters Journal.Orders. Zero values mean "any"; From/To
+// are inclusive epoch seconds on CreatedAtEpoch.
+type OrderQuery struct {
+	Symbol string
+	Status string
+	From   int64
+	To     int64
+	Offset int
+	Limit  int
+}
+
+// Journal is the durable record of orders, their status changes and
+// fills. Lookups are served from memory; writes go to disk first.
+type Journal interface {
+	RecordOrder(o OrderRow) error
+	// UpdateOrder applies fn to the stored order and records the result.
+	UpdateOrder(orderID string, fn func(*OrderRow)) error
+	RecordFill(f Fill) error
+
+	Order(orderID string) (OrderRow, bool)
+	FindOrders(match func(OrderRow) bool) []OrderRow
+	// Orders returns one page, newest first, plus the total match count.
+	Orders(q OrderQuery) ([]OrderRow, int)
+	Fills(orderID string) []Fill
+
+	Close() error
+}
+
+var ErrOrderNotFound = errors.New("order not found")
+
+// IsTerminalStatus is true once an order can no longer change.
+func IsTerminalStatus(status string) bool {
+	return status != "open" && status != "pending"
+}
+
+// ----- file-backed journal -----
+
+// journalEntry is one line of the journal file.
+type journalEntry struct {
+	Kind  string    `json:"kind"` // "order" | "update" | "fill"
+	At    int64     `json:"at"`
+	Order *OrderRow `json:"order,omitempty"`
+	Fill  *Fill     `json:"fill,omitempty"`
+}
+
+// compact once the file has this many lines more than live records
+const journalCompactSlack = 5000
+
+// FileJournal is an append-only JSON-lines journal. Every change is one
+// line, replayed on open; the file is rewritten from memory once it has
+// too many superseded lines, dropping finished orders older than maxAge.
+type FileJournal struct {
+	mu     sync.Mutex
+	path   string
+	f      *os.File
+	maxAge time.Duration
+
+	orders  map[string]*OrderRow
+	ids     []string // insertion order
+	fills   map[string][]Fill
+	nFills  int
+	entries int // lines in the file
+}
+
+// OpenFileJournal opens (or creates) the journal at path. maxAge <= 0
+// keeps finished orders forever.
+func OpenFileJournal(path string, maxAge time.Duration) (*FileJournal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("journal mkdir: %w", err)
+	}
+
+	j := &FileJournal{
+		path:   path,
+		maxAge: maxAge,
+		orders: make(map[string]*OrderRow),
+		fills:  make(map[string][]Fill),
+	}
+	if err := j.replay(); err != nil {
+		return nil, err
+	}
+	if err := j.compactLocked(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *FileJournal) replay() error {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("journal open: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		var e journalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// a crash mid-append leaves a torn last line; skip it
+			log.Printf("journal: skipping bad line %d: %v", line, err)
+			continue
+		}
+		j.apply(e)
+	}
+	return sc.Err()
+}
+
+func (j *FileJournal) apply(e journalEntry) {
+	j.entries++
+	switch e.Kind {
+	case "order", "update":
+		if e.Order == nil {
+			return
+		}
+		row := *e.Order
+		if _, ok := j.orders[row.OrderID]; !ok {
+			j.ids = append(j.ids, row.OrderID)
+		}
+		j.orders[row.OrderID] = &row
+	case "fill":
+		if e.Fill == nil {
+			return
+		}
+		j.fills[e.Fill.OrderID] = append(j.fills[e.Fill.OrderID], *e.Fill)
+		j.nFills++
+	}
+}
+
+// appendLocked writes e to disk, then applies it in memory.
+func (j *FileJournal) appendLocked(e journalEntry) error {
+	if j.f == nil {
+		return errors.New("journal closed")
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("journal write: %w", err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return fmt.Errorf("journal sync: %w", err)
+	}
+	j.apply(e)
+
+	// e is on disk already; a failed compaction is retried next time
+	if j.entries > len(j.orders)+j.nFills+journalCompactSlack {
+		if err := j.compactLocked(); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	return nil
+}
+
+// compactLocked rewrites the file with one line per live record. The
+// new file replaces the old one only once it is fully on disk; until
+// then appends keep going to the old one.
+func (j *FileJournal) compactLocked() error {
+	if j.maxAge > 0 {
+		cutoff := time.Now().Add(-j.maxAge).Unix()
+		kept := j.ids[:0]
+		for _, id := range j.ids {
+			o := j.orders[id]
+			if IsTerminalStatus(o.Status) && o.CreatedAtEpoch < cutoff {
+				delete(j.orders, id)
+				delete(j.fills, id)
+				continue
+			}
+			kept = append(kept, id)
+		}
+		j.ids = kept
+	}
+	// fills for an order we don't have can't be looked up; drop them
+	for id, fs := range j.fills {
+		if _, ok := j.orders[id]; !ok {
+			log.Printf("journal: dropping %d fills for unknown order %s", len(fs), id)
+			delete(j.fills, id)
+		}
+	}
+
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("journal compact: %w", err)
+	}
+	fail := func(err error) error {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("journal compact: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	now := time.Now().Unix()
+	fills := 0
+	for _, id := range j.ids {
+		if err := enc.Encode(journalEntry{Kind: "order", At: now, Order: j.orders[id]}); err != nil {
+			return fail(err)
+		}
+		for i := range j.fills[id] {
+			if err := enc.Encode(journalEntry{Kind: "fill", At: now, Fill: &j.fills[id][i]}); err != nil {
+				return fail(err)
+			}
+			fills++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := f.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return fail(err)
+	}
+
+	// f now is the journal, already open for appending
+	if j.f != nil {
+		j.f.Close()
+	}
+	j.f = f
+	j.nFills = fills
+	j.entries = len(j.ids) + fills
+	return nil
+}
+
+func (j *FileJournal) RecordOrder(o OrderRow) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if o.UpdatedAtEpoch == 0 {
+		o.UpdatedAtEpoch = o.CreatedAtEpoch
+	}
+	return j.appendLocked(journalEntry{Kind: "order", At: time.Now().Unix(), Order: &o})
+}
+
+func (j *FileJournal) UpdateOrder(orderID string, fn func(*OrderRow)) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	cur, ok := j.orders[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	row := *cur
+	fn(&row)
+	row.OrderID = orderID
+	now := time.Now().Unix()
+	row.UpdatedAtEpoch = now
+	return j.appendLocked(journalEntry{Kind: "update", At: now, Order: &row})
+}
+
+func (j *FileJournal) RecordFill(f Fill) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if f.TimeEpoch == 0 {
+		f.TimeEpoch = time.Now().Unix()
+	}
+	return j.appendLocked(journalEntry{Kind: "fill", At: time.Now().Unix(), Fill: &f})
+}
+
+func (j *FileJournal) Order(orderID string) (OrderRow, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	o, ok := j.orders[orderID]
+	if !ok {
+		return OrderRow{}, false
+	}
+	return *o, true
+}
+
+func (j *FileJournal) FindOrders(match func(OrderRow) bool) []OrderRow {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var out []OrderRow
+	for _, id := range j.ids {
+		if o := j.orders[id]; match(*o) {
+			out = append(out, *o)
+		}
+	}
+	return out
+}
+
+func (j *FileJournal) Orders(q OrderQuery) ([]OrderRow, int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := []OrderRow{}
+	total := 0
+	for i := len(j.ids) - 1; i >= 0; i-- {
+		o := j.orders[j.ids[i]]
+		if q.Symbol != "" && o.Symbol != q.Symbol {
+			continue
+		}
+		if q.Status != "" && o.Status != q.Status {
+			continue
+		}
+		if q.From > 0 && o.CreatedAtEpoch < q.From {
+			continue
+		}
+		if q.To > 0 && o.CreatedAtEpoch > q.To {
+			continue
+		}
+		total++
+		if total > q.Offset && (q.Limit <= 0 || len(out) < q.Limit) {
+			out = append(out, *o)
+		}
+	}
+	return out, total
+}
+
+func (j *FileJournal) Fills(orderID string) []Fill {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	out := make([]Fill, len(j.fills[orderID]))
+	copy(out, j.fills[orderID])
+	return out
+}
+
+func (j *FileJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
@@ -0,0 +1,183 @@
+// backend/internal/lighter/journal_test.go
+package internal
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestJournal(t *testing.T, path string, maxAge time.Duration) *FileJournal {
+	t.Helper()
+	j, err := OpenFileJournal(path, maxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { j.Close() })
+	return j
+}
+
+func journalLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		out = append(out, sc.Text())
+	}
+	return out
+}
+
+func TestFileJournalReplay(t *testing.T) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	cases := []struct {
+		name   string
+		lines  []string
+		orders map[string]string // id -> status
+		fills  map[string]int
+	}{
+		{
+			name: "order, update and fill",
+			lines: []string{
+				`{"kind":"order","order":{"order_id":"1","status":"open","price":"3024.5","created_at_epoch":` + ts + `}}`,
+				`{"kind":"update","order":{"order_id":"1","status":"filled","price":3024.5,"created_at_epoch":` + ts + `}}`,
+				`{"kind":"fill","fill":{"order_id":"1","trade_id":"t1","price":"3024.5","size":"0.1"}}`,
+			},
+			orders: map[string]string{"1": "filled"},
+			fills:  map[string]int{"1": 1},
+		},
+		{
+			name: "torn last line is skipped",
+			lines: []string{
+				`{"kind":"order","order":{"order_id":"1","status":"open","created_at_epoch":` + ts + `}}`,
+				`{"kind":"fill","fill":{"order_id":"1","trade_id":"t1","price":"1","si`,
+			},
+			orders: map[string]string{"1": "open"},
+			fills:  map[string]int{"1": 0},
+		},
+		{
+			name: "old finished orders are pruned with their fills",
+			lines: []string{
+				`{"kind":"order","order":{"order_id":"old","status":"filled","created_at_epoch":1}}`,
+				`{"kind":"fill","fill":{"order_id":"old","trade_id":"t1","price":"1","size":"1"}}`,
+				`{"kind":"order","order":{"order_id":"old-open","status":"open","created_at_epoch":1}}`,
+				`{"kind":"order","order":{"order_id":"new","status":"cancelled","created_at_epoch":` + ts + `}}`,
+			},
+			orders: map[string]string{"old-open": "open", "new": "cancelled"},
+			fills:  map[string]int{"old": 0},
+		},
+		{
+			name: "fills without an order are dropped",
+			lines: []string{
+				`{"kind":"order","order":{"order_id":"1","status":"open","created_at_epoch":` + ts + `}}`,
+				`{"kind":"fill","fill":{"order_id":"ghost","trade_id":"t1","price":"1","size":"1"}}`,
+			},
+			orders: map[string]string{"1": "open"},
+			fills:  map[string]int{"ghost": 0},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "journal.jsonl")
+			if err := os.WriteFile(path, []byte(strings.Join(c.lines, "\n")+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			j := openTestJournal(t, path, 24*time.Hour)
+
+			got := j.FindOrders(func(OrderRow) bool { return true })
+			if len(got) != len(c.orders) {
+				t.Fatalf("got %d orders, want %d", len(got), len(c.orders))
+			}
+			for _, o := range got {
+				if o.Status != c.orders[o.OrderID] {
+					t.Errorf("order %s is %q, want %q", o.OrderID, o.Status, c.orders[o.OrderID])
+				}
+			}
+			for id, n := range c.fills {
+				if got := len(j.Fills(id)); got != n {
+					t.Errorf("order %s has %d fills, want %d", id, got, n)
+				}
+			}
+
+			// the file was compacted to exactly what's live
+			if lines := journalLines(t, path); len(lines) != j.entries || j.entries != len(j.orders)+j.nFills {
+				t.Errorf("%d lines, entries %d, orders %d, fills %d", len(lines), j.entries, len(j.orders), j.nFills)
+			}
+		})
+	}
+}
+
+func TestFileJournalRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j := openTestJournal(t, path, 0)
+
+	row := OrderRow{OrderID: "42", Symbol: "ETH", Status: "open", Price: MustDecimal("3024.50"), SizeContracts: MustDecimal("0.1"), CreatedAtEpoch: 1}
+	if err := j.RecordOrder(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.UpdateOrder("42", func(o *OrderRow) { o.Status = "filled"; o.OrderID = "other" }); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.UpdateOrder("nope", func(*OrderRow) {}); err != ErrOrderNotFound {
+		t.Fatalf("update of unknown order: %v", err)
+	}
+	if err := j.RecordFill(Fill{OrderID: "42", TradeID: "t1", Price: MustDecimal("3024.5"), Size: MustDecimal("0.1")}); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+	if err := j.RecordFill(Fill{OrderID: "42"}); err == nil {
+		t.Fatal("write after close succeeded")
+	}
+
+	j = openTestJournal(t, path, 0)
+	o, ok := j.Order("42")
+	if !ok || o.Status != "filled" || !o.Price.Equal(MustDecimal("3024.5")) || o.UpdatedAtEpoch == 0 {
+		t.Fatalf("reopened order %+v, %v", o, ok)
+	}
+	fills := j.Fills("42")
+	if len(fills) != 1 || !fills[0].Size.Equal(MustDecimal("0.1")) || fills[0].TimeEpoch == 0 {
+		t.Fatalf("reopened fills %+v", fills)
+	}
+}
+
+func TestFileJournalCompactRenameFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "journal.jsonl")
+	j := openTestJournal(t, path, 0)
+	if err := j.RecordOrder(OrderRow{OrderID: "1", Status: "open"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// put a non-empty directory where the journal is, so the rename fails
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(path, "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	j.mu.Lock()
+	err := j.compactLocked()
+	j.mu.Unlock()
+	if err == nil {
+		t.Fatal("compaction over a directory succeeded")
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temp file left behind: %v", err)
+	}
+
+	// the old file is still open: writes keep working
+	if err := j.RecordOrder(OrderRow{OrderID: "2", Status: "open"}); err != nil {
+		t.Fatalf("write after failed compaction: %v", err)
+	}
+	if _, ok := j.Order("2"); !ok {
+		t.Fatal("order 2 missing")
+	}
+}
@@ -0,0 +1,267 @@
+// backend/internal/lighter/killswitch.go
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Trip sources.
+const (
+	KillSourceOperator  = "operator"
+	KillSourceDailyLoss = "daily_loss"
+)
+
+// KillSwitchTrip is one entry in the trip history.
+type KillSwitchTrip struct {
+	At        int64  `json:"at"`
+	Source    string `json:"source"` // KillSourceOperator | KillSourceDailyLoss
+	Reason    string `json:"reason"`
+	RearmedAt int64  `json:"rearmed_at,omitempty"`
+	RearmedBy string `json:"rearmed_by,omitempty"`
+}
+
+// KillSwitchState is what gets persisted. The day's PnL is what the
+// bot's fills realized since the start of Day (UTC), net of fees, plus
+// the change in unrealized PnL since the first observation of the day.
+// Re-arming after a daily loss trip starts the day's count again.
+// Positions is the ledger the fills are realized against.
+type KillSwitchState struct {
+	Tripped bool             `json:"tripped"`
+	Trips   []KillSwitchTrip `json:"trips"`
+
+	Day               string                 `json:"day,omitempty"`
+	DayRealized       Decimal                `json:"day_realized"`
+	DayUnrealizedBase *Decimal               `json:"day_unrealized_base,omitempty"`
+	DayPnl            Decimal                `json:"day_pnl"`
+	Positions         map[string]PnlPosition `json:"positions,omitempty"`
+}
+
+// PnlPosition is one symbol in the kill switch's ledger.
+type PnlPosition struct {
+	Size     Decimal `json:"size"` // signed: long is positive
+	AvgEntry Decimal `json:"avg_entry"`
+}
+
+// keep the trip log bounded; old trips fall off the front
+const maxKillSwitchTrips = 200
+
+var ErrKillSwitchArmed = errors.New("kill switch is not tripped")
+
+// KillSwitch blocks new orders once tripped, until an operator re-arms
+// it. State lives in a JSON file so a restart stays halted.
+type KillSwitch struct {
+	mu     sync.Mutex
+	path   string
+	state  KillSwitchState
+	seeded bool // ledger taken from the account this run
+}
+
+func OpenKillSwitch(path string) (*KillSwitch, error) {
+	k := &KillSwitch{path: path}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read kill switch state: %w", err)
+	}
+	if err := json.Unmarshal(b, &k.state); err != nil {
+		return nil, fmt.Errorf("decode kill switch state: %w", err)
+	}
+	return k, nil
+}
+
+// Tripped reports whether trading is halted, with the latest reason.
+func (k *KillSwitch) Tripped() (bool, string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if !k.state.Tripped || len(k.state.Trips) == 0 {
+		return k.state.Tripped, ""
+	}
+	return true, k.state.Trips[len(k.state.Trips)-1].Reason
+}
+
+// Trip halts trading and records why. It returns false if the switch
+// was already tripped (the extra trip is still logged).
+func (k *KillSwitch) Trip(source, reason string) (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	first := !k.state.Tripped
+	k.state.Tripped = true
+	k.state.Trips = append(k.state.Trips, KillSwitchTrip{
+		At:     time.Now().Unix(),
+		Source: source,
+		Reason: reason,
+	})
+	if n := len(k.state.Trips); n > maxKillSwitchTrips {
+		k.state.Trips = k.state.Trips[n-maxKillSwitchTrips:]
+	}
+	return first, k.saveLocked()
+}
+
+// Rearm lets orders through again. If the daily loss limit tripped it,
+// the day's PnL counts from here, so the same loss doesn't trip it
+// again on the next check.
+func (k *KillSwitch) Rearm(by string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if !k.state.Tripped {
+		return ErrKillSwitchArmed
+	}
+	k.state.Tripped = false
+	for i := len(k.state.Trips) - 1; i >= 0 && k.state.Trips[i].RearmedAt == 0; i-- {
+		if k.state.Trips[i].Source == KillSourceDailyLoss {
+			k.rollLocked(k.state.Day)
+			break
+		}
+	}
+	if n := len(k.state.Trips); n > 0 {
+		k.state.Trips[n-1].RearmedAt = time.Now().Unix()
+		k.state.Trips[n-1].RearmedBy = by
+	}
+	return k.saveLocked()
+}
+
+// ObserveFill books a fill against the ledger and adds what it
+// realized to the day it happened on.
+func (k *KillSwitch) ObserveFill(f Fill) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	realized := k.applyFillLocked(f)
+	at := time.Now()
+	if f.TimeEpoch > 0 {
+		at = time.Unix(f.TimeEpoch, 0)
+	}
+	day := at.UTC().Format("2006-01-02")
+	if day > k.state.Day {
+		k.rollLocked(day)
+	}
+	if day == k.state.Day {
+		k.state.DayRealized = k.state.DayRealized.Add(realized)
+	}
+	return k.saveLocked()
+}
+
+// applyFillLocked moves the ledger position by f and returns the PnL
+// realized by the part that closed it, less the fee.
+func (k *KillSwitch) applyFillLocked(f Fill) Decimal {
+	if k.state.Positions == nil {
+		k.state.Positions = make(map[string]PnlPosition)
+	}
+	pos := k.state.Positions[f.Symbol]
+	qty := f.Size
+	if f.Side == "sell" {
+		qty = qty.Neg()
+	}
+
+	realized := f.FeeUsd.Neg()
+	switch {
+	case pos.Size.IsZero() || pos.Size.Sign() == qty.Sign():
+		// opening or adding: the entry becomes the size-weighted average
+		size := pos.Size.Add(qty)
+		cost := pos.AvgEntry.Mul(pos.Size).Add(f.Price.Mul(qty))
+		pos.AvgEntry, _ = cost.Div(size) // size is non-zero: qty has pos's sign
+		pos.Size = size
+	default:
+		closed := qty.Abs()
+		if pos.Size.Abs().LessThan(closed) {
+			closed = pos.Size.Abs()
+		}
+		pnl := f.Price.Sub(pos.AvgEntry).Mul(closed)
+		if pos.Size.Sign() < 0 {
+			pnl = pnl.Neg()
+		}
+		realized = realized.Add(pnl)
+		pos.Size = pos.Size.Add(qty)
+		if pos.Size.Sign() == qty.Sign() {
+			pos.AvgEntry = f.Price // flipped: the rest opened at this price
+		}
+	}
+	if pos.Size.IsZero() {
+		pos.AvgEntry = Decimal{}
+	}
+	k.state.Positions[f.Symbol] = pos
+	return realized
+}
+
+// SeedPositions replaces the ledger with the account's positions, once
+// per run: fills missed while the bot was down would leave the saved one
+// wrong. Call it before fills are observed; from then on only fills move
+// the ledger, and later calls do nothing.
+func (k *KillSwitch) SeedPositions(open map[string]PnlPosition) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.seeded {
+		return nil
+	}
+	k.seeded = true
+	k.state.Positions = make(map[string]PnlPosition, len(open))
+	for sym, p := range open {
+		k.state.Positions[sym] = p
+	}
+	return k.saveLocked()
+}
+
+// ObserveDayPnl takes the account's current unrealized PnL and returns
+// the PnL since the start of the UTC day.
+func (k *KillSwitch) ObserveDayPnl(now time.Time, unrealized Decimal) (Decimal, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if day := now.UTC().Format("2006-01-02"); day != k.state.Day {
+		k.rollLocked(day)
+	}
+	if k.state.DayUnrealizedBase == nil {
+		k.state.DayUnrealizedBase = &unrealized
+	}
+	k.state.DayPnl = k.state.DayRealized.Add(unrealized).Sub(*k.state.DayUnrealizedBase)
+	return k.state.DayPnl, k.saveLocked()
+}
+
+// rollLocked starts a new day; its unrealized base is taken at the
+// next observation.
+func (k *KillSwitch) rollLocked(day string) {
+	k.state.Day = day
+	k.state.DayRealized = Decimal{}
+	k.state.DayUnrealizedBase = nil
+	k.state.DayPnl = Decimal{}
+}
+
+func (k *KillSwitch) State() KillSwitchState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	st := k.state
+	st.Trips = append([]KillSwitchTrip(nil), k.state.Trips...)
+	st.Positions = make(map[string]PnlPosition, len(k.state.Positions))
+	for sym, p := range k.state.Positions {
+		st.Positions[sym] = p
+	}
+	return st
+}
+
+func (k *KillSwitch) saveLocked() error {
+	if err := os.MkdirAll(filepath.Dir(k.path), 0o755); err != nil {
+		return fmt.Errorf("kill switch mkdir: %w", err)
+	}
+	b, err := json.MarshalIndent(k.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := k.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("write kill switch state: %w", err)
+	}
+	return os.Rename(tmp, k.path)
+}
@@ -0,0 +1,147 @@
+// backend/internal/lighter/killswitch_test.go
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKillSwitchRealizedFromFills(t *testing.T) {
+	d := MustDecimal
+	fill := func(side, px, size string) Fill {
+		return Fill{Symbol: "ETH", Side: side, Price: d(px), Size: d(size)}
+	}
+	cases := []struct {
+		name     string
+		fills    []Fill
+		realized string
+		size     string
+		entry    string
+	}{
+		{"open only", []Fill{fill("buy", "100", "2")}, "0", "2", "100"},
+		{"add averages the entry", []Fill{fill("buy", "100", "1"), fill("buy", "110", "1")}, "0", "2", "105"},
+		{"partial close", []Fill{fill("buy", "100", "2"), fill("sell", "110", "1")}, "10", "1", "100"},
+		{"short closed at a loss", []Fill{fill("sell", "100", "1"), fill("buy", "104", "1")}, "-4", "0", "0"},
+		{"flip", []Fill{fill("buy", "100", "1"), fill("sell", "90", "3")}, "-10", "-2", "90"},
+		{"fees count", []Fill{{Symbol: "ETH", Side: "buy", Price: d("100"), Size: d("1"), FeeUsd: d("0.25")}}, "-0.25", "1", "100"},
+	}
+	for _, c := range cases {
+		k, err := OpenKillSwitch(filepath.Join(t.TempDir(), "killswitch.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range c.fills {
+			if err := k.ObserveFill(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		st := k.State()
+		pos := st.Positions["ETH"]
+		if st.DayRealized.String() != c.realized || pos.Size.String() != c.size || pos.AvgEntry.String() != c.entry {
+			t.Errorf("%s: realized %s, position %s @ %s; want %s, %s @ %s",
+				c.name, st.DayRealized, pos.Size, pos.AvgEntry, c.realized, c.size, c.entry)
+		}
+	}
+}
+
+func TestKillSwitchDayPnlPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "killswitch.json")
+	day := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	k, err := OpenKillSwitch(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SeedPositions(map[string]PnlPosition{"ETH": {Size: MustDecimal("1"), AvgEntry: MustDecimal("100")}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.ObserveDayPnl(day, MustDecimal("-20")); err != nil {
+		t.Fatal(err)
+	}
+	// the position closes: its unrealized PnL leaves the account, its
+	// realized PnL comes in as a fill
+	if err := k.ObserveFill(Fill{Symbol: "ETH", Side: "sell", Price: MustDecimal("70"), Size: MustDecimal("1"), TimeEpoch: day.Unix() + 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restart keeps the day's base and realized PnL, and takes the
+	// ledger from the account again
+	k, err = OpenKillSwitch(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SeedPositions(map[string]PnlPosition{"BTC": {Size: MustDecimal("1")}}); err != nil {
+		t.Fatal(err)
+	}
+	pnl, err := k.ObserveDayPnl(day.Add(time.Hour), MustDecimal("0"))
+	if err != nil || pnl.String() != "-10" {
+		t.Fatalf("day PnL after restart: %s, %v; want -10", pnl, err)
+	}
+	if pos := k.State().Positions; len(pos) != 1 || pos["BTC"].Size.String() != "1" {
+		t.Errorf("ledger after restart %v, want the account's BTC only", pos)
+	}
+
+	// a new day starts from zero
+	pnl, _ = k.ObserveDayPnl(day.Add(24*time.Hour), MustDecimal("5"))
+	if !pnl.IsZero() {
+		t.Errorf("next day PnL %s, want 0", pnl)
+	}
+}
+
+func TestKillSwitchSeedReplacesEarlyFills(t *testing.T) {
+	k, err := OpenKillSwitch(filepath.Join(t.TempDir(), "killswitch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a fill before the seed lands on an empty ledger; the account,
+	// which already has it, wins
+	if err := k.ObserveFill(Fill{Symbol: "ETH", Side: "buy", Price: MustDecimal("100"), Size: MustDecimal("1")}); err != nil {
+		t.Fatal(err)
+	}
+	seed := map[string]PnlPosition{"ETH": {Size: MustDecimal("3"), AvgEntry: MustDecimal("90")}}
+	if err := k.SeedPositions(seed); err != nil {
+		t.Fatal(err)
+	}
+	// only once per run
+	if err := k.SeedPositions(map[string]PnlPosition{}); err != nil {
+		t.Fatal(err)
+	}
+	if pos := k.State().Positions["ETH"]; pos.Size.String() != "3" || pos.AvgEntry.String() != "90" {
+		t.Errorf("ledger %s @ %s, want 3 @ 90", pos.Size, pos.AvgEntry)
+	}
+}
+
+func TestKillSwitchRearmResetsDailyLoss(t *testing.T) {
+	day := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		source string
+		after  string // day PnL on the next check
+	}{
+		{"daily loss trip starts the count again", KillSourceDailyLoss, "0"},
+		{"operator trip keeps the day's loss", KillSourceOperator, "-50"},
+	}
+	for _, c := range cases {
+		k, err := OpenKillSwitch(filepath.Join(t.TempDir(), "killswitch.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := k.ObserveDayPnl(day, MustDecimal("0")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := k.ObserveDayPnl(day.Add(time.Minute), MustDecimal("-50")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := k.Trip(c.source, "test"); err != nil {
+			t.Fatal(err)
+		}
+		if err := k.Rearm("test"); err != nil {
+			t.Fatal(err)
+		}
+		pnl, err := k.ObserveDayPnl(day.Add(2*time.Minute), MustDecimal("-50"))
+		if err != nil || pnl.String() != c.after {
+			t.Errorf("%s: day PnL %s, %v; want %s", c.name, pnl, err, c.after)
+		}
+	}
+}
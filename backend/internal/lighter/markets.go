@@ -0,0 +1,256 @@
+// backend/internal/lighter/markets.go
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnknownMarket = errors.New("unknown market")
+
+// MarketOrderMaxSlippage bounds a market order's worst price: it is sent
+// as IOC this far from the last trade.
+var MarketOrderMaxSlippage = MustDecimal("0.01")
+
+// GridMode says what happens to a price or size that isn't a whole
+// number of ticks/steps: GridRound moves it onto the grid, GridReject
+// refuses the order.
+type GridMode string
+
+const (
+	GridRound  GridMode = "round"
+	GridReject GridMode = "reject"
+)
+
+// GridModeFromEnv reads ORDER_GRID_MODE (round|reject, default round).
+func GridModeFromEnv() GridMode {
+	if strings.EqualFold(os.Getenv("ORDER_GRID_MODE"), string(GridReject)) {
+		return GridReject
+	}
+	return GridRound
+}
+
+// MarketSpec is a market's trading grid and minimums from
+// orderBookDetails.
+type MarketSpec struct {
+	Symbol         string  `json:"symbol"`
+	MarketID       int     `json:"market_id"`
+	SizeDecimals   int     `json:"size_decimals"`
+	PriceDecimals  int     `json:"price_decimals"`
+	MinBaseAmount  Decimal `json:"min_base_amount"`
+	MinQuoteAmount Decimal `json:"min_quote_amount"`
+	LastTradePrice Decimal `json:"last_trade_price"`
+}
+
+// TickSize is the smallest price increment.
+func (m MarketSpec) TickSize() Decimal { return NewDecimalFromInt(1).Shift(-m.PriceDecimals) }
+
+// StepSize is the smallest size increment.
+func (m MarketSpec) StepSize() Decimal { return NewDecimalFromInt(1).Shift(-m.SizeDecimals) }
+
+// OnTick reports whether px is a whole number of ticks.
+func (m MarketSpec) OnTick(px Decimal) bool { return px.Truncate(m.PriceDecimals).Equal(px) }
+
+// PriceTicks converts px to integer price units. Off-tick prices are
+// refused under GridReject; under GridRound they move up or down a tick
+// as roundUp says, so callers can round toward the passive side.
+func (m MarketSpec) PriceTicks(px Decimal, mode GridMode, roundUp bool) (uint32, error) {
+	if !m.OnTick(px) {
+		if mode == GridReject {
+			return 0, fmt.Errorf("price %v is not a multiple of the %s tick %v", px, m.Symbol, m.TickSize())
+		}
+		if roundUp {
+			px = px.Ceil(m.PriceDecimals)
+		} else {
+			px = px.Floor(m.PriceDecimals)
+		}
+	}
+	n, ok := px.Shift(m.PriceDecimals).Int64()
+	if !ok || n <= 0 || n > math.MaxUint32 {
+		return 0, fmt.Errorf("price %v out of range for %s", px, m.Symbol)
+	}
+	return uint32(n), nil
+}
+
+// BaseAmount converts size to integer base units. Off-step sizes are
+// refused under GridReject and rounded down under GridRound, so an
+// order is never bigger than asked.
+func (m MarketSpec) BaseAmount(size Decimal, mode GridMode) (int64, error) {
+	if !size.Truncate(m.SizeDecimals).Equal(size) {
+		if mode == GridReject {
+			return 0, fmt.Errorf("size %v is not a multiple of the %s step %v", size, m.Symbol, m.StepSize())
+		}
+		size = size.Floor(m.SizeDecimals)
+	}
+	n, ok := size.Shift(m.SizeDecimals).Int64()
+	if !ok {
+		return 0, fmt.Errorf("size %v out of range for %s", size, m.Symbol)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("size is below the %s step %v", m.Symbol, m.StepSize())
+	}
+	return n, nil
+}
+
+// CheckMinimums enforces min_base_amount and min_quote_amount on an
+// order already on the grid.
+func (m MarketSpec) CheckMinimums(price uint32, base int64) error {
+	size := NewDecimalScaled(base, m.SizeDecimals)
+	if size.LessThan(m.MinBaseAmount) {
+		return fmt.Errorf("size %v is below the %s minimum %v", size, m.Symbol, m.MinBaseAmount)
+	}
+	notional := size.Mul(NewDecimalScaled(int64(price), m.PriceDecimals))
+	if notional.LessThan(m.MinQuoteAmount) {
+		return fmt.Errorf("order value $%v is below the %s minimum $%v", notional, m.Symbol, m.MinQuoteAmount)
+	}
+	return nil
+}
+
+// ScaleOrder maps a validated OrderRequest onto Lighter's integer price
+// and base amount. Market orders get a slippage-bounded worst price.
+// Prices off the tick round toward the passive side (buys down, sells
+// up) or are refused, per mode; size_usd is converted at that price and
+// always rounded down to the step. The result must clear the market's
+// minimum size and value.
+func (m MarketSpec) ScaleOrder(req OrderRequest, mode GridMode) (uint32, int64, error) {
+	isAsk := req.Side == "sell"
+
+	var (
+		px    Decimal
+		price uint32
+		err   error
+	)
+	if req.Type == "limit" {
+		px = *req.Price
+		price, err = m.PriceTicks(px, mode, isAsk)
+	} else {
+		if !m.LastTradePrice.IsPositive() {
+			return 0, 0, fmt.Errorf("no reference price for %s market order", req.Symbol)
+		}
+		one := NewDecimalFromInt(1)
+		if isAsk {
+			px = m.LastTradePrice.Mul(one.Sub(MarketOrderMaxSlippage))
+		} else {
+			px = m.LastTradePrice.Mul(one.Add(MarketOrderMaxSlippage))
+		}
+		// our own worst price, not the caller's: always round it
+		price, err = m.PriceTicks(px, GridRound, isAsk)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var base int64
+	if req.SizeContracts != nil && req.SizeContracts.IsPositive() {
+		base, err = m.BaseAmount(*req.SizeContracts, mode)
+	} else {
+		onGrid := NewDecimalScaled(int64(price), m.PriceDecimals)
+		var size Decimal
+		if size, err = req.SizeUSD.Div(onGrid); err != nil {
+			return 0, 0, fmt.Errorf("size from usd: %w", err)
+		}
+		base, err = m.BaseAmount(size, GridRound)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := m.CheckMinimums(price, base); err != nil {
+		return 0, 0, err
+	}
+	return price, base, nil
+}
+
+// MarketRegistry maps symbols and market ids to specs. It re-reads
+// orderBookDetails through the client cache and only re-parses when
+// that returns a new response.
+type MarketRegistry struct {
+	lc *LighterClient
+
+	mu        sync.Mutex
+	fetchedAt time.Time
+	bySymbol  map[string]MarketSpec
+	byID      map[int]MarketSpec
+}
+
+func NewMarketRegistry(lc *LighterClient) *MarketRegistry {
+	return &MarketRegistry{lc: lc}
+}
+
+func (r *MarketRegistry) refresh(ctx context.Context) error {
+	cr, err := r.lc.OrderBookDetailsCached(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.bySymbol != nil && cr.FetchedAt.Equal(r.fetchedAt) {
+		return nil
+	}
+
+	var resp struct {
+		OrderBookDetails []MarketSpec `json:"order_book_details"`
+	}
+	if err := json.Unmarshal(cr.Raw, &resp); err != nil {
+		return fmt.Errorf("decode market specs: %w", err)
+	}
+	r.bySymbol = make(map[string]MarketSpec, len(resp.OrderBookDetails))
+	r.byID = make(map[int]MarketSpec, len(resp.OrderBookDetails))
+	for _, m := range resp.OrderBookDetails {
+		r.bySymbol[m.Symbol] = m
+		r.byID[m.MarketID] = m
+	}
+	r.fetchedAt = cr.FetchedAt
+	return nil
+}
+
+// Lookup returns the spec for symbol, or ErrUnknownMarket.
+func (r *MarketRegistry) Lookup(ctx context.Context, symbol string) (MarketSpec, error) {
+	if err := r.refresh(ctx); err != nil {
+		return MarketSpec{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.bySymbol[symbol]
+	if !ok {
+		return MarketSpec{}, ErrUnknownMarket
+	}
+	return m, nil
+}
+
+// ByID returns the spec for a market id, or ErrUnknownMarket.
+func (r *MarketRegistry) ByID(ctx context.Context, marketID int) (MarketSpec, error) {
+	if err := r.refresh(ctx); err != nil {
+		return MarketSpec{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.byID[marketID]
+	if !ok {
+		return MarketSpec{}, ErrUnknownMarket
+	}
+	return m, nil
+}
+
+// All returns every spec, ordered by market id.
+func (r *MarketRegistry) All(ctx context.Context) ([]MarketSpec, error) {
+	if err := r.refresh(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]MarketSpec, 0, len(r.byID))
+	for _, m := range r.byID {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].MarketID < out[j].MarketID })
+	return out, nil
+}
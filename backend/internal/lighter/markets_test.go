@@ -0,0 +1,60 @@
+// backend/internal/lighter/markets_test.go
+package internal
+
+import "testing"
+
+func TestScaleOrder(t *testing.T) {
+	eth := MarketSpec{
+		Symbol:         "ETH",
+		SizeDecimals:   4,
+		PriceDecimals:  2,
+		MinBaseAmount:  MustDecimal("0.005"),
+		MinQuoteAmount: MustDecimal("10"),
+		LastTradePrice: MustDecimal("3000"),
+	}
+	dec := func(s string) *Decimal { v := MustDecimal(s); return &v }
+	limit := func(side, px string) OrderRequest {
+		return OrderRequest{Symbol: "ETH", Side: side, Type: "limit", Price: dec(px)}
+	}
+	withContracts := func(r OrderRequest, size string) OrderRequest { r.SizeContracts = dec(size); return r }
+	withUSD := func(r OrderRequest, usd string) OrderRequest { r.SizeUSD = dec(usd); return r }
+	market := func(side string) OrderRequest { return OrderRequest{Symbol: "ETH", Side: side, Type: "market"} }
+
+	cases := []struct {
+		name    string
+		spec    MarketSpec
+		req     OrderRequest
+		mode    GridMode
+		price   uint32
+		base    int64
+		wantErr bool
+	}{
+		{"on grid", eth, withContracts(limit("buy", "3024.5"), "0.1"), GridReject, 302450, 1000, false},
+		{"buy rounds down a tick", eth, withContracts(limit("buy", "3024.559"), "0.1"), GridRound, 302455, 1000, false},
+		{"sell rounds up", eth, withContracts(limit("sell", "3024.551"), "0.1"), GridRound, 302456, 1000, false},
+		{"off-tick price rejected", eth, withContracts(limit("buy", "3024.551"), "0.1"), GridReject, 0, 0, true},
+		{"size rounds down", eth, withContracts(limit("buy", "3000"), "0.12345"), GridRound, 300000, 1234, false},
+		{"off-step size rejected", eth, withContracts(limit("buy", "3000"), "0.12345"), GridReject, 0, 0, true},
+		{"size_usd at the limit price", eth, withUSD(limit("buy", "3000"), "300"), GridReject, 300000, 1000, false},
+		{"size_usd always rounds down", eth, withUSD(limit("buy", "3000"), "100"), GridReject, 300000, 333, false},
+		{"market buy bounded above", eth, withContracts(market("buy"), "0.1"), GridReject, 303000, 1000, false},
+		{"market sell bounded below", eth, withContracts(market("sell"), "0.1"), GridReject, 297000, 1000, false},
+		{"market without a reference price", MarketSpec{Symbol: "ETH", SizeDecimals: 4, PriceDecimals: 2}, withContracts(market("buy"), "0.1"), GridRound, 0, 0, true},
+		{"below min base", eth, withContracts(limit("buy", "3000"), "0.004"), GridRound, 0, 0, true},
+		{"below min quote", eth, withContracts(limit("buy", "1000"), "0.009"), GridRound, 0, 0, true},
+		{"rounds to nothing", eth, withContracts(limit("buy", "3000"), "0.00001"), GridRound, 0, 0, true},
+		{"price out of range", eth, withContracts(limit("buy", "99999999"), "0.1"), GridRound, 0, 0, true},
+	}
+	for _, c := range cases {
+		price, base, err := c.spec.ScaleOrder(c.req, c.mode)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %d, %d, want an error", c.name, price, base)
+			}
+			continue
+		}
+		if err != nil || price != c.price || base != c.base {
+			t.Errorf("%s: got %d, %d, %v, want %d, %d", c.name, price, base, err, c.price, c.base)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// backend/internal/lighter/nonce.go
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Lighter's error code for a tx whose nonce isn't the expected one.
+const codeInvalidNonce = 21104
+
+// how many times Do resyncs and retries after an invalid-nonce rejection
+const maxNonceRetries = 3
+
+// IsInvalidNonce reports whether err is an invalid-nonce rejection.
+// Only the exchange's code counts: other rejections may mention the
+// nonce without meaning the counter is wrong.
+func IsInvalidNonce(err error) bool {
+	rej, ok := AsAPIError(err)
+	if !ok || rej.Err != nil { // a transport failure is never a rejection
+		return false
+	}
+	return rej.Code == codeInvalidNonce
+}
+
+// NonceManager hands out tx nonces for one API key.
+//
+// The exchange is the source of truth: the first Next call (and any call
+// after Invalidate) fetches /api/v1/nextNonce. If statePath is set, the
+// last nonce handed out is also written there, so after a restart we
+// don't reuse a nonce the exchange hasn't indexed yet.
+//
+// Each sync starts a new generation. Do sends without holding the lock,
+// so a result can come back after another caller has already resynced;
+// such a stale result never invalidates the newer counter.
+type NonceManager struct {
+	lc           *LighterClient
+	accountIndex int64
+	apiKeyIndex  uint8
+	statePath    string
+
+	mu     sync.Mutex
+	next   int64
+	synced bool
+	gen    uint64
+	// highest nonce the exchange accepted; a sync never goes below it,
+	// in case nextNonce hasn't caught up yet
+	accepted int64
+	// trust the local high-water mark only on the first sync after start
+	restored bool
+}
+
+func NewNonceManager(lc *LighterClient, accountIndex int64, apiKeyIndex uint8, statePath string) *NonceManager {
+	return &NonceManager{
+		lc:           lc,
+		accountIndex: accountIndex,
+		apiKeyIndex:  apiKeyIndex,
+		statePath:    statePath,
+		accepted:     -1,
+	}
+}
+
+// Next returns the next nonce to sign with, syncing first if needed.
+func (m *NonceManager) Next(ctx context.Context) (int64, error) {
+	n, _, err := m.lease(ctx)
+	return n, err
+}
+
+// lease is Next plus the generation the nonce came from.
+func (m *NonceManager) lease(ctx context.Context) (int64, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.synced {
+		if err := m.syncLocked(ctx); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	n := m.next
+	m.next++
+	m.persistLocked(n)
+	return n, m.gen, nil
+}
+
+// Invalidate forces a resync from the exchange on the next call.
+func (m *NonceManager) Invalidate() {
+	m.mu.Lock()
+	m.synced = false
+	m.mu.Unlock()
+}
+
+// invalidate is Invalidate for a result from generation gen; it is a
+// no-op once the counter has resynced past gen. It reports whether it
+// invalidated.
+func (m *NonceManager) invalidate(gen uint64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if gen != m.gen {
+		return false
+	}
+	m.synced = false
+	return true
+}
+
+func (m *NonceManager) accept(n int64) {
+	m.mu.Lock()
+	if n > m.accepted {
+		m.accepted = n
+	}
+	m.mu.Unlock()
+}
+
+// Do calls fn with a fresh nonce. On an invalid-nonce rejection it
+// resyncs and retries; any other failure also invalidates the local
+// counter, since the burned nonce would leave a gap. Failures from a
+// generation that has since been resynced only trigger the retry.
+func (m *NonceManager) Do(ctx context.Context, fn func(nonce int64) error) error {
+	var err error
+	for attempt := 0; attempt <= maxNonceRetries; attempt++ {
+		nonce, gen, lerr := m.lease(ctx)
+		if lerr != nil {
+			return lerr
+		}
+
+		err = fn(nonce)
+		if err == nil {
+			m.accept(nonce)
+			return nil
+		}
+
+		stale := !m.invalidate(gen)
+		if !IsInvalidNonce(err) {
+			return err
+		}
+		log.Printf("nonce %d rejected, resyncing (attempt %d, stale %v): %v", nonce, attempt+1, stale, err)
+	}
+	return err
+}
+
+func (m *NonceManager) syncLocked(ctx context.Context) error {
+	n, err := m.lc.NextNonce(ctx, m.accountIndex, m.apiKeyIndex)
+	if err != nil {
+		return fmt.Errorf("sync nonce: %w", err)
+	}
+
+	if !m.restored {
+		m.restored = true
+		if last, ok := m.loadLocked(); ok && last+1 > n {
+			log.Printf("nonce: exchange says %d, local state says %d; using local", n, last+1)
+			n = last + 1
+		}
+	}
+	if m.accepted+1 > n {
+		n = m.accepted + 1
+	}
+
+	m.next = n
+	m.synced = true
+	m.gen++
+	return nil
+}
+
+func (m *NonceManager) loadLocked() (int64, bool) {
+	if m.statePath == "" {
+		return 0, false
+	}
+	b, err := os.ReadFile(m.statePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("nonce state read error: %v", err)
+		}
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		log.Printf("nonce state parse error: %v", err)
+		return 0, false
+	}
+	return n, true
+}
+
+// persistLocked writes via a temp file + rename so a crash mid-write
+// never leaves a truncated value behind.
+func (m *NonceManager) persistLocked(n int64) {
+	if m.statePath == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(m.statePath), 0o755); err != nil {
+		log.Printf("nonce state mkdir error: %v", err)
+		return
+	}
+	tmp := m.statePath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(n, 10)), 0o644); err != nil {
+		log.Printf("nonce state write error: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, m.statePath); err != nil {
+		log.Printf("nonce state rename error: %v", err)
+	}
+}
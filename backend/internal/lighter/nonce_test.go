@@ -0,0 +1,166 @@
+// backend/internal/lighter/nonce_test.go
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// nonceServer serves /api/v1/nextNonce from *next and counts calls.
+func nonceServer(t *testing.T, next *int64, calls *int32) *LighterClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		fmt.Fprintf(w, `{"code":200,"nonce":%d}`, atomic.LoadInt64(next))
+	}))
+	t.Cleanup(srv.Close)
+	return &LighterClient{baseURL: srv.URL, http: srv.Client()}
+}
+
+func TestIsInvalidNonce(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"invalid nonce code", &APIError{Code: codeInvalidNonce, Message: "invalid nonce"}, true},
+		{"wrapped", fmt.Errorf("send: %w", &APIError{Code: codeInvalidNonce}), true},
+		{"other code mentioning nonce", &APIError{Code: 21500, Message: "nonce too far ahead of account state"}, false},
+		{"transport", &APIError{Code: codeInvalidNonce, Err: errors.New("reset")}, false},
+		{"plain error", errors.New("invalid nonce"), false},
+		{"nil", nil, false},
+	}
+	for _, c := range cases {
+		if got := IsInvalidNonce(c.err); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNonceManagerSync(t *testing.T) {
+	cases := []struct {
+		name     string
+		exchange int64
+		state    string // local state file, "" for none
+		want     int64
+	}{
+		{"fresh", 10, "", 10},
+		{"local ahead", 10, "14", 15},
+		{"local behind", 10, "3", 10},
+		{"corrupt state", 10, "x", 10},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var calls int32
+			next := c.exchange
+			path := filepath.Join(t.TempDir(), "nonce.state")
+			if c.state != "" {
+				if err := os.WriteFile(path, []byte(c.state), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m := NewNonceManager(nonceServer(t, &next, &calls), 1, 0, path)
+
+			for i := int64(0); i < 3; i++ {
+				n, err := m.Next(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != c.want+i {
+					t.Fatalf("nonce %d = %d, want %d", i, n, c.want+i)
+				}
+			}
+			if calls != 1 {
+				t.Errorf("%d syncs, want 1", calls)
+			}
+			b, _ := os.ReadFile(path)
+			if string(b) != fmt.Sprint(c.want+2) {
+				t.Errorf("state %q, want %d", b, c.want+2)
+			}
+		})
+	}
+}
+
+func TestNonceManagerDo(t *testing.T) {
+	var calls int32
+	next := int64(5)
+	m := NewNonceManager(nonceServer(t, &next, &calls), 1, 0, "")
+
+	// rejected once: resync to the exchange's value and retry
+	var tried []int64
+	err := m.Do(context.Background(), func(n int64) error {
+		tried = append(tried, n)
+		if len(tried) == 1 {
+			atomic.StoreInt64(&next, 8)
+			return &APIError{Code: codeInvalidNonce}
+		}
+		return nil
+	})
+	if err != nil || fmt.Sprint(tried) != "[5 8]" {
+		t.Fatalf("tried %v, err %v", tried, err)
+	}
+
+	// any other rejection is returned as is, without a retry
+	other := &APIError{Code: 21500, Message: "bad nonce field"}
+	tried = nil
+	err = m.Do(context.Background(), func(n int64) error {
+		tried = append(tried, n)
+		return other
+	})
+	if err != other || len(tried) != 1 {
+		t.Fatalf("tried %v, err %v", tried, err)
+	}
+
+	// gives up after maxNonceRetries resyncs
+	tried = nil
+	err = m.Do(context.Background(), func(n int64) error {
+		tried = append(tried, n)
+		return &APIError{Code: codeInvalidNonce}
+	})
+	if !IsInvalidNonce(err) || len(tried) != maxNonceRetries+1 {
+		t.Fatalf("tried %v, err %v", tried, err)
+	}
+}
+
+func TestNonceManagerStaleGeneration(t *testing.T) {
+	var calls int32
+	next := int64(20)
+	m := NewNonceManager(nonceServer(t, &next, &calls), 1, 0, "")
+	ctx := context.Background()
+
+	// A leases 20 and is slow; B leases 21, gets rejected and resyncs
+	a, genA, _ := m.lease(ctx)
+	_, genB, _ := m.lease(ctx)
+	if !m.invalidate(genB) {
+		t.Fatal("current generation not invalidated")
+	}
+	atomic.StoreInt64(&next, 21)
+	n, err := m.Next(ctx)
+	if err != nil || n != 21 {
+		t.Fatalf("after resync got %d, %v", n, err)
+	}
+
+	// A's late failure belongs to the old generation: no new resync
+	if m.invalidate(genA) {
+		t.Error("stale generation invalidated the new counter")
+	}
+	if n, _ := m.Next(ctx); n != 22 || calls != 2 {
+		t.Errorf("next %d after %d syncs, want 22 after 2", n, calls)
+	}
+
+	// A's late success is remembered: a lagging nextNonce can't hand
+	// out an accepted nonce again
+	m.accept(a)
+	m.accept(30)
+	m.Invalidate()
+	if n, _ := m.Next(ctx); n != 31 {
+		t.Errorf("next after lagging sync = %d, want 31", n)
+	}
+}
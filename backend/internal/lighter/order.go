@@ -2,30 +2,194 @@
 package internal
 
 import (
-    "context"
-    "encoding/json"
-    "fmt"
-    "net/http"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 )
 
+// ----- API order shapes (what /api/trade/order takes and returns) -----
 
+type OrderRequest struct {
+	Symbol        string   `json:"symbol"`
+	Side          string   `json:"side"` // "buy" | "sell"
+	Type          string   `json:"type"` // "market" | "limit"
+	Price         *Decimal `json:"price,omitempty"`
+	SizeUSD       *Decimal `json:"size_usd,omitempty"`
+	SizeContracts *Decimal `json:"size_contracts,omitempty"`
+	Leverage      float64  `json:"leverage"`
+	ReduceOnly    bool     `json:"reduce_only"`
+	ClientID      string   `json:"client_id"`
+	Mode          string   `json:"mode,omitempty"` // "live" | "paper"; empty means TRADING_MODE
+
+	StopLoss   *Decimal `json:"stop_loss,omitempty"`
+	TakeProfit *Decimal `json:"take_profit,omitempty"`
+}
+
+// OrderResponse describes a placed order. For live orders OrderID is
+// the exchange order index when it could be looked up right after
+// sending, else the tx hash; OrderIndex and TxHash always carry those
+// two separately.
+type OrderResponse struct {
+	OrderID          string       `json:"order_id"`
+	OrderIndex       int64        `json:"order_index,omitempty"`
+	TxHash           string       `json:"tx_hash,omitempty"`
+	ClientOrderIndex int64        `json:"client_order_index,omitempty"`
+	Status           string       `json:"status"`
+	Message          string       `json:"message,omitempty"`
+	Request          OrderRequest `json:"request"`
+	ChildOrderIDs    []string     `json:"child_order_ids,omitempty"` // SL/TP legs
+}
+
+// ----- executors -----
+
+const (
+	ModeLive  = "live"
+	ModePaper = "paper"
+)
+
+// TradingModeFromEnv reads TRADING_MODE (live|paper, default live).
+func TradingModeFromEnv() string {
+	if strings.EqualFold(os.Getenv("TRADING_MODE"), ModePaper) {
+		return ModePaper
+	}
+	return ModeLive
+}
+
+// Executor carries out an order that has already been validated and
+// risk-checked against mkt. The live one signs and sends it to Lighter;
+// the paper one fills it against the live book on a virtual account.
+type Executor interface {
+	Mode() string
+	Execute(ctx context.Context, req OrderRequest, mkt MarketSpec) (*OrderResponse, error)
+	Cancel(ctx context.Context, orderID string) error
+}
+
+// ----- sendTx -----
+
+// PlaceOrderRequest is the sendTx body for a signed order tx.
 type PlaceOrderRequest struct {
-    // shape that Lighter / underlying CEX expects
+	TxType          uint8  `json:"tx_type"`
+	TxInfo          string `json:"tx_info"` // signed tx JSON from Signer
+	PriceProtection bool   `json:"price_protection"`
 }
 
+// PlaceOrderResponse is what sendTx returns once a tx is accepted.
 type PlaceOrderResponse struct {
-    // real exchange response fields
+	Code                     int    `json:"code"`
+	Message                  string `json:"message"`
+	TxHash                   string `json:"tx_hash"`
+	PredictedExecutionTimeMs int64  `json:"predicted_execution_time_ms"`
 }
 
+// PlaceOrder submits a signed create-order tx via /api/v1/sendTx.
 func (c *LighterClient) PlaceOrder(ctx context.Context, payload PlaceOrderRequest) (*PlaceOrderResponse, error) {
-    raw, err := c.doJSON(ctx, http.MethodPost, "/api/v1/orders", nil /* query */)
-    if err != nil {
-        return nil, err
-    }
-
-    var resp PlaceOrderResponse
-    if err := json.Unmarshal(raw, &resp); err != nil {
-        return nil, fmt.Errorf("decode place order: %w", err)
-    }
-    return &resp, nil
-}
\ No newline at end of file
+	return c.sendTx(ctx, payload)
+}
+
+// CreateGroupedOrders submits a signed grouped-orders tx (OTO/OCO/OTOCO).
+func (c *LighterClient) CreateGroupedOrders(ctx context.Context, txInfo string) (*PlaceOrderResponse, error) {
+	return c.sendTx(ctx, PlaceOrderRequest{TxType: TxTypeCreateGroupedOrders, TxInfo: txInfo})
+}
+
+// CancelOrder submits a signed cancel-order tx via /api/v1/sendTx.
+func (c *LighterClient) CancelOrder(ctx context.Context, txInfo string) (*PlaceOrderResponse, error) {
+	return c.sendTx(ctx, PlaceOrderRequest{TxType: TxTypeCancelOrder, TxInfo: txInfo})
+}
+
+// ModifyOrder submits a signed modify-order tx via /api/v1/sendTx.
+func (c *LighterClient) ModifyOrder(ctx context.Context, txInfo string) (*PlaceOrderResponse, error) {
+	return c.sendTx(ctx, PlaceOrderRequest{TxType: TxTypeModifyOrder, TxInfo: txInfo})
+}
+
+// CancelAllOrders submits a signed cancel-all tx via /api/v1/sendTx.
+func (c *LighterClient) CancelAllOrders(ctx context.Context, txInfo string) (*PlaceOrderResponse, error) {
+	return c.sendTx(ctx, PlaceOrderRequest{TxType: TxTypeCancelAllOrders, TxInfo: txInfo})
+}
+
+// sendTx posts tx_type/tx_info as a form and decodes the result. Any
+// non-200 code (HTTP or in the body) comes back as *APIError.
+func (c *LighterClient) sendTx(ctx context.Context, payload PlaceOrderRequest) (*PlaceOrderResponse, error) {
+	form := url.Values{}
+	form.Set("tx_type", strconv.Itoa(int(payload.TxType)))
+	form.Set("tx_info", payload.TxInfo)
+	form.Set("price_protection", strconv.FormatBool(payload.PriceProtection))
+
+	// a POST is never retried: a timeout may still have landed the tx
+	status, bodyBytes, err := c.roundTrip(ctx, "/api/v1/sendTx", false, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/sendTx", strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("accept", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, c.transportError("POST /api/v1/sendTx", err)
+	}
+	if err := checkResponse("POST /api/v1/sendTx", status, bodyBytes); err != nil {
+		return nil, err
+	}
+
+	var out PlaceOrderResponse
+	if err := json.Unmarshal(bodyBytes, &out); err != nil {
+		return nil, fmt.Errorf("decode send tx: %w", err)
+	}
+	return &out, nil
+}
+
+// ----- account orders -----
+
+// AccountOrder is one of our orders as the exchange reports it.
+// OrderIndex is the exchange's id for it; orders sent in one grouped tx
+// share the tx's Nonce.
+type AccountOrder struct {
+	OrderIndex       int64   `json:"order_index"`
+	ClientOrderIndex int64   `json:"client_order_index"`
+	MarketIndex      int     `json:"market_index"`
+	Nonce            int64   `json:"nonce"`
+	Type             string  `json:"type"` // limit / market / stop-loss / take-profit ...
+	Status           string  `json:"status"`
+	IsAsk            bool    `json:"is_ask"`
+	Price            Decimal `json:"price"`
+	ParentOrderIndex int64   `json:"parent_order_index"`
+	Timestamp        int64   `json:"timestamp"`
+}
+
+type accountOrdersResponse struct {
+	Code   int            `json:"code"`
+	Orders []AccountOrder `json:"orders"`
+}
+
+// how many recent inactive orders AccountOrders looks through
+const inactiveOrdersLimit = 100
+
+// AccountOrders wraps GET /api/v1/accountActiveOrders, or with active
+// false the newest page of /api/v1/accountInactiveOrders, for one
+// market. auth comes from Signer.AuthToken.
+func (c *LighterClient) AccountOrders(ctx context.Context, accountIndex int64, marketID int, auth string, active bool) ([]AccountOrder, error) {
+	path := "/api/v1/accountActiveOrders"
+	query := map[string]string{
+		"account_index": strconv.FormatInt(accountIndex, 10),
+		"market_id":     strconv.Itoa(marketID),
+		"auth":          auth,
+	}
+	if !active {
+		path = "/api/v1/accountInactiveOrders"
+		query["limit"] = strconv.Itoa(inactiveOrdersLimit)
+	}
+	raw, err := c.doJSON(ctx, http.MethodGet, path, query)
+	if err != nil {
+		return nil, err
+	}
+	var out accountOrdersResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("decode account orders: %w", err)
+	}
+	return out.Orders, nil
+}
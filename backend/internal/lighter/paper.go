@@ -0,0 +1,599 @@
+// backend/internal/lighter/paper.go
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPaperRejected is an order the paper account refuses outright,
+	// the way the exchange would.
+	ErrPaperRejected = errors.New("paper order rejected")
+
+	ErrPaperOrderNotFound = errors.New("paper order not found")
+	ErrPaperOrderClosed   = errors.New("paper order is not open")
+)
+
+const (
+	paperOrderPrefix = "paper-"
+
+	defaultPaperStartingBalance = 10000
+	defaultPaperMatchInterval   = 500 * time.Millisecond
+
+	// closed orders and fills kept in the state file
+	maxPaperClosedOrders = 500
+	maxPaperFills        = 1000
+)
+
+// IsPaperOrderID reports whether id was issued by a PaperExecutor.
+func IsPaperOrderID(id string) bool { return strings.HasPrefix(id, paperOrderPrefix) }
+
+// PaperFeed is the market data a paper account fills against: the order
+// book by market id, and the market row (fees, mark) by symbol.
+type PaperFeed interface {
+	Book(ctx context.Context, marketID int) (BookSnapshot, error)
+	Market(symbol string) (MarketRow, bool)
+}
+
+// PaperPosition is a virtual position. Size is signed: long > 0.
+type PaperPosition struct {
+	Symbol      string  `json:"symbol"`
+	MarketID    int     `json:"market_id"`
+	Size        Decimal `json:"size"`
+	AvgEntry    Decimal `json:"avg_entry_price"`
+	Leverage    Decimal `json:"leverage"`
+	RealizedPnl Decimal `json:"realized_pnl"`
+}
+
+// PaperOrder is an order on the virtual account. Market orders never
+// rest: whatever the book can't fill at the worst price is cancelled.
+type PaperOrder struct {
+	OrderID        string  `json:"order_id"`
+	MarketID       int     `json:"market_id"`
+	Symbol         string  `json:"symbol"`
+	Side           string  `json:"side"`
+	Type           string  `json:"type"`
+	Price          Decimal `json:"price"` // limit, or worst price for market
+	Size           Decimal `json:"size"`
+	Filled         Decimal `json:"filled"`
+	Status         string  `json:"status"` // open | filled | cancelled
+	ReduceOnly     bool    `json:"reduce_only"`
+	Leverage       float64 `json:"leverage"`
+	ClientID       string  `json:"client_id,omitempty"`
+	CreatedAtEpoch int64   `json:"created_at_epoch"`
+}
+
+func (o PaperOrder) remaining() Decimal { return o.Size.Sub(o.Filled) }
+
+// PaperAccount is the persisted virtual account. Balance is cash:
+// the starting balance plus realized PnL, less fees.
+type PaperAccount struct {
+	StartingBalance Decimal                  `json:"starting_balance"`
+	Balance         Decimal                  `json:"balance"`
+	RealizedPnl     Decimal                  `json:"realized_pnl"`
+	FeesPaid        Decimal                  `json:"fees_paid"`
+	Positions       map[string]PaperPosition `json:"positions"`
+	Orders          []PaperOrder             `json:"orders"` // oldest first
+	Fills           []Fill                   `json:"fills"`  // oldest first
+	Seq             int64                    `json:"seq"`
+}
+
+func newPaperAccount(balance Decimal) PaperAccount {
+	return PaperAccount{
+		StartingBalance: balance,
+		Balance:         balance,
+		Positions:       map[string]PaperPosition{},
+	}
+}
+
+// PaperStartingBalanceFromEnv reads PAPER_STARTING_BALANCE (USD,
+// default 10000).
+func PaperStartingBalanceFromEnv() Decimal {
+	v := os.Getenv("PAPER_STARTING_BALANCE")
+	if v == "" {
+		return NewDecimalFromInt(defaultPaperStartingBalance)
+	}
+	d, err := ParseDecimal(v)
+	if err != nil || !d.IsPositive() {
+		log.Printf("bad PAPER_STARTING_BALANCE %q, using %d", v, defaultPaperStartingBalance)
+		return NewDecimalFromInt(defaultPaperStartingBalance)
+	}
+	return d
+}
+
+// PaperMatchIntervalFromEnv reads PAPER_MATCH_INTERVAL, how often
+// resting paper orders are checked against the book (default 500ms).
+func PaperMatchIntervalFromEnv() time.Duration {
+	return envDuration("PAPER_MATCH_INTERVAL", defaultPaperMatchInterval)
+}
+
+// PaperExecutor fills orders against a PaperFeed instead of sending
+// them. Marketable quantity takes book liquidity level by level at the
+// taker fee; the rest of a limit order rests and fills at its own price,
+// at the maker fee, once the opposite side of the book reaches it.
+// Book liquidity isn't consumed between matches, so a resting order
+// can fill against the same level on consecutive passes.
+//
+// Fees come from MarketRow.TakerFee/MakerFee, which Lighter quotes in
+// percent ("0.0200" is 2 bps).
+type PaperExecutor struct {
+	feed PaperFeed
+	grid GridMode
+	path string // "" keeps the account in memory only
+	now  func() time.Time
+
+	mu      sync.Mutex
+	acct    PaperAccount
+	onFill  func(Fill)
+	pending []Fill // fills to hand to onFill once mu is released
+}
+
+// NewPaperExecutor starts an in-memory account with balance.
+func NewPaperExecutor(feed PaperFeed, grid GridMode, balance Decimal) *PaperExecutor {
+	return &PaperExecutor{feed: feed, grid: grid, now: time.Now, acct: newPaperAccount(balance)}
+}
+
+// OpenPaperExecutor loads the account saved at path, or starts one with
+// balance if there is none.
+func OpenPaperExecutor(path string, feed PaperFeed, grid GridMode, balance Decimal) (*PaperExecutor, error) {
+	p := NewPaperExecutor(feed, grid, balance)
+	p.path = path
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read paper account: %w", err)
+	}
+	if err := json.Unmarshal(b, &p.acct); err != nil {
+		return nil, fmt.Errorf("decode paper account: %w", err)
+	}
+	if p.acct.Positions == nil {
+		p.acct.Positions = map[string]PaperPosition{}
+	}
+	return p, nil
+}
+
+// SetClock replaces time.Now, for replaying recorded data.
+func (p *PaperExecutor) SetClock(now func() time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.now = now
+}
+
+// OnFill registers a callback for every paper fill. It runs outside the
+// executor's lock, so it may place or cancel orders.
+func (p *PaperExecutor) OnFill(fn func(Fill)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onFill = fn
+}
+
+func (p *PaperExecutor) Mode() string { return ModePaper }
+
+// Execute opens a paper order and crosses the book with it at once.
+// Stop-loss / take-profit legs aren't simulated and are refused.
+func (p *PaperExecutor) Execute(ctx context.Context, req OrderRequest, mkt MarketSpec) (*OrderResponse, error) {
+	if req.StopLoss != nil || req.TakeProfit != nil {
+		return nil, fmt.Errorf("%w: stop_loss/take_profit are not simulated", ErrPaperRejected)
+	}
+	price, base, err := mkt.ScaleOrder(req, p.grid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPaperRejected, err)
+	}
+	book, err := p.feed.Book(ctx, mkt.MarketID)
+	if err != nil {
+		return nil, fmt.Errorf("paper book for %s: %w", mkt.Symbol, err)
+	}
+	row, _ := p.feed.Market(req.Symbol)
+
+	p.mu.Lock()
+	o := PaperOrder{
+		MarketID:       mkt.MarketID,
+		Symbol:         req.Symbol,
+		Side:           req.Side,
+		Type:           req.Type,
+		Price:          NewDecimalScaled(int64(price), mkt.PriceDecimals),
+		Size:           NewDecimalScaled(base, mkt.SizeDecimals),
+		Status:         "open",
+		ReduceOnly:     req.ReduceOnly,
+		Leverage:       req.Leverage,
+		ClientID:       req.ClientID,
+		CreatedAtEpoch: p.now().Unix(),
+	}
+	if o.ReduceOnly {
+		o.Size = minDecimal(o.Size, p.reducibleLocked(o.Symbol, o.Side))
+		if !o.Size.IsPositive() {
+			p.mu.Unlock()
+			return nil, fmt.Errorf("%w: reduce-only order would not reduce the %s position", ErrPaperRejected, o.Symbol)
+		}
+	}
+	p.acct.Seq++
+	o.OrderID = fmt.Sprintf("%s%d", paperOrderPrefix, p.acct.Seq)
+
+	p.takeLocked(&o, book, row.TakerFee)
+	switch {
+	case !o.remaining().IsPositive():
+		o.Status = "filled"
+	case o.Type == "market":
+		o.Status = "cancelled"
+	}
+	p.acct.Orders = append(p.acct.Orders, o)
+	p.saveLocked()
+	p.mu.Unlock()
+	p.flush()
+
+	return &OrderResponse{
+		OrderID: o.OrderID,
+		Status:  o.Status,
+		Message: fmt.Sprintf("paper: filled %v of %v", o.Filled, o.Size),
+		Request: req,
+	}, nil
+}
+
+// Cancel cancels a resting paper order.
+func (p *PaperExecutor) Cancel(_ context.Context, orderID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.acct.Orders {
+		o := &p.acct.Orders[i]
+		if o.OrderID != orderID {
+			continue
+		}
+		if o.Status != "open" {
+			return ErrPaperOrderClosed
+		}
+		o.Status = "cancelled"
+		p.saveLocked()
+		return nil
+	}
+	return ErrPaperOrderNotFound
+}
+
+// CancelAll cancels every resting paper order, or only symbol's, and
+// returns their ids.
+func (p *PaperExecutor) CancelAll(symbol string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cancelled := []string{}
+	for i := range p.acct.Orders {
+		o := &p.acct.Orders[i]
+		if o.Status == "open" && (symbol == "" || o.Symbol == symbol) {
+			o.Status = "cancelled"
+			cancelled = append(cancelled, o.OrderID)
+		}
+	}
+	if len(cancelled) > 0 {
+		p.saveLocked()
+	}
+	return cancelled
+}
+
+// Match fills resting orders the book has moved through. Markets whose
+// book can't be read are skipped until the next pass.
+func (p *PaperExecutor) Match(ctx context.Context) {
+	p.mu.Lock()
+	ids := map[int]bool{}
+	for _, o := range p.acct.Orders {
+		if o.Status == "open" {
+			ids[o.MarketID] = true
+		}
+	}
+	p.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	books := make(map[int]BookSnapshot, len(ids))
+	for id := range ids {
+		b, err := p.feed.Book(ctx, id)
+		if err != nil {
+			continue
+		}
+		books[id] = b
+	}
+
+	p.mu.Lock()
+	changed := false
+	for i := range p.acct.Orders {
+		o := &p.acct.Orders[i]
+		book, ok := books[o.MarketID]
+		if o.Status != "open" || !ok {
+			continue
+		}
+		row, _ := p.feed.Market(o.Symbol)
+		if p.restLocked(o, book, row.MakerFee) {
+			changed = true
+		}
+	}
+	if changed {
+		p.saveLocked()
+	}
+	p.mu.Unlock()
+	p.flush()
+}
+
+// Run matches resting orders every interval until ctx ends.
+func (p *PaperExecutor) Run(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.Match(ctx)
+		}
+	}
+}
+
+// Account is a copy of the virtual account.
+func (p *PaperExecutor) Account() PaperAccount {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a := p.acct
+	a.Positions = make(map[string]PaperPosition, len(p.acct.Positions))
+	for k, v := range p.acct.Positions {
+		a.Positions[k] = v
+	}
+	a.Orders = append([]PaperOrder(nil), p.acct.Orders...)
+	a.Fills = append([]Fill(nil), p.acct.Fills...)
+	return a
+}
+
+// Reset wipes the account back to balance, or to its last starting
+// balance if balance is zero.
+func (p *PaperExecutor) Reset(balance Decimal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !balance.IsPositive() {
+		balance = p.acct.StartingBalance
+	}
+	seq := p.acct.Seq // keep ids unique across resets
+	p.acct = newPaperAccount(balance)
+	p.acct.Seq = seq
+	return p.saveErrLocked()
+}
+
+// ----- matching -----
+
+// takeLocked crosses o against the opposite side of book, best level
+// first, up to o's price, at each level's price.
+func (p *PaperExecutor) takeLocked(o *PaperOrder, book BookSnapshot, feePct Decimal) {
+	levels := book.Asks
+	if o.Side == "sell" {
+		levels = book.Bids
+	}
+	for _, l := range levels {
+		rem := o.remaining()
+		if !rem.IsPositive() {
+			return
+		}
+		px := l.Price
+		if (o.Side == "buy" && o.Price.LessThan(px)) || (o.Side == "sell" && px.LessThan(o.Price)) {
+			return
+		}
+		p.fillLocked(o, px, minDecimal(rem, l.Size), feePct)
+	}
+}
+
+// restLocked fills a resting order at its own price against whatever
+// the book now shows at or through it. It reports whether anything
+// changed.
+func (p *PaperExecutor) restLocked(o *PaperOrder, book BookSnapshot, feePct Decimal) bool {
+	levels := book.Asks
+	if o.Side == "sell" {
+		levels = book.Bids
+	}
+	var avail Decimal
+	for _, l := range levels {
+		px := l.Price
+		if (o.Side == "buy" && o.Price.LessThan(px)) || (o.Side == "sell" && px.LessThan(o.Price)) {
+			break
+		}
+		avail = avail.Add(l.Size)
+	}
+	if !avail.IsPositive() {
+		return false
+	}
+
+	qty := minDecimal(o.remaining(), avail)
+	if o.ReduceOnly {
+		qty = minDecimal(qty, p.reducibleLocked(o.Symbol, o.Side))
+		if !qty.IsPositive() {
+			o.Status = "cancelled" // nothing left to reduce
+			return true
+		}
+	}
+	p.fillLocked(o, o.Price, qty, feePct)
+	if !o.remaining().IsPositive() {
+		o.Status = "filled"
+	}
+	return true
+}
+
+// fillLocked books one fill of o: position, cash, fees and the fill log.
+func (p *PaperExecutor) fillLocked(o *PaperOrder, px, qty, feePct Decimal) {
+	o.Filled = o.Filled.Add(qty)
+	fee := px.Mul(qty).Mul(feePct).Shift(-2)
+
+	p.applyLocked(o, px, qty)
+	p.acct.Balance = p.acct.Balance.Sub(fee)
+	p.acct.FeesPaid = p.acct.FeesPaid.Add(fee)
+
+	f := Fill{
+		OrderID:   o.OrderID,
+		TradeID:   fmt.Sprintf("%s-%d", o.OrderID, len(p.acct.Fills)+1),
+		Symbol:    o.Symbol,
+		Side:      o.Side,
+		Price:     px,
+		Size:      qty,
+		FeeUsd:    fee,
+		TimeEpoch: p.now().Unix(),
+	}
+	p.acct.Fills = append(p.acct.Fills, f)
+	p.pending = append(p.pending, f)
+}
+
+// applyLocked moves the position by a fill: adding averages the entry,
+// reducing realizes PnL against it, and crossing zero opens the
+// remainder at px.
+func (p *PaperExecutor) applyLocked(o *PaperOrder, px, qty Decimal) {
+	pos, ok := p.acct.Positions[o.Symbol]
+	if !ok {
+		pos = PaperPosition{Symbol: o.Symbol, MarketID: o.MarketID}
+	}
+	delta := qty
+	if o.Side == "sell" {
+		delta = qty.Neg()
+	}
+
+	if pos.Size.IsZero() || pos.Size.Sign() == delta.Sign() {
+		size := pos.Size.Add(delta)
+		// size is non-zero: qty adds to it
+		pos.AvgEntry, _ = pos.AvgEntry.Mul(pos.Size.Abs()).Add(px.Mul(qty)).Div(size.Abs())
+		pos.Size = size
+	} else {
+		closing := minDecimal(qty, pos.Size.Abs())
+		pnl := px.Sub(pos.AvgEntry).Mul(closing)
+		if pos.Size.Sign() < 0 {
+			pnl = pnl.Neg()
+		}
+		pos.RealizedPnl = pos.RealizedPnl.Add(pnl)
+		p.acct.RealizedPnl = p.acct.RealizedPnl.Add(pnl)
+		p.acct.Balance = p.acct.Balance.Add(pnl)
+
+		pos.Size = pos.Size.Add(delta)
+		switch {
+		case pos.Size.IsZero():
+			pos.AvgEntry = Decimal{}
+		case pos.Size.Sign() == delta.Sign():
+			pos.AvgEntry = px
+		}
+	}
+	if o.Leverage > 0 {
+		pos.Leverage = NewDecimalFromFloat(o.Leverage)
+	}
+
+	if pos.Size.IsZero() {
+		delete(p.acct.Positions, o.Symbol)
+		return
+	}
+	p.acct.Positions[o.Symbol] = pos
+}
+
+// reducibleLocked is how much a side-order can take off the position.
+func (p *PaperExecutor) reducibleLocked(symbol, side string) Decimal {
+	pos := p.acct.Positions[symbol]
+	if (side == "buy" && pos.Size.Sign() < 0) || (side == "sell" && pos.Size.Sign() > 0) {
+		return pos.Size.Abs()
+	}
+	return Decimal{}
+}
+
+// flush hands pending fills to the callback outside the lock.
+func (p *PaperExecutor) flush() {
+	p.mu.Lock()
+	fills, fn := p.pending, p.onFill
+	p.pending = nil
+	p.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	for _, f := range fills {
+		fn(f)
+	}
+}
+
+func minDecimal(a, b Decimal) Decimal {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+// ----- persistence -----
+
+// trimLocked drops the oldest closed orders and fills past their caps.
+func (p *PaperExecutor) trimLocked() {
+	if n := len(p.acct.Fills) - maxPaperFills; n > 0 {
+		p.acct.Fills = append([]Fill(nil), p.acct.Fills[n:]...)
+	}
+	closed := 0
+	for _, o := range p.acct.Orders {
+		if o.Status != "open" {
+			closed++
+		}
+	}
+	if closed <= maxPaperClosedOrders {
+		return
+	}
+	drop := closed - maxPaperClosedOrders
+	kept := make([]PaperOrder, 0, len(p.acct.Orders)-drop)
+	for _, o := range p.acct.Orders {
+		if o.Status != "open" && drop > 0 {
+			drop--
+			continue
+		}
+		kept = append(kept, o)
+	}
+	p.acct.Orders = kept
+}
+
+// saveLocked persists the account; a failed write is logged, not
+// returned, since the fill has already happened in memory.
+func (p *PaperExecutor) saveLocked() {
+	if err := p.saveErrLocked(); err != nil {
+		log.Printf("paper: %v", err)
+	}
+}
+
+func (p *PaperExecutor) saveErrLocked() error {
+	p.trimLocked()
+	if p.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return fmt.Errorf("paper account mkdir: %w", err)
+	}
+	b, err := json.MarshalIndent(p.acct, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("write paper account: %w", err)
+	}
+	return os.Rename(tmp, p.path)
+}
+
+// OpenOrders returns the resting orders, oldest first.
+func (a PaperAccount) OpenOrders() []PaperOrder {
+	var out []PaperOrder
+	for _, o := range a.Orders {
+		if o.Status == "open" {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// SortedPositions returns the positions ordered by symbol.
+func (a PaperAccount) SortedPositions() []PaperPosition {
+	out := make([]PaperPosition, 0, len(a.Positions))
+	for _, pos := range a.Positions {
+		out = append(out, pos)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
@@ -0,0 +1,161 @@
+// backend/internal/lighter/paper_test.go
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fakePaperFeed serves one fixed book and fee schedule.
+type fakePaperFeed struct {
+	book BookSnapshot
+	row  MarketRow
+}
+
+func (f *fakePaperFeed) Book(context.Context, int) (BookSnapshot, error) { return f.book, nil }
+func (f *fakePaperFeed) Market(string) (MarketRow, bool)                 { return f.row, true }
+
+func paperBook(bids, asks [][2]string) BookSnapshot {
+	level := func(l [2]string) BookLevel { return BookLevel{Price: MustDecimal(l[0]), Size: MustDecimal(l[1])} }
+	var b BookSnapshot
+	for _, l := range bids {
+		b.Bids = append(b.Bids, level(l))
+	}
+	for _, l := range asks {
+		b.Asks = append(b.Asks, level(l))
+	}
+	return b
+}
+
+func newTestPaper() (*PaperExecutor, *fakePaperFeed, MarketSpec) {
+	feed := &fakePaperFeed{
+		book: paperBook([][2]string{{"99", "1"}, {"98", "2"}}, [][2]string{{"100", "1"}, {"101", "2"}}),
+		row:  MarketRow{Symbol: "ETH", TakerFee: MustDecimal("0.1"), MakerFee: MustDecimal("0.02")},
+	}
+	mkt := MarketSpec{Symbol: "ETH", SizeDecimals: 2, PriceDecimals: 2, LastTradePrice: MustDecimal("100")}
+	return NewPaperExecutor(feed, GridReject, NewDecimalFromInt(10000)), feed, mkt
+}
+
+func paperReq(side, typ, px, size string) OrderRequest {
+	sz := MustDecimal(size)
+	req := OrderRequest{Symbol: "ETH", Side: side, Type: typ, SizeContracts: &sz}
+	if px != "" {
+		p := MustDecimal(px)
+		req.Price = &p
+	}
+	return req
+}
+
+func TestPaperExecute(t *testing.T) {
+	reduce := func(r OrderRequest) OrderRequest { r.ReduceOnly = true; return r }
+	sl := MustDecimal("90")
+	cases := []struct {
+		name     string
+		before   []OrderRequest
+		req      OrderRequest
+		rejected bool
+		status   string
+		filled   string
+		position string
+		balance  string
+	}{
+		{"market walks the book", nil, paperReq("buy", "market", "", "2"), false, "filled", "2", "2", "9999.799"},
+		{"market remainder is cancelled", nil, paperReq("buy", "market", "", "5"), false, "cancelled", "3", "3", "9999.698"},
+		{"limit stops at its price and rests", nil, paperReq("buy", "limit", "100", "2"), false, "open", "1", "1", "9999.9"},
+		{"limit behind the book rests", nil, paperReq("sell", "limit", "99.5", "1"), false, "open", "0", "0", "10000"},
+		{"sell takes bids", nil, paperReq("sell", "market", "", "1"), false, "filled", "1", "-1", "9999.901"},
+		{"reduce-only is clipped to the position",
+			[]OrderRequest{paperReq("buy", "market", "", "1")},
+			reduce(paperReq("sell", "market", "", "3")), false, "filled", "1", "0", "9998.801"},
+		{"reduce-only with nothing to reduce", nil, reduce(paperReq("sell", "market", "", "1")), true, "", "", "", ""},
+		{"brackets aren't simulated", nil, func() OrderRequest { r := paperReq("buy", "market", "", "1"); r.StopLoss = &sl; return r }(), true, "", "", "", ""},
+		{"off-grid price", nil, paperReq("buy", "limit", "100.001", "1"), true, "", "", "", ""},
+	}
+	for _, c := range cases {
+		p, _, mkt := newTestPaper()
+		for _, r := range c.before {
+			if _, err := p.Execute(context.Background(), r, mkt); err != nil {
+				t.Fatalf("%s: setup: %v", c.name, err)
+			}
+		}
+		resp, err := p.Execute(context.Background(), c.req, mkt)
+		if c.rejected {
+			if !errors.Is(err, ErrPaperRejected) {
+				t.Errorf("%s: got %+v, %v, want a rejection", c.name, resp, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		acct := p.Account()
+		o := acct.Orders[len(acct.Orders)-1]
+		pos := acct.Positions["ETH"]
+		if resp.Status != c.status || o.Filled.String() != c.filled || pos.Size.String() != c.position || acct.Balance.String() != c.balance {
+			t.Errorf("%s: status %s, filled %s, position %s, balance %s; want %s, %s, %s, %s",
+				c.name, resp.Status, o.Filled, pos.Size, acct.Balance, c.status, c.filled, c.position, c.balance)
+		}
+	}
+}
+
+func TestPaperMatchResting(t *testing.T) {
+	p, feed, mkt := newTestPaper()
+	var fills []Fill
+	p.OnFill(func(f Fill) { fills = append(fills, f) })
+
+	// long 1 at 100, then a resting buy and a resting reduce-only sell,
+	// which is clipped to the 1 held when it's placed
+	if _, err := p.Execute(context.Background(), paperReq("buy", "market", "", "1"), mkt); err != nil {
+		t.Fatal(err)
+	}
+	buy, err := p.Execute(context.Background(), paperReq("buy", "limit", "99.5", "1"), mkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exit := paperReq("sell", "limit", "102", "2")
+	exit.ReduceOnly = true
+	sell, err := p.Execute(context.Background(), exit, mkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name     string
+		book     BookSnapshot
+		buy      string // status/filled of the resting buy
+		sell     string
+		position string
+		fills    int
+	}{
+		{"book away: nothing", paperBook([][2]string{{"99", "1"}}, [][2]string{{"100", "1"}}), "open 0", "open 0", "1", 1},
+		{"asks reach the buy: partial at its own price", paperBook(nil, [][2]string{{"99", "0.4"}, {"99.6", "5"}}), "open 0.4", "open 0", "1.4", 2},
+		{"the rest of the buy", paperBook(nil, [][2]string{{"99.5", "5"}}), "filled 1", "open 0", "2", 3},
+		{"bids through the sell", paperBook([][2]string{{"103", "10"}}, nil), "filled 1", "filled 1", "1", 4},
+	}
+	for _, s := range steps {
+		feed.book = s.book
+		p.Match(context.Background())
+		acct := p.Account()
+		got := map[string]string{}
+		for _, o := range acct.Orders {
+			got[o.OrderID] = o.Status + " " + o.Filled.String()
+		}
+		pos := acct.Positions["ETH"]
+		if got[buy.OrderID] != s.buy || got[sell.OrderID] != s.sell || pos.Size.String() != s.position || len(fills) != s.fills {
+			t.Errorf("%s: buy %q, sell %q, position %s, %d fills; want %q, %q, %s, %d",
+				s.name, got[buy.OrderID], got[sell.OrderID], pos.Size, len(fills), s.buy, s.sell, s.position, s.fills)
+		}
+	}
+
+	// resting fills are at the order's price with the maker fee
+	f := fills[1]
+	if !f.Price.Equal(MustDecimal("99.5")) || !f.FeeUsd.Equal(MustDecimal("0.00796")) {
+		t.Errorf("resting fill %s at %s, fee %s", f.Size, f.Price, f.FeeUsd)
+	}
+	// 1 sold at 102 against the 99.75 average of 100 and 99.5
+	if acct := p.Account(); !acct.RealizedPnl.Equal(MustDecimal("2.25")) {
+		t.Errorf("realized %s, want 2.25", acct.RealizedPnl)
+	}
+}
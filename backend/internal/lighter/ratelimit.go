@@ -0,0 +1,104 @@
+// backend/internal/lighter/ratelimit.go
+package internal
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Request weights from Lighter's rate-limit docs. Limits are counted in
+// weight per minute; anything not listed costs the default.
+var endpointWeights = map[string]float64{
+	"/api/v1/sendTx":       6,
+	"/api/v1/sendTxBatch":  6,
+	"/api/v1/nextNonce":    6,
+	"/api/v1/candlesticks": 50,
+	"/api/v1/fundings":     50,
+}
+
+const (
+	defaultEndpointWeight = 300
+	// weight per minute for a premium account; standard accounts get far
+	// less, so set LIGHTER_RATE_LIMIT_PER_MIN to match yours
+	defaultWeightPerMinute = 24000
+)
+
+func endpointWeight(path string) float64 {
+	if w, ok := endpointWeights[path]; ok {
+		return w
+	}
+	return defaultEndpointWeight
+}
+
+// TokenBucket refills continuously at perMinute/60 per second up to a
+// burst of perMinute.
+type TokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // per second
+	last     time.Time
+}
+
+func NewTokenBucket(perMinute float64) *TokenBucket {
+	return &TokenBucket{
+		capacity: perMinute,
+		tokens:   perMinute,
+		rate:     perMinute / 60,
+		last:     time.Now(),
+	}
+}
+
+// newTokenBucketFromEnv reads LIGHTER_RATE_LIMIT_PER_MIN.
+func newTokenBucketFromEnv() *TokenBucket {
+	perMin := float64(defaultWeightPerMinute)
+	if v := os.Getenv("LIGHTER_RATE_LIMIT_PER_MIN"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			perMin = f
+		}
+	}
+	return NewTokenBucket(perMin)
+}
+
+// Wait blocks until n tokens are available or ctx is done.
+func (b *TokenBucket) Wait(ctx context.Context, n float64) error {
+	if n > b.capacity {
+		n = b.capacity
+	}
+	for {
+		b.mu.Lock()
+		b.refillLocked(time.Now())
+		if b.tokens >= n {
+			b.tokens -= n
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Available is the current token count, for /api/status.
+func (b *TokenBucket) Available() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked(time.Now())
+	return b.tokens
+}
+
+func (b *TokenBucket) refillLocked(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
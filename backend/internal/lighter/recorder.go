@@ -0,0 +1,301 @@
+// backend/internal/lighter/recorder.go
+package internal
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record kinds, one per line of a recording.
+const (
+	RecordSpecs   = "specs"
+	RecordMarkets = "markets"
+	RecordTrade   = "trade"
+	RecordFunding = "funding"
+)
+
+const (
+	recordFileLayout   = "20060102-15"
+	recordFileExt      = ".jsonl.gz"
+	recorderFlushEvery = time.Second
+	recorderSpecsEvery = time.Hour
+)
+
+// Record is one line of a recording. At is unix ms when it was written.
+type Record struct {
+	Kind    string        `json:"kind"`
+	At      int64         `json:"at"`
+	Specs   []MarketSpec  `json:"specs,omitempty"`
+	Markets []MarketRow   `json:"markets,omitempty"`
+	Stale   bool          `json:"stale,omitempty"`
+	Trade   *StreamTrade  `json:"trade,omitempty"`
+	Funding []FundingRate `json:"funding,omitempty"`
+}
+
+func (r Record) Time() time.Time { return time.UnixMilli(r.At) }
+
+// Recorder writes the merged market stream, public trades and funding
+// to gzip'd JSON lines under dir, one file per UTC hour
+// (20060102-15.jsonl.gz). Every file starts with the market specs so it
+// replays on its own, and funding is only written when it changes.
+// Files are flushed every second; a crash loses at most that, and
+// ReadRecordings tolerates the truncated tail.
+type Recorder struct {
+	dir   string
+	specs func(ctx context.Context) ([]MarketSpec, error)
+
+	mu          sync.Mutex
+	hour        string
+	f           *os.File
+	gz          *gzip.Writer
+	enc         *json.Encoder
+	lastSpecs   []MarketSpec
+	specsAt     time.Time
+	lastFunding []FundingRate
+	onSpecs     func([]MarketSpec)
+}
+
+// OpenRecorder records into dir; specs supplies the market grid written
+// at the head of each file.
+func OpenRecorder(dir string, specs func(ctx context.Context) ([]MarketSpec, error)) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("recorder mkdir: %w", err)
+	}
+	return &Recorder{dir: dir, specs: specs}, nil
+}
+
+// OnSpecs registers a callback for every refresh of the market specs,
+// e.g. to subscribe the trade stream for each recorded market. Set it
+// before Run.
+func (r *Recorder) OnSpecs(fn func([]MarketSpec)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onSpecs = fn
+}
+
+// Run records every hub snapshot until ctx is done, then closes the
+// current file.
+func (r *Recorder) Run(ctx context.Context, hub *MarketHub) {
+	sub := hub.Subscribe()
+	defer func() { hub.Unsubscribe(sub) }()
+	defer r.Close()
+
+	flush := time.NewTicker(recorderFlushEvery)
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snap, ok := <-sub.C:
+			if !ok {
+				sub = hub.Subscribe()
+				continue
+			}
+			r.refreshSpecs(ctx)
+			r.RecordMarkets(snap)
+		case <-flush.C:
+			r.mu.Lock()
+			if r.gz != nil {
+				if err := r.gz.Flush(); err != nil {
+					log.Printf("recorder: flush: %v", err)
+				}
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// refreshSpecs re-reads the market grid at most hourly; on failure the
+// last one keeps being written.
+func (r *Recorder) refreshSpecs(ctx context.Context) {
+	r.mu.Lock()
+	due := r.lastSpecs == nil || time.Since(r.specsAt) >= recorderSpecsEvery
+	r.mu.Unlock()
+	if !due || r.specs == nil {
+		return
+	}
+	specs, err := r.specs(ctx)
+	if err != nil {
+		log.Printf("recorder: market specs: %v", err)
+		return
+	}
+	r.mu.Lock()
+	r.lastSpecs, r.specsAt = specs, time.Now()
+	open := r.gz != nil // a new file gets them from rotateLocked
+	onSpecs := r.onSpecs
+	r.mu.Unlock()
+	if open {
+		r.write(Record{Kind: RecordSpecs, Specs: specs})
+	}
+	if onSpecs != nil {
+		onSpecs(specs)
+	}
+}
+
+// RecordMarkets writes a hub snapshot, and its funding if that changed.
+func (r *Recorder) RecordMarkets(snap MarketSnapshot) {
+	r.write(Record{Kind: RecordMarkets, Markets: snap.Markets, Stale: snap.Stale})
+
+	if len(snap.Funding) == 0 {
+		return
+	}
+	r.mu.Lock()
+	changed := !reflect.DeepEqual(snap.Funding, r.lastFunding)
+	if changed {
+		r.lastFunding = snap.Funding
+	}
+	r.mu.Unlock()
+	if changed {
+		r.write(Record{Kind: RecordFunding, Funding: snap.Funding})
+	}
+}
+
+// RecordTrade writes one public trade. It's cheap enough to call from
+// the stream's read loop.
+func (r *Recorder) RecordTrade(t StreamTrade) {
+	r.write(Record{Kind: RecordTrade, Trade: &t})
+}
+
+func (r *Recorder) write(rec Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	rec.At = now.UnixMilli()
+	if err := r.rotateLocked(now); err != nil {
+		log.Printf("recorder: %v", err)
+		return
+	}
+	if err := r.enc.Encode(rec); err != nil {
+		log.Printf("recorder: write %s: %v", rec.Kind, err)
+	}
+}
+
+// rotateLocked moves to the file for now's hour. A new file opens with
+// the specs so a replay can start there. Reopening an hour appends a
+// second gzip member, which readers handle.
+func (r *Recorder) rotateLocked(now time.Time) error {
+	hour := now.Format(recordFileLayout)
+	if r.gz != nil && hour == r.hour {
+		return nil
+	}
+	r.closeLocked()
+
+	path := filepath.Join(r.dir, hour+recordFileExt)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open recording: %w", err)
+	}
+	r.f, r.gz, r.hour = f, gzip.NewWriter(f), hour
+	r.enc = json.NewEncoder(r.gz)
+	if r.lastSpecs != nil {
+		if err := r.enc.Encode(Record{Kind: RecordSpecs, At: now.UnixMilli(), Specs: r.lastSpecs}); err != nil {
+			return fmt.Errorf("write specs: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) closeLocked() {
+	if r.gz == nil {
+		return
+	}
+	if err := r.gz.Close(); err != nil {
+		log.Printf("recorder: close %s: %v", r.hour, err)
+	}
+	if err := r.f.Close(); err != nil {
+		log.Printf("recorder: close %s: %v", r.hour, err)
+	}
+	r.f, r.gz, r.enc = nil, nil, nil
+}
+
+// Close finishes the current file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closeLocked()
+	return nil
+}
+
+// ReadRecordings calls fn with every record in dir between from and to
+// (zero means unbounded), oldest file first; specs and funding from
+// just before from come through too. A truncated file, from a
+// crash mid-write, is read up to the damage. An error from fn stops the
+// read and is returned.
+func ReadRecordings(dir string, from, to time.Time, fn func(Record) error) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+recordFileExt))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no recordings in %s", dir)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		hour, err := time.Parse(recordFileLayout, strings.TrimSuffix(filepath.Base(path), recordFileExt))
+		if err != nil {
+			continue // not one of ours
+		}
+		if (!from.IsZero() && !hour.Add(time.Hour).After(from)) || (!to.IsZero() && hour.After(to)) {
+			continue
+		}
+		if err := readRecordingFile(path, from, to, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRecordingFile(path string, from, to time.Time, fn func(Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		log.Printf("recording %s: %v, skipped", filepath.Base(path), err)
+		return nil
+	}
+	dec := json.NewDecoder(gz)
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, gzip.ErrChecksum) {
+			log.Printf("recording %s is truncated, read up to the damage", filepath.Base(path))
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("recording %s: %w", filepath.Base(path), err)
+		}
+		t := rec.Time()
+		if !to.IsZero() && t.After(to) {
+			return nil
+		}
+		// specs and funding before from are still the state at from
+		if !from.IsZero() && t.Before(from) && rec.Kind != RecordSpecs && rec.Kind != RecordFunding {
+			continue
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// backend/internal/lighter/risk.go
+package internal
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+)
+
+// ----- pre-trade risk -----
+
+// RiskContext is everything a check may look at for one order. The
+// caller fills it from the market snapshot, account and order journal.
+type RiskContext struct {
+	Order OrderRequest
+
+	MarkPrice   Decimal // from the merged market snapshot
+	NotionalUsd Decimal // this order's USD value at its limit (or mark) price
+
+	// current exposure on Order.Symbol: |position| plus working orders
+	SymbolExposureUsd Decimal
+	OpenOrders        int
+
+	// MarginKnown is false when the account couldn't be fetched; checks
+	// that need margin fail closed in that case.
+	MarginKnown        bool
+	MarginAvailableUsd Decimal
+}
+
+// RiskCheck is one rule in the chain. Check returns a reason when the
+// order must be refused.
+type RiskCheck interface {
+	Name() string
+	Check(rc *RiskContext) error
+}
+
+// RiskRejection names the rule that stopped an order.
+type RiskRejection struct {
+	Rule   string
+	Reason string
+}
+
+func (e *RiskRejection) Error() string {
+	return fmt.Sprintf("risk check %s failed: %s", e.Rule, e.Reason)
+}
+
+// RiskEngine runs its checks in order and stops at the first failure.
+type RiskEngine struct {
+	checks []RiskCheck
+}
+
+func NewRiskEngine(checks ...RiskCheck) *RiskEngine {
+	return &RiskEngine{checks: checks}
+}
+
+// Add appends a check to the end of the chain.
+func (e *RiskEngine) Add(c RiskCheck) {
+	e.checks = append(e.checks, c)
+}
+
+// Check returns a *RiskRejection for the first failing rule, or nil.
+func (e *RiskEngine) Check(rc *RiskContext) error {
+	for _, c := range e.checks {
+		if err := c.Check(rc); err != nil {
+			return &RiskRejection{Rule: c.Name(), Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// ----- built-in checks -----
+
+// MaxOrderNotional caps the USD value of a single order.
+type MaxOrderNotional struct{ LimitUsd float64 }
+
+func (c MaxOrderNotional) Name() string { return "max_order_notional" }
+
+func (c MaxOrderNotional) Check(rc *RiskContext) error {
+	if limit := NewDecimalFromFloat(c.LimitUsd); limit.LessThan(rc.NotionalUsd) {
+		return fmt.Errorf("order notional $%s exceeds $%.2f", rc.NotionalUsd.StringFixed(2), c.LimitUsd)
+	}
+	return nil
+}
+
+// MaxSymbolNotional caps position plus working orders on one symbol,
+// counting this order. Reduce-only orders can't add exposure.
+type MaxSymbolNotional struct{ LimitUsd float64 }
+
+func (c MaxSymbolNotional) Name() string { return "max_symbol_notional" }
+
+func (c MaxSymbolNotional) Check(rc *RiskContext) error {
+	if rc.Order.ReduceOnly {
+		return nil
+	}
+	total := rc.SymbolExposureUsd.Add(rc.NotionalUsd)
+	if NewDecimalFromFloat(c.LimitUsd).LessThan(total) {
+		return fmt.Errorf("%s exposure would be $%s, limit $%.2f", rc.Order.Symbol, total.StringFixed(2), c.LimitUsd)
+	}
+	return nil
+}
+
+// MaxLeverage caps the leverage requested on the order.
+type MaxLeverage struct{ Limit float64 }
+
+func (c MaxLeverage) Name() string { return "max_leverage" }
+
+func (c MaxLeverage) Check(rc *RiskContext) error {
+	if rc.Order.Leverage > c.Limit {
+		return fmt.Errorf("leverage %.1fx exceeds %.1fx", rc.Order.Leverage, c.Limit)
+	}
+	return nil
+}
+
+// MaxOpenOrders caps how many orders may be working at once.
+type MaxOpenOrders struct{ Limit int }
+
+func (c MaxOpenOrders) Name() string { return "max_open_orders" }
+
+func (c MaxOpenOrders) Check(rc *RiskContext) error {
+	if rc.OpenOrders >= c.Limit {
+		return fmt.Errorf("%d orders already open, limit %d", rc.OpenOrders, c.Limit)
+	}
+	return nil
+}
+
+// PriceBand rejects limit prices too far from mark, which catches
+// fat-fingered decimals.
+type PriceBand struct{ MaxDeviationPct float64 }
+
+func (c PriceBand) Name() string { return "price_band" }
+
+func (c PriceBand) Check(rc *RiskContext) error {
+	if !rc.MarkPrice.IsPositive() {
+		return fmt.Errorf("no mark price for %s", rc.Order.Symbol)
+	}
+	if rc.Order.Price == nil {
+		return nil
+	}
+	dev, _ := rc.Order.Price.Sub(rc.MarkPrice).Abs().Shift(2).Div(rc.MarkPrice)
+	if NewDecimalFromFloat(c.MaxDeviationPct).LessThan(dev) {
+		return fmt.Errorf("price %v is %s%% from mark %v, band %.2f%%", rc.Order.Price, dev.StringFixed(2), rc.MarkPrice, c.MaxDeviationPct)
+	}
+	return nil
+}
+
+// MarginAvailable requires enough free margin for notional / leverage.
+type MarginAvailable struct{}
+
+func (c MarginAvailable) Name() string { return "margin_available" }
+
+func (c MarginAvailable) Check(rc *RiskContext) error {
+	if rc.Order.ReduceOnly {
+		return nil
+	}
+	if !rc.MarginKnown {
+		return fmt.Errorf("account margin unavailable")
+	}
+	lev := NewDecimalFromFloat(math.Max(rc.Order.Leverage, 1))
+	need, _ := rc.NotionalUsd.Div(lev) // lev is at least 1
+	if rc.MarginAvailableUsd.LessThan(need) {
+		return fmt.Errorf("needs $%s margin, $%s available", need.StringFixed(2), rc.MarginAvailableUsd.StringFixed(2))
+	}
+	return nil
+}
+
+// ----- config -----
+
+// RiskConfig holds the limits for the built-in checks. A zero limit
+// turns its check off.
+type RiskConfig struct {
+	MaxOrderNotionalUsd  float64
+	MaxSymbolNotionalUsd float64
+	MaxLeverage          float64
+	MaxOpenOrders        int
+	PriceBandPct         float64
+	RequireMargin        bool
+}
+
+func DefaultRiskConfig() RiskConfig {
+	return RiskConfig{
+		MaxOrderNotionalUsd:  50_000,
+		MaxSymbolNotionalUsd: 250_000,
+		MaxLeverage:          20,
+		MaxOpenOrders:        50,
+		PriceBandPct:         5,
+		RequireMargin:        true,
+	}
+}
+
+// RiskConfigFromEnv overrides the defaults with RISK_* env vars.
+func RiskConfigFromEnv() RiskConfig {
+	cfg := DefaultRiskConfig()
+	envFloat("RISK_MAX_ORDER_NOTIONAL_USD", &cfg.MaxOrderNotionalUsd)
+	envFloat("RISK_MAX_SYMBOL_NOTIONAL_USD", &cfg.MaxSymbolNotionalUsd)
+	envFloat("RISK_MAX_LEVERAGE", &cfg.MaxLeverage)
+	envFloat("RISK_PRICE_BAND_PCT", &cfg.PriceBandPct)
+	if v := os.Getenv("RISK_MAX_OPEN_ORDERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.MaxOpenOrders = n
+		} else {
+			log.Printf("bad RISK_MAX_OPEN_ORDERS %q: %v", v, err)
+		}
+	}
+	if v := os.Getenv("RISK_REQUIRE_MARGIN"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.RequireMargin = b
+		} else {
+			log.Printf("bad RISK_REQUIRE_MARGIN %q: %v", v, err)
+		}
+	}
+	return cfg
+}
+
+func envFloat(name string, dst *float64) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("bad %s %q: %v", name, v, err)
+		return
+	}
+	*dst = f
+}
+
+// NewRiskEngineFromConfig builds the standard chain: cheap shape checks
+// first, margin last.
+func NewRiskEngineFromConfig(cfg RiskConfig) *RiskEngine {
+	e := NewRiskEngine()
+	if cfg.MaxLeverage > 0 {
+		e.Add(MaxLeverage{Limit: cfg.MaxLeverage})
+	}
+	if cfg.MaxOrderNotionalUsd > 0 {
+		e.Add(MaxOrderNotional{LimitUsd: cfg.MaxOrderNotionalUsd})
+	}
+	if cfg.PriceBandPct > 0 {
+		e.Add(PriceBand{MaxDeviationPct: cfg.PriceBandPct})
+	}
+	if cfg.MaxOpenOrders > 0 {
+		e.Add(MaxOpenOrders{Limit: cfg.MaxOpenOrders})
+	}
+	if cfg.MaxSymbolNotionalUsd > 0 {
+		e.Add(MaxSymbolNotional{LimitUsd: cfg.MaxSymbolNotionalUsd})
+	}
+	if cfg.RequireMargin {
+		e.Add(MarginAvailable{})
+	}
+	return e
+}
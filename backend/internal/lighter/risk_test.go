@@ -0,0 +1,94 @@
+// backend/internal/lighter/risk_test.go
+package internal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRiskEngineDefaultChain(t *testing.T) {
+	d := MustDecimal
+	px := func(s string) *Decimal { v := d(s); return &v }
+	base := func() RiskContext {
+		return RiskContext{
+			Order:              OrderRequest{Symbol: "ETH", Side: "buy", Type: "limit", Price: px("3000"), Leverage: 5},
+			MarkPrice:          d("3000"),
+			NotionalUsd:        d("10000"),
+			SymbolExposureUsd:  d("0"),
+			OpenOrders:         0,
+			MarginKnown:        true,
+			MarginAvailableUsd: d("5000"),
+		}
+	}
+	cases := []struct {
+		name string
+		edit func(rc *RiskContext)
+		rule string // "" means the order passes
+	}{
+		{"passes", nil, ""},
+		{"leverage over", func(rc *RiskContext) { rc.Order.Leverage = 25 }, "max_leverage"},
+		{"leverage at the limit", func(rc *RiskContext) { rc.Order.Leverage = 20; rc.MarginAvailableUsd = d("500") }, ""},
+		{"order notional over", func(rc *RiskContext) { rc.NotionalUsd = d("50000.01") }, "max_order_notional"},
+		{"order notional at the limit", func(rc *RiskContext) { rc.NotionalUsd = d("50000"); rc.MarginAvailableUsd = d("10000") }, ""},
+		{"price above band", func(rc *RiskContext) { rc.Order.Price = px("3150.01") }, "price_band"},
+		{"price below band", func(rc *RiskContext) { rc.Order.Price = px("2849.99") }, "price_band"},
+		{"price at band edge", func(rc *RiskContext) { rc.Order.Price = px("3150") }, ""},
+		{"no mark price", func(rc *RiskContext) { rc.MarkPrice = Decimal{} }, "price_band"},
+		{"market order skips the band", func(rc *RiskContext) { rc.Order.Type = "market"; rc.Order.Price = nil }, ""},
+		{"too many open orders", func(rc *RiskContext) { rc.OpenOrders = 50 }, "max_open_orders"},
+		{"symbol exposure over", func(rc *RiskContext) { rc.SymbolExposureUsd = d("240000.01") }, "max_symbol_notional"},
+		{"reduce-only ignores exposure", func(rc *RiskContext) { rc.SymbolExposureUsd = d("300000"); rc.Order.ReduceOnly = true }, ""},
+		{"margin short", func(rc *RiskContext) { rc.MarginAvailableUsd = d("1999.99") }, "margin_available"},
+		{"margin unknown", func(rc *RiskContext) { rc.MarginKnown = false }, "margin_available"},
+		{"no leverage needs full margin", func(rc *RiskContext) { rc.Order.Leverage = 0 }, "margin_available"},
+		{"reduce-only needs no margin", func(rc *RiskContext) { rc.MarginKnown = false; rc.Order.ReduceOnly = true }, ""},
+		{"first failure wins", func(rc *RiskContext) { rc.Order.Leverage = 25; rc.MarginKnown = false }, "max_leverage"},
+	}
+	engine := NewRiskEngineFromConfig(DefaultRiskConfig())
+	for _, c := range cases {
+		rc := base()
+		if c.edit != nil {
+			c.edit(&rc)
+		}
+		err := engine.Check(&rc)
+		if c.rule == "" {
+			if err != nil {
+				t.Errorf("%s: rejected: %v", c.name, err)
+			}
+			continue
+		}
+		var rej *RiskRejection
+		if !errors.As(err, &rej) || rej.Rule != c.rule {
+			t.Errorf("%s: got %v, want rule %s", c.name, err, c.rule)
+		}
+	}
+}
+
+func TestNewRiskEngineFromConfig(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  RiskConfig
+		want []string
+	}{
+		{"defaults", DefaultRiskConfig(), []string{"max_leverage", "max_order_notional", "price_band", "max_open_orders", "max_symbol_notional", "margin_available"}},
+		{"zero limits are off", RiskConfig{MaxOpenOrders: 3}, []string{"max_open_orders"}},
+		{"nothing", RiskConfig{}, nil},
+	}
+	for _, c := range cases {
+		e := NewRiskEngineFromConfig(c.cfg)
+		var got []string
+		for _, ch := range e.checks {
+			got = append(got, ch.Name())
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+}
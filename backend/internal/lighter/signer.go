@@ -1 +1,780 @@
-package internal
\ No newline at end of file
+// backend/internal/lighter/signer.go
+package internal
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	curve "github.com/elliottech/poseidon_crypto/curve/ecgfp5"
+	g "github.com/elliottech/poseidon_crypto/field/goldilocks"
+	gFp5 "github.com/elliottech/poseidon_crypto/field/goldilocks_quintic_extension"
+	p2 "github.com/elliottech/poseidon_crypto/hash/poseidon2_goldilocks"
+	schnorr "github.com/elliottech/poseidon_crypto/signature/schnorr"
+)
+
+// ----- tx enums (values match Lighter's sendTx API) -----
+
+const (
+	TxTypeCreateOrder     = 14
+	TxTypeCancelOrder     = 15
+	TxTypeCancelAllOrders = 16
+	TxTypeModifyOrder     = 17
+
+	TxTypeCreateGroupedOrders = 28
+)
+
+const (
+	OrderTypeLimit           = 0
+	OrderTypeMarket          = 1
+	OrderTypeStopLoss        = 2
+	OrderTypeStopLossLimit   = 3
+	OrderTypeTakeProfit      = 4
+	OrderTypeTakeProfitLimit = 5
+)
+
+const (
+	TimeInForceIOC          = 0
+	TimeInForceGoodTillTime = 1
+	TimeInForcePostOnly     = 2
+)
+
+// GroupingType values for a grouped-orders tx.
+const (
+	GroupingOTO   = 1 // first order triggers the rest
+	GroupingOCO   = 2 // filling one cancels the other
+	GroupingOTOCO = 3 // first triggers an OCO pair
+)
+
+// TimeInForce values for a cancel-all tx.
+const (
+	CancelAllImmediate = 0
+	CancelAllScheduled = 1
+	CancelAllAbort     = 2
+)
+
+const (
+	// how long a signed tx stays valid if the caller doesn't set ExpiredAt
+	defaultTxExpiry = 10 * time.Minute
+	// resting GTT orders live this long unless OrderExpiry is set
+	defaultOrderExpiry = 28 * 24 * time.Hour
+)
+
+// Field limits the exchange enforces; txs outside them are rejected
+// before signing rather than by sendTx.
+const (
+	MinMarketIndex = 0
+	MaxMarketIndex = math.MaxInt16
+
+	maxAccountIndex     = 1<<48 - 2
+	maxAPIKeyIndex      = 254
+	maxClientOrderIndex = 1<<48 - 1
+	maxOrderIndex       = 1<<56 - 1
+	maxBaseAmount       = 1<<48 - 1
+	maxTimestamp        = 1<<48 - 1
+	maxGroupedOrders    = 3
+)
+
+// MarketIndexOf converts a market id to the tx field, refusing ids the
+// field can't hold instead of wrapping them.
+func MarketIndexOf(marketID int) (int16, error) {
+	if marketID < MinMarketIndex || marketID > MaxMarketIndex {
+		return 0, fmt.Errorf("market id %d out of range", marketID)
+	}
+	return int16(marketID), nil
+}
+
+// ----- tx bodies (serialized as tx_info) -----
+
+// CreateOrderTxInfo is the tx_info for an L2 create order.
+// Price and BaseAmount are integers scaled by the market's decimals.
+type CreateOrderTxInfo struct {
+	AccountIndex     int64
+	ApiKeyIndex      uint8
+	MarketIndex      int16
+	ClientOrderIndex int64
+	BaseAmount       int64
+	Price            uint32
+	IsAsk            uint8
+	Type             uint8
+	TimeInForce      uint8
+	ReduceOnly       uint8
+	TriggerPrice     uint32
+	OrderExpiry      int64
+	ExpiredAt        int64
+	Nonce            int64
+	Sig              []byte `json:",omitempty"`
+	SignedHash       string `json:"-"` // hex of the signed digest
+}
+
+func (tx *CreateOrderTxInfo) TxType() uint8 { return TxTypeCreateOrder }
+
+// Hash returns the digest that gets signed for this tx.
+func (tx *CreateOrderTxInfo) Hash(chainID uint32) []byte {
+	elems := txPrefix(chainID, tx.TxType(), tx.Nonce, tx.ExpiredAt, tx.AccountIndex, tx.ApiKeyIndex)
+	elems = append(elems, tx.order().elems()...)
+	return p2.HashToQuinticExtension(elems).ToLittleEndianBytes()
+}
+
+func (tx *CreateOrderTxInfo) order() GroupedOrderInfo {
+	return GroupedOrderInfo{
+		MarketIndex:      tx.MarketIndex,
+		ClientOrderIndex: tx.ClientOrderIndex,
+		BaseAmount:       tx.BaseAmount,
+		Price:            tx.Price,
+		IsAsk:            tx.IsAsk,
+		Type:             tx.Type,
+		TimeInForce:      tx.TimeInForce,
+		ReduceOnly:       tx.ReduceOnly,
+		TriggerPrice:     tx.TriggerPrice,
+		OrderExpiry:      tx.OrderExpiry,
+	}
+}
+
+// Validate applies the exchange's field and order-type rules.
+func (tx *CreateOrderTxInfo) Validate() error {
+	if err := validateEnvelope(tx.AccountIndex, tx.ApiKeyIndex, tx.Nonce, tx.ExpiredAt); err != nil {
+		return err
+	}
+	o := tx.order()
+	if o.ClientOrderIndex < 0 || o.ClientOrderIndex > maxClientOrderIndex {
+		return fmt.Errorf("client order index %d out of range", o.ClientOrderIndex)
+	}
+	if err := o.validateFields(); err != nil {
+		return err
+	}
+	if o.ReduceOnly == 0 && o.BaseAmount == 0 {
+		return errors.New("base amount must be > 0")
+	}
+	return o.validateType()
+}
+
+// GroupedOrderInfo is one order inside a grouped-orders tx. It is a
+// CreateOrderTxInfo without the account/nonce envelope.
+type GroupedOrderInfo struct {
+	MarketIndex      int16
+	ClientOrderIndex int64
+	BaseAmount       int64
+	Price            uint32
+	IsAsk            uint8
+	Type             uint8
+	TimeInForce      uint8
+	ReduceOnly       uint8
+	TriggerPrice     uint32
+	OrderExpiry      int64
+}
+
+func (o GroupedOrderInfo) elems() []g.Element {
+	return []g.Element{
+		g.FromUint32(uint32(o.MarketIndex)),
+		g.FromInt64(o.ClientOrderIndex),
+		g.FromInt64(o.BaseAmount),
+		g.FromUint32(o.Price),
+		g.FromUint32(uint32(o.IsAsk)),
+		g.FromUint32(uint32(o.Type)),
+		g.FromUint32(uint32(o.TimeInForce)),
+		g.FromUint32(uint32(o.ReduceOnly)),
+		g.FromUint32(o.TriggerPrice),
+		g.FromInt64(o.OrderExpiry),
+	}
+}
+
+// validateFields checks ranges shared by every order.
+func (o GroupedOrderInfo) validateFields() error {
+	if o.MarketIndex < MinMarketIndex {
+		return fmt.Errorf("market index %d out of range", o.MarketIndex)
+	}
+	if o.BaseAmount < 0 || o.BaseAmount > maxBaseAmount {
+		return fmt.Errorf("base amount %d out of range", o.BaseAmount)
+	}
+	if o.Price == 0 {
+		return errors.New("price must be > 0")
+	}
+	if o.IsAsk > 1 || o.ReduceOnly > 1 {
+		return errors.New("IsAsk and ReduceOnly must be 0 or 1")
+	}
+	if o.TimeInForce > TimeInForcePostOnly {
+		return fmt.Errorf("invalid time in force %d", o.TimeInForce)
+	}
+	if o.OrderExpiry < 0 {
+		return fmt.Errorf("invalid order expiry %d", o.OrderExpiry)
+	}
+	return nil
+}
+
+// validateType checks time in force, trigger and expiry against the
+// order type: market orders are IOC with no expiry, resting limits need
+// an expiry, and stop/take-profit orders need a trigger and an expiry.
+func (o GroupedOrderInfo) validateType() error {
+	switch o.Type {
+	case OrderTypeMarket:
+		if o.TimeInForce != TimeInForceIOC {
+			return errors.New("market orders must be IOC")
+		}
+		if o.OrderExpiry != 0 || o.TriggerPrice != 0 {
+			return errors.New("market orders take no expiry or trigger")
+		}
+	case OrderTypeLimit:
+		if o.TriggerPrice != 0 {
+			return errors.New("limit orders take no trigger")
+		}
+		if (o.TimeInForce == TimeInForceIOC) != (o.OrderExpiry == 0) {
+			return errors.New("limit orders need an expiry unless IOC")
+		}
+	case OrderTypeStopLoss, OrderTypeTakeProfit:
+		if o.TimeInForce != TimeInForceIOC {
+			return errors.New("stop-loss/take-profit orders must be IOC")
+		}
+		fallthrough
+	case OrderTypeStopLossLimit, OrderTypeTakeProfitLimit:
+		if o.TriggerPrice == 0 {
+			return errors.New("trigger orders need a trigger price")
+		}
+		if o.OrderExpiry == 0 {
+			return errors.New("trigger orders need an expiry")
+		}
+	default:
+		return fmt.Errorf("invalid order type %d", o.Type)
+	}
+	return nil
+}
+
+func (o GroupedOrderInfo) isTrigger() bool {
+	return o.Type >= OrderTypeStopLoss && o.Type <= OrderTypeTakeProfitLimit
+}
+
+// needsExpiry reports whether the exchange requires an OrderExpiry.
+func (o GroupedOrderInfo) needsExpiry() bool {
+	return o.Type != OrderTypeMarket && (o.Type != OrderTypeLimit || o.TimeInForce != TimeInForceIOC)
+}
+
+// CreateGroupedOrdersTxInfo is the tx_info for orders linked by
+// GroupingType, e.g. a parent with stop-loss/take-profit children.
+// Grouped orders carry no client order index, and children of an OTO
+// or OTOCO group have BaseAmount 0: they take the parent's filled size.
+type CreateGroupedOrdersTxInfo struct {
+	AccountIndex int64
+	ApiKeyIndex  uint8
+	GroupingType uint8
+	Orders       []GroupedOrderInfo
+	ExpiredAt    int64
+	Nonce        int64
+	Sig          []byte `json:",omitempty"`
+	SignedHash   string `json:"-"` // hex of the signed digest
+}
+
+func (tx *CreateGroupedOrdersTxInfo) TxType() uint8 { return TxTypeCreateGroupedOrders }
+
+// Hash returns the digest that gets signed for this tx. Orders are
+// hashed one by one and folded into a single hash before the envelope.
+func (tx *CreateGroupedOrdersTxInfo) Hash(chainID uint32) []byte {
+	elems := txPrefix(chainID, tx.TxType(), tx.Nonce, tx.ExpiredAt, tx.AccountIndex, tx.ApiKeyIndex)
+	elems = append(elems, g.FromUint32(uint32(tx.GroupingType)))
+
+	agg := p2.EmptyHashOut()
+	for i, o := range tx.Orders {
+		h := p2.HashNoPad(o.elems())
+		if i == 0 {
+			agg = h
+		} else {
+			agg = p2.HashNToOne([]p2.HashOut{agg, h})
+		}
+	}
+	elems = append(elems, agg[:]...)
+	return p2.HashToQuinticExtension(elems).ToLittleEndianBytes()
+}
+
+// Validate applies the exchange's rules for the group: one market, no
+// client order indexes, and the OTO/OCO/OTOCO shape.
+func (tx *CreateGroupedOrdersTxInfo) Validate() error {
+	if err := validateEnvelope(tx.AccountIndex, tx.ApiKeyIndex, tx.Nonce, tx.ExpiredAt); err != nil {
+		return err
+	}
+	if len(tx.Orders) < 2 || len(tx.Orders) > maxGroupedOrders {
+		return fmt.Errorf("grouped orders need 2 to %d orders, got %d", maxGroupedOrders, len(tx.Orders))
+	}
+	for _, o := range tx.Orders {
+		if o.MarketIndex != tx.Orders[0].MarketIndex {
+			return errors.New("grouped orders must share a market")
+		}
+		if o.ClientOrderIndex != 0 {
+			return errors.New("grouped orders can't carry a client order index")
+		}
+		if err := o.validateFields(); err != nil {
+			return err
+		}
+	}
+
+	parent, children := tx.Orders[0], tx.Orders[1:]
+	switch tx.GroupingType {
+	case GroupingOCO:
+		return validateExitPair(tx.Orders, false)
+	case GroupingOTO, GroupingOTOCO:
+		want := 1
+		if tx.GroupingType == GroupingOTOCO {
+			want = 2
+		}
+		if len(children) != want {
+			return fmt.Errorf("grouping type %d needs %d child orders", tx.GroupingType, want)
+		}
+		if parent.Type != OrderTypeMarket && parent.Type != OrderTypeLimit {
+			return errors.New("the parent of a group must be a market or limit order")
+		}
+		if parent.BaseAmount == 0 {
+			return errors.New("base amount must be > 0")
+		}
+		if err := parent.validateType(); err != nil {
+			return err
+		}
+		for _, c := range children {
+			if c.BaseAmount != 0 {
+				return errors.New("child orders take the parent's size; base amount must be 0")
+			}
+			if c.IsAsk == parent.IsAsk {
+				return errors.New("child orders must be on the opposite side to the parent")
+			}
+			if c.OrderExpiry != children[0].OrderExpiry {
+				return errors.New("child orders must share an expiry")
+			}
+		}
+		if parent.OrderExpiry != 0 && parent.OrderExpiry != children[0].OrderExpiry {
+			return errors.New("a parent with an expiry must share it with its children")
+		}
+		if tx.GroupingType == GroupingOTOCO {
+			return validateExitPair(children, true)
+		}
+		if !children[0].isTrigger() {
+			return errors.New("the child of an OTO group must be a stop-loss or take-profit")
+		}
+		return children[0].validateType()
+	default:
+		return fmt.Errorf("invalid grouping type %d", tx.GroupingType)
+	}
+}
+
+// validateExitPair checks an OCO pair: one stop-loss and one
+// take-profit. A standalone OCO also needs equal, reduce-only sizes on
+// the same side; inside OTOCO the sizes come from the parent.
+func validateExitPair(orders []GroupedOrderInfo, underParent bool) error {
+	if len(orders) != 2 {
+		return errors.New("an OCO pair needs exactly two orders")
+	}
+	a, b := orders[0], orders[1]
+	if !underParent {
+		if a.BaseAmount != b.BaseAmount || a.IsAsk != b.IsAsk {
+			return errors.New("OCO orders must have the same size and side")
+		}
+		if a.ReduceOnly != 1 || b.ReduceOnly != 1 {
+			return errors.New("OCO orders must be reduce-only")
+		}
+		if a.OrderExpiry != b.OrderExpiry {
+			return errors.New("OCO orders must share an expiry")
+		}
+	}
+	var sl, tp bool
+	for _, o := range orders {
+		if err := o.validateType(); err != nil {
+			return err
+		}
+		switch o.Type {
+		case OrderTypeStopLoss, OrderTypeStopLossLimit:
+			sl = true
+		case OrderTypeTakeProfit, OrderTypeTakeProfitLimit:
+			tp = true
+		}
+	}
+	if !sl || !tp {
+		return errors.New("an OCO pair is one stop-loss and one take-profit")
+	}
+	return nil
+}
+
+// CancelOrderTxInfo is the tx_info for an L2 cancel order.
+// Index is the exchange order index (or client order index).
+type CancelOrderTxInfo struct {
+	AccountIndex int64
+	ApiKeyIndex  uint8
+	MarketIndex  int16
+	Index        int64
+	ExpiredAt    int64
+	Nonce        int64
+	Sig          []byte `json:",omitempty"`
+	SignedHash   string `json:"-"` // hex of the signed digest
+}
+
+func (tx *CancelOrderTxInfo) TxType() uint8 { return TxTypeCancelOrder }
+
+// Hash returns the digest that gets signed for this tx.
+func (tx *CancelOrderTxInfo) Hash(chainID uint32) []byte {
+	elems := txPrefix(chainID, tx.TxType(), tx.Nonce, tx.ExpiredAt, tx.AccountIndex, tx.ApiKeyIndex)
+	elems = append(elems,
+		g.FromUint32(uint32(tx.MarketIndex)),
+		g.FromInt64(tx.Index),
+	)
+	return p2.HashToQuinticExtension(elems).ToLittleEndianBytes()
+}
+
+// Validate checks the envelope, market and order index.
+func (tx *CancelOrderTxInfo) Validate() error {
+	if err := validateEnvelope(tx.AccountIndex, tx.ApiKeyIndex, tx.Nonce, tx.ExpiredAt); err != nil {
+		return err
+	}
+	if tx.MarketIndex < MinMarketIndex {
+		return fmt.Errorf("market index %d out of range", tx.MarketIndex)
+	}
+	return validateOrderIndex(tx.Index)
+}
+
+// ModifyOrderTxInfo is the tx_info for amending a resting order in place.
+// Index is the order index (or client order index) being modified.
+type ModifyOrderTxInfo struct {
+	AccountIndex int64
+	ApiKeyIndex  uint8
+	MarketIndex  int16
+	Index        int64
+	BaseAmount   int64
+	Price        uint32
+	TriggerPrice uint32
+	ExpiredAt    int64
+	Nonce        int64
+	Sig          []byte `json:",omitempty"`
+	SignedHash   string `json:"-"` // hex of the signed digest
+}
+
+func (tx *ModifyOrderTxInfo) TxType() uint8 { return TxTypeModifyOrder }
+
+// Hash returns the digest that gets signed for this tx.
+func (tx *ModifyOrderTxInfo) Hash(chainID uint32) []byte {
+	elems := txPrefix(chainID, tx.TxType(), tx.Nonce, tx.ExpiredAt, tx.AccountIndex, tx.ApiKeyIndex)
+	elems = append(elems,
+		g.FromUint32(uint32(tx.MarketIndex)),
+		g.FromInt64(tx.Index),
+		g.FromInt64(tx.BaseAmount),
+		g.FromUint32(tx.Price),
+		g.FromUint32(tx.TriggerPrice),
+	)
+	return p2.HashToQuinticExtension(elems).ToLittleEndianBytes()
+}
+
+// Validate checks the envelope, market, order index, size and price.
+func (tx *ModifyOrderTxInfo) Validate() error {
+	if err := validateEnvelope(tx.AccountIndex, tx.ApiKeyIndex, tx.Nonce, tx.ExpiredAt); err != nil {
+		return err
+	}
+	if tx.MarketIndex < MinMarketIndex {
+		return fmt.Errorf("market index %d out of range", tx.MarketIndex)
+	}
+	if err := validateOrderIndex(tx.Index); err != nil {
+		return err
+	}
+	if tx.BaseAmount <= 0 || tx.BaseAmount > maxBaseAmount {
+		return fmt.Errorf("base amount %d out of range", tx.BaseAmount)
+	}
+	if tx.Price == 0 {
+		return errors.New("price must be > 0")
+	}
+	return nil
+}
+
+// CancelAllOrdersTxInfo is the tx_info for cancelling every open order
+// on the account. Time is only used for scheduled cancels (ms epoch).
+type CancelAllOrdersTxInfo struct {
+	AccountIndex int64
+	ApiKeyIndex  uint8
+	TimeInForce  uint8
+	Time         int64
+	ExpiredAt    int64
+	Nonce        int64
+	Sig          []byte `json:",omitempty"`
+	SignedHash   string `json:"-"` // hex of the signed digest
+}
+
+func (tx *CancelAllOrdersTxInfo) TxType() uint8 { return TxTypeCancelAllOrders }
+
+// Hash returns the digest that gets signed for this tx.
+func (tx *CancelAllOrdersTxInfo) Hash(chainID uint32) []byte {
+	elems := txPrefix(chainID, tx.TxType(), tx.Nonce, tx.ExpiredAt, tx.AccountIndex, tx.ApiKeyIndex)
+	elems = append(elems,
+		g.FromUint32(uint32(tx.TimeInForce)),
+		g.FromInt64(tx.Time),
+	)
+	return p2.HashToQuinticExtension(elems).ToLittleEndianBytes()
+}
+
+// Validate checks the envelope and that a scheduled cancel has a time.
+func (tx *CancelAllOrdersTxInfo) Validate() error {
+	if err := validateEnvelope(tx.AccountIndex, tx.ApiKeyIndex, tx.Nonce, tx.ExpiredAt); err != nil {
+		return err
+	}
+	switch tx.TimeInForce {
+	case CancelAllImmediate, CancelAllAbort:
+		if tx.Time != 0 {
+			return errors.New("only a scheduled cancel-all takes a time")
+		}
+	case CancelAllScheduled:
+		if tx.Time <= 0 || tx.Time > maxTimestamp {
+			return errors.New("scheduled cancel-all needs a time")
+		}
+	default:
+		return fmt.Errorf("invalid cancel-all time in force %d", tx.TimeInForce)
+	}
+	return nil
+}
+
+// txPrefix is the envelope every tx hash starts with: chain id, tx type,
+// nonce, expiry and the signing account/key, as Goldilocks elements.
+func txPrefix(chainID uint32, txType uint8, nonce, expiredAt, account int64, apiKey uint8) []g.Element {
+	elems := make([]g.Element, 0, 20)
+	return append(elems,
+		g.FromUint32(chainID),
+		g.FromUint32(uint32(txType)),
+		g.FromInt64(nonce),
+		g.FromInt64(expiredAt),
+		g.FromInt64(account),
+		g.FromUint32(uint32(apiKey)),
+	)
+}
+
+func validateEnvelope(account int64, apiKey uint8, nonce, expiredAt int64) error {
+	if account < 0 || account > maxAccountIndex {
+		return fmt.Errorf("account index %d out of range", account)
+	}
+	if apiKey > maxAPIKeyIndex {
+		return fmt.Errorf("api key index %d out of range", apiKey)
+	}
+	if nonce < 0 {
+		return fmt.Errorf("invalid nonce %d", nonce)
+	}
+	if expiredAt < 0 || expiredAt > maxTimestamp {
+		return fmt.Errorf("invalid tx expiry %d", expiredAt)
+	}
+	return nil
+}
+
+// validateOrderIndex accepts a client order index or an exchange order
+// index; the two ranges don't overlap.
+func validateOrderIndex(idx int64) error {
+	if idx < 1 || idx > maxOrderIndex {
+		return fmt.Errorf("order index %d out of range", idx)
+	}
+	return nil
+}
+
+// ----- Signer -----
+
+// Signer holds the API key for one account and signs txs for it with
+// Lighter's scheme: Schnorr over the ECgFp5 curve, on Poseidon2 tx
+// digests.
+type Signer struct {
+	key          curve.ECgFp5Scalar
+	accountIndex int64
+	apiKeyIndex  uint8
+	chainID      uint32
+
+	// nonce source for signatures; tests swap in a fixed one
+	sampleK func() curve.ECgFp5Scalar
+}
+
+// NewSigner parses a 40-byte hex API private key (with or without 0x).
+func NewSigner(privateKeyHex string, accountIndex int64, apiKeyIndex uint8, chainID uint32) (*Signer, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(privateKeyHex), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("decode private key: %w", err)
+	}
+	if len(raw) != 40 {
+		return nil, fmt.Errorf("private key must be 40 bytes, got %d", len(raw))
+	}
+	if accountIndex < 0 || accountIndex > maxAccountIndex {
+		return nil, fmt.Errorf("invalid account index %d", accountIndex)
+	}
+	if apiKeyIndex > maxAPIKeyIndex {
+		return nil, fmt.Errorf("invalid api key index %d", apiKeyIndex)
+	}
+
+	return &Signer{
+		key:          curve.ScalarElementFromLittleEndianBytes(raw),
+		accountIndex: accountIndex,
+		apiKeyIndex:  apiKeyIndex,
+		chainID:      chainID,
+		sampleK:      curve.SampleScalarCrypto,
+	}, nil
+}
+
+// NewSignerFromEnv builds a signer from LIGHTER_API_PRIVATE_KEY,
+// LIGHTER_ACCOUNT_INDEX, LIGHTER_API_KEY_INDEX and LIGHTER_CHAIN_ID.
+func NewSignerFromEnv() (*Signer, error) {
+	keyHex := os.Getenv(LIGHTER_API_PRIVATE_KEY)
+	if keyHex == "" {
+		return nil, errors.New(LIGHTER_API_PRIVATE_KEY + " not set in env")
+	}
+
+	acctStr := os.Getenv(ACCOUNT_INDEX)
+	if acctStr == "" {
+		return nil, errors.New(ACCOUNT_INDEX + " not set in env")
+	}
+	acct, err := strconv.ParseInt(acctStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ACCOUNT_INDEX, err)
+	}
+
+	var apiKey uint64
+	if v := os.Getenv(API_KEY_INDEX); v != "" {
+		apiKey, err = strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", API_KEY_INDEX, err)
+		}
+	}
+
+	chainID := uint64(MAINNET_CHAIN_ID)
+	if v := os.Getenv(CHAIN_ID); v != "" {
+		chainID, err = strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", CHAIN_ID, err)
+		}
+	}
+
+	return NewSigner(keyHex, acct, uint8(apiKey), uint32(chainID))
+}
+
+func (s *Signer) AccountIndex() int64 { return s.accountIndex }
+func (s *Signer) APIKeyIndex() uint8  { return s.apiKeyIndex }
+func (s *Signer) ChainID() uint32     { return s.chainID }
+
+// PublicKey returns the hex public key registered for this API key.
+func (s *Signer) PublicKey() string {
+	return hex.EncodeToString(schnorr.SchnorrPkFromSk(s.key).ToLittleEndianBytes())
+}
+
+// AuthToken returns a token for the account's authenticated GET
+// endpoints, valid until deadline: "deadline:account:key:signature".
+func (s *Signer) AuthToken(deadline time.Time) (string, error) {
+	msg := fmt.Sprintf("%d:%d:%d", deadline.Unix(), s.accountIndex, s.apiKeyIndex)
+	elems, err := g.ArrayFromCanonicalLittleEndianBytes([]byte(msg))
+	if err != nil {
+		return "", fmt.Errorf("auth token: %w", err)
+	}
+	sig, err := s.sign(p2.HashToQuinticExtension(elems).ToLittleEndianBytes())
+	if err != nil {
+		return "", err
+	}
+	return msg + ":" + hex.EncodeToString(sig), nil
+}
+
+// sign signs a 40-byte tx digest; the signature is S || E, 80 bytes.
+func (s *Signer) sign(digest []byte) ([]byte, error) {
+	msg, err := gFp5.FromCanonicalLittleEndianBytes(digest)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	return schnorr.SchnorrSignHashedMessage2(msg, s.key, s.sampleK()).ToBytes(), nil
+}
+
+// signTx validates tx, then sets its Sig and SignedHash and returns
+// the tx_info JSON.
+func (s *Signer) signTx(tx interface {
+	Validate() error
+	Hash(chainID uint32) []byte
+}, sig *[]byte, signedHash *string) (string, error) {
+	if err := tx.Validate(); err != nil {
+		return "", err
+	}
+	digest := tx.Hash(s.chainID)
+	b, err := s.sign(digest)
+	if err != nil {
+		return "", err
+	}
+	*sig, *signedHash = b, hex.EncodeToString(digest)
+	return marshalTxInfo(tx)
+}
+
+// SignCreateOrder fills in account/key/expiry, signs the tx and returns
+// the tx_info JSON ready for sendTx. The caller sets Nonce.
+func (s *Signer) SignCreateOrder(tx *CreateOrderTxInfo) (string, error) {
+	now := time.Now()
+	tx.AccountIndex = s.accountIndex
+	tx.ApiKeyIndex = s.apiKeyIndex
+	if tx.ExpiredAt == 0 {
+		tx.ExpiredAt = now.Add(defaultTxExpiry).UnixMilli()
+	}
+	if tx.OrderExpiry == 0 && tx.order().needsExpiry() {
+		tx.OrderExpiry = now.Add(defaultOrderExpiry).UnixMilli()
+	}
+	return s.signTx(tx, &tx.Sig, &tx.SignedHash)
+}
+
+// SignCreateGroupedOrders fills in account/key/expiry, signs the tx and
+// returns the tx_info JSON ready for sendTx. The caller sets Nonce.
+// Orders that need an expiry and have none share one, as the exchange
+// requires for a parent and its children.
+func (s *Signer) SignCreateGroupedOrders(tx *CreateGroupedOrdersTxInfo) (string, error) {
+	now := time.Now()
+	tx.AccountIndex = s.accountIndex
+	tx.ApiKeyIndex = s.apiKeyIndex
+	if tx.ExpiredAt == 0 {
+		tx.ExpiredAt = now.Add(defaultTxExpiry).UnixMilli()
+	}
+	expiry := now.Add(defaultOrderExpiry).UnixMilli()
+	for i := range tx.Orders {
+		if o := &tx.Orders[i]; o.OrderExpiry == 0 && o.needsExpiry() {
+			o.OrderExpiry = expiry
+		}
+	}
+	return s.signTx(tx, &tx.Sig, &tx.SignedHash)
+}
+
+// SignCancelOrder fills in account/key/expiry, signs the tx and returns
+// the tx_info JSON ready for sendTx. The caller sets Nonce.
+func (s *Signer) SignCancelOrder(tx *CancelOrderTxInfo) (string, error) {
+	tx.AccountIndex = s.accountIndex
+	tx.ApiKeyIndex = s.apiKeyIndex
+	if tx.ExpiredAt == 0 {
+		tx.ExpiredAt = time.Now().Add(defaultTxExpiry).UnixMilli()
+	}
+	return s.signTx(tx, &tx.Sig, &tx.SignedHash)
+}
+
+// SignModifyOrder fills in account/key/expiry, signs the tx and returns
+// the tx_info JSON ready for sendTx. The caller sets Nonce.
+func (s *Signer) SignModifyOrder(tx *ModifyOrderTxInfo) (string, error) {
+	tx.AccountIndex = s.accountIndex
+	tx.ApiKeyIndex = s.apiKeyIndex
+	if tx.ExpiredAt == 0 {
+		tx.ExpiredAt = time.Now().Add(defaultTxExpiry).UnixMilli()
+	}
+	return s.signTx(tx, &tx.Sig, &tx.SignedHash)
+}
+
+// SignCancelAllOrders fills in account/key/expiry, signs the tx and
+// returns the tx_info JSON ready for sendTx. The caller sets Nonce.
+func (s *Signer) SignCancelAllOrders(tx *CancelAllOrdersTxInfo) (string, error) {
+	tx.AccountIndex = s.accountIndex
+	tx.ApiKeyIndex = s.apiKeyIndex
+	if tx.ExpiredAt == 0 {
+		tx.ExpiredAt = time.Now().Add(defaultTxExpiry).UnixMilli()
+	}
+	return s.signTx(tx, &tx.Sig, &tx.SignedHash)
+}
+
+// VerifyTx checks sig against a tx digest and hex public key. It needs
+// no network access, so it doubles as the check for offline test vectors.
+func VerifyTx(publicKeyHex string, digest, sig []byte) bool {
+	pub, err := hex.DecodeString(strings.TrimPrefix(publicKeyHex, "0x"))
+	if err != nil {
+		return false
+	}
+	return schnorr.Validate(pub, digest, sig) == nil
+}
+
+func marshalTxInfo(tx any) (string, error) {
+	b, err := json.Marshal(tx)
+	if err != nil {
+		return "", fmt.Errorf("encode tx info: %w", err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,274 @@
+// backend/internal/lighter/signer_test.go
+package internal
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	curve "github.com/elliottech/poseidon_crypto/curve/ecgfp5"
+	g "github.com/elliottech/poseidon_crypto/field/goldilocks"
+	gFp5 "github.com/elliottech/poseidon_crypto/field/goldilocks_quintic_extension"
+)
+
+// Expected digests, public key and signature below were produced with
+// Lighter's Go SDK (github.com/elliottech/lighter-go, txtypes.*.Hash,
+// signer.NewKeyManager) for the same fields on chain 304.
+const (
+	testKeyHex = "a1b2c3d4e5f60718293a4b5c6d7e8f90112233445566778899aabbccddeeff001122334455667708"
+	testPubHex = "23d869dc3c92429e643cf83685dec41291114753f201b8de5107dba5e2198f40cba49047d6452a55"
+	testChain  = 304
+)
+
+func testSigner(t *testing.T) *Signer {
+	t.Helper()
+	s, err := NewSigner(testKeyHex, 12345, 3, testChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := curve.ScalarElementFromLittleEndianBytes([]byte("0123456789abcdef0123456789abcdef01234567"))
+	s.sampleK = func() curve.ECgFp5Scalar { return k }
+	return s
+}
+
+func testCreateOrder() *CreateOrderTxInfo {
+	return &CreateOrderTxInfo{
+		AccountIndex: 12345, ApiKeyIndex: 3, MarketIndex: 1,
+		ClientOrderIndex: 777, BaseAmount: 1500, Price: 6543210,
+		IsAsk: 1, Type: OrderTypeLimit, TimeInForce: TimeInForceGoodTillTime,
+		OrderExpiry: 1767225600000, ExpiredAt: 1764633600000, Nonce: 42,
+	}
+}
+
+func testBracket() *CreateGroupedOrdersTxInfo {
+	return &CreateGroupedOrdersTxInfo{
+		AccountIndex: 12345, ApiKeyIndex: 3, GroupingType: GroupingOTOCO,
+		Orders: []GroupedOrderInfo{
+			{MarketIndex: 1, BaseAmount: 1500, Price: 6543210, Type: OrderTypeLimit,
+				TimeInForce: TimeInForceGoodTillTime, OrderExpiry: 1767225600000},
+			{MarketIndex: 1, Price: 6000000, IsAsk: 1, Type: OrderTypeStopLoss,
+				ReduceOnly: 1, TriggerPrice: 6100000, OrderExpiry: 1767225600000},
+			{MarketIndex: 1, Price: 7000000, IsAsk: 1, Type: OrderTypeTakeProfit,
+				ReduceOnly: 1, TriggerPrice: 6900000, OrderExpiry: 1767225600000},
+		},
+		ExpiredAt: 1764633600000, Nonce: 46,
+	}
+}
+
+func TestTxHashMatchesSDK(t *testing.T) {
+	cases := []struct {
+		name string
+		tx   interface{ Hash(uint32) []byte }
+		want string
+	}{
+		{"create", testCreateOrder(),
+			"ac55b92576516cb84df9a4fc0322ec3c8406f7a576f5a427cf3e403c9485d07cb5e167e06b2fc0d2"},
+		{"cancel", &CancelOrderTxInfo{AccountIndex: 12345, ApiKeyIndex: 3, MarketIndex: 1,
+			Index: 281474976710700, ExpiredAt: 1764633600000, Nonce: 43},
+			"70572cda43b6b6903fdcc780747b98275964a9ea6954187735119eb8e8d001759c9de85e8eb9481a"},
+		{"modify", &ModifyOrderTxInfo{AccountIndex: 12345, ApiKeyIndex: 3, MarketIndex: 1,
+			Index: 281474976710700, BaseAmount: 2000, Price: 6500000, ExpiredAt: 1764633600000, Nonce: 44},
+			"1c720d55fe1fa7c67467d12c139c744854eecc142949680a2756b5ba5befdfbcdbb3f26db591b09f"},
+		{"cancel all", &CancelAllOrdersTxInfo{AccountIndex: 12345, ApiKeyIndex: 3,
+			TimeInForce: CancelAllImmediate, ExpiredAt: 1764633600000, Nonce: 45},
+			"9dd191e927680f3268ae23d0974969cfc1fee92b4364da015dd3ff4808edaa5ecc74eda70c0ce9ab"},
+		{"grouped", testBracket(),
+			"cab701241c28a2b1f84db5116f0d23ed02e8ff692d191325c196b0461a47593a4a8c4bded76a8ce6"},
+	}
+	for _, c := range cases {
+		if got := hex.EncodeToString(c.tx.Hash(testChain)); got != c.want {
+			t.Errorf("%s: hash %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSignCreateOrder(t *testing.T) {
+	s := testSigner(t)
+	if got := s.PublicKey(); got != testPubHex {
+		t.Fatalf("public key %s, want %s", got, testPubHex)
+	}
+
+	tx := testCreateOrder()
+	info, err := s.SignCreateOrder(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantSig = "b240ef548aafee55269fa99744de52ce0666d398eea7181e3aec69a2fbede23339d3d6dc9a22ea7a" +
+		"8bf5f4665ef05cb9b7c0623bcbad990db324c2bb90f32e27a15dcd4b4f53064444d83a73ec021516"
+	if got := hex.EncodeToString(tx.Sig); got != wantSig {
+		t.Errorf("sig %s, want %s", got, wantSig)
+	}
+	if tx.SignedHash != "ac55b92576516cb84df9a4fc0322ec3c8406f7a576f5a427cf3e403c9485d07cb5e167e06b2fc0d2" {
+		t.Errorf("signed hash %s", tx.SignedHash)
+	}
+	if strings.Contains(info, "SignedHash") || !strings.Contains(info, `"MarketIndex":1`) {
+		t.Errorf("tx_info %s", info)
+	}
+
+	digest, _ := hex.DecodeString(tx.SignedHash)
+	if !VerifyTx(s.PublicKey(), digest, tx.Sig) {
+		t.Error("signature doesn't verify")
+	}
+	digest[0] ^= 1
+	if VerifyTx(s.PublicKey(), digest, tx.Sig) {
+		t.Error("signature verifies against another digest")
+	}
+}
+
+// Vector from poseidon_crypto's schnorr tests (secret key, hashed
+// message and nonce in, S and E out).
+func TestSignSchnorrVector(t *testing.T) {
+	k := curve.ECgFp5Scalar{5245666847777449560, 15178169970799106939, 4403065012435293749, 15306540389399388999, 8935555081913173844}
+	s := &Signer{
+		key:     curve.ECgFp5Scalar{12235002942052073545, 1175977464658719998, 8536934969147463310, 6524687619313720391, 2922072024880609112},
+		sampleK: func() curve.ECgFp5Scalar { return k },
+	}
+	msg := gFp5.Element{
+		g.FromUint64(8398652514106806347),
+		g.FromUint64(11069112711939986896),
+		g.FromUint64(9732488227085561369),
+		g.FromUint64(18076754337204438535),
+		g.FromUint64(17155407358725346236),
+	}
+	sig, err := s.sign(msg.ToLittleEndianBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantS := curve.ECgFp5Scalar{6950590877883398434, 17178336263794770543, 11012823478139181320, 16445091359523510936, 5882925226143600273}
+	wantE := curve.ECgFp5Scalar{4544744459434870309, 4180764085957612004, 3024669018778978615, 15433417688859446606, 6775027260348937828}
+	if got := curve.ScalarElementFromLittleEndianBytes(sig[:40]); got != wantS {
+		t.Errorf("S = %v, want %v", got, wantS)
+	}
+	if got := curve.ScalarElementFromLittleEndianBytes(sig[40:]); got != wantE {
+		t.Errorf("E = %v, want %v", got, wantE)
+	}
+}
+
+func TestSignGroupedDefaultsExpiry(t *testing.T) {
+	s := testSigner(t)
+	tx := testBracket()
+	for i := range tx.Orders {
+		tx.Orders[i].OrderExpiry = 0
+	}
+	if _, err := s.SignCreateGroupedOrders(tx); err != nil {
+		t.Fatal(err)
+	}
+	exp := tx.Orders[0].OrderExpiry
+	if exp == 0 || tx.Orders[1].OrderExpiry != exp || tx.Orders[2].OrderExpiry != exp {
+		t.Errorf("expiries %d %d %d, want one shared expiry",
+			tx.Orders[0].OrderExpiry, tx.Orders[1].OrderExpiry, tx.Orders[2].OrderExpiry)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	cases := []struct {
+		name  string
+		tx    interface{ Validate() error }
+		error string
+	}{
+		{"market with expiry", func() *CreateOrderTxInfo {
+			tx := testCreateOrder()
+			tx.Type, tx.TimeInForce = OrderTypeMarket, TimeInForceIOC
+			return tx
+		}(), "no expiry"},
+		{"resting limit without expiry", func() *CreateOrderTxInfo {
+			tx := testCreateOrder()
+			tx.OrderExpiry = 0
+			return tx
+		}(), "need an expiry"},
+		{"negative market", func() *CreateOrderTxInfo {
+			tx := testCreateOrder()
+			tx.MarketIndex = -1
+			return tx
+		}(), "market index"},
+		{"zero size", func() *CreateOrderTxInfo {
+			tx := testCreateOrder()
+			tx.BaseAmount = 0
+			return tx
+		}(), "base amount"},
+		{"grouped client index", func() *CreateGroupedOrdersTxInfo {
+			tx := testBracket()
+			tx.Orders[0].ClientOrderIndex = 9
+			return tx
+		}(), "client order index"},
+		{"sized child", func() *CreateGroupedOrdersTxInfo {
+			tx := testBracket()
+			tx.Orders[1].BaseAmount = 1500
+			return tx
+		}(), "base amount must be 0"},
+		{"child on parent side", func() *CreateGroupedOrdersTxInfo {
+			tx := testBracket()
+			tx.Orders[2].IsAsk = 0
+			return tx
+		}(), "opposite side"},
+		{"two stops", func() *CreateGroupedOrdersTxInfo {
+			tx := testBracket()
+			tx.Orders[2].Type = OrderTypeStopLoss
+			return tx
+		}(), "one stop-loss and one take-profit"},
+		{"stop without expiry", func() *CreateGroupedOrdersTxInfo {
+			tx := testBracket()
+			tx.GroupingType = GroupingOTO
+			tx.Orders = tx.Orders[:2]
+			tx.Orders[0].OrderExpiry, tx.Orders[1].OrderExpiry = 0, 0
+			tx.Orders[0].TimeInForce = TimeInForceIOC
+			return tx
+		}(), "need an expiry"},
+		{"unscheduled cancel-all time", &CancelAllOrdersTxInfo{Time: 5}, "scheduled"},
+		{"cancel index 0", &CancelOrderTxInfo{MarketIndex: 1}, "order index"},
+	}
+	for _, c := range cases {
+		err := c.tx.Validate()
+		if err == nil || !strings.Contains(err.Error(), c.error) {
+			t.Errorf("%s: err %v, want %q", c.name, err, c.error)
+		}
+	}
+	if err := testCreateOrder().Validate(); err != nil {
+		t.Errorf("valid create order: %v", err)
+	}
+	if err := testBracket().Validate(); err != nil {
+		t.Errorf("valid bracket: %v", err)
+	}
+}
+
+func TestMarketIndexOf(t *testing.T) {
+	for id, ok := range map[int]bool{0: true, 255: true, 2048: true, MaxMarketIndex: true, -1: false, MaxMarketIndex + 1: false} {
+		if _, err := MarketIndexOf(id); (err == nil) != ok {
+			t.Errorf("MarketIndexOf(%d): err %v", id, err)
+		}
+	}
+}
+
+func TestNewSignerRejectsBadKeys(t *testing.T) {
+	if _, err := NewSigner(testKeyHex[:64], 1, 0, testChain); err == nil {
+		t.Error("32-byte key accepted")
+	}
+	if _, err := NewSigner(testKeyHex, 1, 255, testChain); err == nil {
+		t.Error("api key index 255 accepted")
+	}
+	if _, err := NewSigner("0x"+testKeyHex, 1, 0, testChain); err != nil {
+		t.Errorf("0x-prefixed key: %v", err)
+	}
+}
+
+func TestAuthToken(t *testing.T) {
+	s := testSigner(t)
+	tok, err := s.AuthToken(time.Unix(1764633600, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const prefix = "1764633600:12345:3:"
+	if !strings.HasPrefix(tok, prefix) {
+		t.Fatalf("token %q", tok)
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(tok, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// digest of the message per the SDK's ConstructAuthToken
+	digest, _ := hex.DecodeString("e3e40489ed73d84e26e583117e33f68642f1a14b3ea4534e0ab517fe0b866a06dac7db1d6ae60314")
+	if !VerifyTx(s.PublicKey(), digest, sig) {
+		t.Error("auth token signature doesn't verify")
+	}
+}
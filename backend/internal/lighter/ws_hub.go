@@ -1 +1,201 @@
-package internal
\ No newline at end of file
+// backend/internal/lighter/ws_hub.go
+package internal
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MarketSnapshot is one merged view of every market. At is when the
+// underlying data was fetched; Stale means upstream was failing.
+type MarketSnapshot struct {
+	Markets []MarketRow
+	Funding []FundingRate // every exchange's rates, when they loaded
+	At      time.Time
+	Stale   bool
+}
+
+// FundingRate is one exchange's current funding rate for a symbol, as
+// Lighter's funding-rates endpoint lists them.
+type FundingRate struct {
+	MarketID int     `json:"market_id"`
+	Exchange string  `json:"exchange"`
+	Symbol   string  `json:"symbol"`
+	Rate     Decimal `json:"rate"`
+}
+
+// ExchangeLighter is Lighter's own row in the funding-rates list.
+const ExchangeLighter = "lighter"
+
+// FundingTable is funding by symbol, then exchange. Exchange names are
+// lowercased so lookups don't depend on how upstream spells them.
+type FundingTable map[string]map[string]Decimal
+
+func NewFundingTable(rates []FundingRate) FundingTable {
+	t := make(FundingTable)
+	for _, fr := range rates {
+		byEx, ok := t[fr.Symbol]
+		if !ok {
+			byEx = make(map[string]Decimal)
+			t[fr.Symbol] = byEx
+		}
+		byEx[strings.ToLower(fr.Exchange)] = fr.Rate
+	}
+	return t
+}
+
+// Rate is one exchange's rate for symbol.
+func (t FundingTable) Rate(exchange, symbol string) (Decimal, bool) {
+	r, ok := t[symbol][strings.ToLower(exchange)]
+	return r, ok
+}
+
+// MarketFetcher loads a fresh market list (REST merge, stream state, ...).
+type MarketFetcher func(ctx context.Context) (MarketSnapshot, error)
+
+const (
+	defaultHubInterval  = 2 * time.Second
+	defaultHubClientBuf = 8
+	hubFetchTimeout     = 10 * time.Second
+)
+
+// HubSubscription is one consumer of the hub. C is closed when the
+// subscriber unsubscribes or is dropped for falling behind.
+type HubSubscription struct {
+	C <-chan MarketSnapshot
+
+	ch      chan MarketSnapshot
+	dropped bool
+}
+
+// Dropped reports whether the hub cut this subscriber off because its
+// buffer was full. Only meaningful once C has been closed.
+func (s *HubSubscription) Dropped() bool { return s.dropped }
+
+// MarketHub fetches markets once per interval and fans the snapshot out
+// to every subscriber, so N websocket clients cost one upstream poll.
+type MarketHub struct {
+	fetch    MarketFetcher
+	interval time.Duration
+	bufSize  int
+
+	mu     sync.RWMutex
+	latest *MarketSnapshot
+	subs   map[*HubSubscription]struct{}
+}
+
+// NewMarketHub builds a hub; zero interval/bufSize take the defaults.
+func NewMarketHub(fetch MarketFetcher, interval time.Duration, bufSize int) *MarketHub {
+	if interval <= 0 {
+		interval = defaultHubInterval
+	}
+	if bufSize <= 0 {
+		bufSize = defaultHubClientBuf
+	}
+	return &MarketHub{
+		fetch:    fetch,
+		interval: interval,
+		bufSize:  bufSize,
+		subs:     make(map[*HubSubscription]struct{}),
+	}
+}
+
+// Run polls until ctx is done. A failed fetch keeps the last snapshot.
+func (h *MarketHub) Run(ctx context.Context) {
+	h.poll(ctx)
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.poll(ctx)
+		}
+	}
+}
+
+func (h *MarketHub) poll(ctx context.Context) {
+	fctx, cancel := context.WithTimeout(ctx, hubFetchTimeout)
+	defer cancel()
+
+	snap, err := h.fetch(fctx)
+	if err != nil {
+		log.Printf("market hub fetch error: %v", err)
+		return
+	}
+	if snap.At.IsZero() {
+		snap.At = time.Now()
+	}
+	h.Publish(snap)
+}
+
+// Publish stores snap as the latest and sends it to every subscriber.
+// A subscriber whose buffer is full is dropped rather than waited on.
+func (h *MarketHub) Publish(snap MarketSnapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.latest = &snap
+	for s := range h.subs {
+		select {
+		case s.ch <- snap:
+		default:
+			s.dropped = true
+			h.removeLocked(s)
+		}
+	}
+}
+
+// Latest returns the most recent snapshot, if any fetch has succeeded.
+func (h *MarketHub) Latest() (MarketSnapshot, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.latest == nil {
+		return MarketSnapshot{}, false
+	}
+	return *h.latest, true
+}
+
+// Subscribe registers a consumer. It gets the latest snapshot right away
+// when there is one, then every new one.
+func (h *MarketHub) Subscribe() *HubSubscription {
+	ch := make(chan MarketSnapshot, h.bufSize)
+	s := &HubSubscription{C: ch, ch: ch}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.latest != nil {
+		ch <- *h.latest
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Unsubscribe removes s and closes its channel. Safe to call twice.
+func (h *MarketHub) Unsubscribe(s *HubSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(s)
+}
+
+// Subscribers is the number of live subscriptions.
+func (h *MarketHub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+func (h *MarketHub) removeLocked(s *HubSubscription) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	close(s.ch)
+}
@@ -6,8 +6,8 @@ export type MarketRow = {
   symbol: string;
   market_id: number;
   status: string;
-  taker_fee: string;
-  maker_fee: string;
+  taker_fee: number;
+  maker_fee: number;
   open_interest: number;
   index_price: number;
   mark_price: number;